@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iljarotar/synth/config"
+	"github.com/iljarotar/synth/file"
+	"github.com/iljarotar/synth/pcm"
+	s "github.com/iljarotar/synth/synth"
+	"github.com/iljarotar/synth/wav"
+	"github.com/spf13/cobra"
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render [file]",
+	Short: "Render a patch to a WAV file without playing it",
+	Long: `Render a patch to a WAV file without playing it.
+
+The configured fade-in and fade-out are applied, so the fade-out ends exactly at the given duration.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename, c, err := prepare(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		duration, _ := cmd.Flags().GetDuration("duration")
+		if duration <= 0 {
+			return fmt.Errorf("duration must be positive")
+		}
+
+		f, _ := cmd.Flags().GetString("format")
+		format, err := pcm.ParseFormat(f)
+		if err != nil {
+			return err
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".wav"
+		}
+
+		err = render(filename, output, duration, format, c)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("rendered %s to %s\n", filename, output)
+		return nil
+	},
+}
+
+func init() {
+	defaultConfigPath, err := config.GetDefaultConfigPath()
+	if err != nil {
+		os.Exit(1)
+	}
+	renderCmd.Flags().IntP("sample-rate", "s", config.DefaultSampleRate, "sample rate")
+	renderCmd.Flags().Float64P("fade-in", "i", config.DefaultFadeIn, "fade-in in seconds")
+	renderCmd.Flags().Float64("fade-out", config.DefaultFadeOut, "fade-out in seconds")
+	renderCmd.Flags().StringP("config", "c", defaultConfigPath, "path to your config file")
+	renderCmd.Flags().DurationP("duration", "d", 0, "length of the rendered audio, e.g. 90s or 2m30s")
+	// -o is the shorthand of --fade-out when playing, so --output has none to avoid confusion
+	renderCmd.Flags().String("output", "", "path of the WAV file (defaults to the patch file name with a .wav extension)")
+	renderCmd.Flags().StringP("format", "f", string(pcm.FormatS16LE), "sample format, one of s16le, s24le, f32le")
+
+	rootCmd.AddCommand(renderCmd)
+}
+
+func render(filename, output string, duration time.Duration, format pcm.Format, c *config.Config) error {
+	synth, err := file.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	err = synth.Initialize(float64(c.SampleRate))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	err = writeWav(f, synth, duration, format, c)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to write %s: %w", output, err), f.Close())
+	}
+
+	return f.Close()
+}
+
+func writeWav(f *os.File, synth *s.Synth, duration time.Duration, format pcm.Format, c *config.Config) error {
+	w, err := wav.NewWriter(f, c.SampleRate, format)
+	if err != nil {
+		return err
+	}
+
+	var (
+		sampleRate  = float64(c.SampleRate)
+		fadeOut     = min(c.FadeOut, duration.Seconds())
+		totalFrames = int(duration.Seconds() * sampleRate)
+		fadeOutAt   = totalFrames - int(fadeOut*sampleRate)
+	)
+
+	synth.FadeIn(c.FadeIn)
+	for i := range totalFrames {
+		if i == fadeOutAt {
+			synth.FadeOut(fadeOut)
+		}
+
+		o := synth.GetOutput()
+		err := w.WriteFrame([2]float64{o.Left, o.Right})
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
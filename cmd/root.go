@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/iljarotar/synth/audio"
@@ -11,6 +17,7 @@ import (
 	"github.com/iljarotar/synth/control"
 	"github.com/iljarotar/synth/file"
 	"github.com/iljarotar/synth/log"
+	"github.com/iljarotar/synth/pcm"
 	"github.com/iljarotar/synth/ui"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -18,6 +25,18 @@ import (
 
 var version = "unknown"
 
+const (
+	outputAudio  = "audio"
+	outputStdout = "stdout"
+)
+
+type options struct {
+	record   string
+	output   string
+	format   pcm.Format
+	realtime bool
+}
+
 var rootCmd = &cobra.Command{
 	Use:     "synth",
 	Version: version,
@@ -25,43 +44,24 @@ var rootCmd = &cobra.Command{
 	Long: `A modular synthesizer for the command line.
 	
 Documentation and usage: https://github.com/iljarotar/synth`,
+	Args:                       patchArgs,
+	SuggestionsMinimumDistance: 2,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, _ := cmd.Flags().GetString("config")
-
-		err := config.EnsureDefaultConfig()
-		if err != nil {
-			return err
-		}
-
 		if len(args) == 0 {
 			return cmd.Help()
 		}
 
-		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
-			return fmt.Errorf("too many arguments passed - at most one argument expected")
-		}
-		filename := args[0]
-
-		defaultConfigPath, err := config.GetDefaultConfigPath()
+		filename, c, err := prepare(cmd, args)
 		if err != nil {
 			return err
 		}
 
-		if cfg == "" {
-			cfg = defaultConfigPath
-		}
-
-		c, err := config.LoadConfig(cfg)
-		if err != nil {
-			return fmt.Errorf("could not load config file: %w", err)
-		}
-
-		err = parseFlags(cmd, c)
+		opts, err := parseOptions(cmd)
 		if err != nil {
 			return err
 		}
 
-		return start(filename, c)
+		return start(filename, opts, c)
 	},
 }
 
@@ -82,6 +82,90 @@ func init() {
 	rootCmd.Flags().Float64P("fade-in", "i", config.DefaultFadeIn, "fade-in in seconds")
 	rootCmd.Flags().Float64P("fade-out", "o", config.DefaultFadeOut, "fade-out in seconds")
 	rootCmd.Flags().StringP("config", "c", defaultConfigPath, "path to your config file")
+	rootCmd.Flags().StringP("record", "r", "", "record the playback to this WAV file, press 'r' to start or stop recording")
+	rootCmd.Flags().String("output", outputAudio, "where to send the audio, one of audio, stdout")
+	rootCmd.Flags().StringP("format", "f", string(pcm.FormatF32LE), "sample format of raw output to stdout, one of s16le, s24le, f32le")
+	rootCmd.Flags().Bool("realtime", true, "pace raw output to stdout in real time, if false frames are written as fast as they are consumed")
+}
+
+// patchArgs accepts a single patch file, which cobra would otherwise treat as an unknown subcommand
+// an argument that is neither an existing file nor has an extension is most likely a mistyped subcommand
+func patchArgs(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(args[0]); err != nil && filepath.Ext(args[0]) == "" {
+		msg := fmt.Sprintf("unknown command %q for %q", args[0], cmd.CommandPath())
+		if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+			msg += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+		}
+		return errors.New(msg)
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("wrong number of arguments passed - exactly one argument expected")
+	}
+	return nil
+}
+
+func parseOptions(cmd *cobra.Command) (*options, error) {
+	record, _ := cmd.Flags().GetString("record")
+	output, _ := cmd.Flags().GetString("output")
+	f, _ := cmd.Flags().GetString("format")
+	realtime, _ := cmd.Flags().GetBool("realtime")
+
+	if output != outputAudio && output != outputStdout {
+		return nil, fmt.Errorf("unknown output %s - must be one of %s, %s", output, outputAudio, outputStdout)
+	}
+
+	format, err := pcm.ParseFormat(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &options{
+		record:   record,
+		output:   output,
+		format:   format,
+		realtime: realtime,
+	}, nil
+}
+
+// prepare validates the arguments and loads the config with all flags applied
+func prepare(cmd *cobra.Command, args []string) (string, *config.Config, error) {
+	cfg, _ := cmd.Flags().GetString("config")
+
+	err := config.EnsureDefaultConfig()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+		return "", nil, fmt.Errorf("wrong number of arguments passed - exactly one argument expected")
+	}
+	filename := args[0]
+
+	defaultConfigPath, err := config.GetDefaultConfigPath()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if cfg == "" {
+		cfg = defaultConfigPath
+	}
+
+	c, err := config.LoadConfig(cfg)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not load config file: %w", err)
+	}
+
+	err = parseFlags(cmd, c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filename, c, nil
 }
 
 func parseFlags(cmd *cobra.Command, config *config.Config) error {
@@ -89,20 +173,32 @@ func parseFlags(cmd *cobra.Command, config *config.Config) error {
 	in, _ := cmd.Flags().GetFloat64("fade-in")
 	out, _ := cmd.Flags().GetFloat64("fade-out")
 
-	if cmd.Flag("sample-rate").Changed {
+	if changed(cmd, "sample-rate") {
 		config.SampleRate = s
 	}
-	if cmd.Flag("fade-in").Changed {
+	if changed(cmd, "fade-in") {
 		config.FadeIn = in
 	}
-	if cmd.Flag("fade-out").Changed {
+	if changed(cmd, "fade-out") {
 		config.FadeOut = out
 	}
 
 	return config.Validate()
 }
 
-func start(filename string, c *config.Config) error {
+// changed reports whether a flag was set, commands that don't define the flag never set it
+func changed(cmd *cobra.Command, name string) bool {
+	f := cmd.Flag(name)
+	return f != nil && f.Changed
+}
+
+func start(filename string, opts *options, c *config.Config) error {
+	// when streaming to stdout, everything meant for the terminal goes to stderr
+	var terminal io.Writer = os.Stdout
+	if opts.output == outputStdout {
+		terminal = os.Stderr
+	}
+
 	logger := log.NewLogger(5)
 	ctl, err := control.NewControl(logger, c)
 	if err != nil {
@@ -116,7 +212,7 @@ func start(filename string, c *config.Config) error {
 	defer func() {
 		err := loader.Close()
 		if err != nil {
-			fmt.Printf("failed to close loader: %v", err)
+			fmt.Fprintf(terminal, "failed to close loader: %v", err)
 		}
 	}()
 
@@ -125,34 +221,75 @@ func start(filename string, c *config.Config) error {
 		return err
 	}
 
-	audioCtx, err := audio.NewContext(int(c.SampleRate), ctl.ReadSample)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		runtime.KeepAlive(audioCtx)
-	}()
+	streamErr := make(chan error, 1)
+	stopStream := make(chan struct{})
 
-	state, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		return fmt.Errorf("failed to initialize raw terminal: %w", err)
+	switch opts.output {
+	case outputStdout:
+		// a reader that goes away must not kill the process, so that the terminal can be restored
+		signal.Ignore(syscall.SIGPIPE)
+		go func() {
+			streamErr <- pcm.Stream(os.Stdout, opts.format, c.SampleRate, ctl.ReadSample, opts.realtime, stopStream)
+		}()
+		defer func() {
+			close(stopStream)
+			if err := <-streamErr; err != nil && !errors.Is(err, syscall.EPIPE) {
+				fmt.Fprintf(terminal, "failed to write to stdout: %v", err)
+			}
+		}()
+	default:
+		audioCtx, err := audio.NewContext(int(c.SampleRate), ctl.ReadSample)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			runtime.KeepAlive(audioCtx)
+		}()
 	}
-	defer func() {
-		if err := term.Restore(int(os.Stdin.Fd()), state); err != nil {
-			fmt.Printf("failed to restore terminal state: %v", err)
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to initialize raw terminal: %w", err)
 		}
-	}()
+		defer func() {
+			if err := term.Restore(int(os.Stdin.Fd()), state); err != nil {
+				fmt.Fprintf(terminal, "failed to restore terminal state: %v", err)
+			}
+		}()
+	} else if opts.output != outputStdout {
+		return fmt.Errorf("failed to initialize raw terminal: stdin is not a terminal")
+	}
 
 	signalChan := make(chan ui.Signal)
 	uiConfig := ui.Config{
 		Logger:     logger,
 		File:       filename,
 		SignalChan: signalChan,
+		Recordable: opts.record != "",
+		Output:     terminal,
 	}
 
 	u := ui.NewUI(uiConfig)
 	go u.Enter()
 
+	// without a terminal, e.g. when stdin is piped, only signals can stop the synth, which must still shut down properly to save the recording
+	osSignals := make(chan os.Signal, 1)
+	signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(osSignals)
+	go func() {
+		for range osSignals {
+			signalChan <- ui.SignalInterrupt
+		}
+	}()
+
+	if opts.record != "" {
+		ctl.SetRecordFile(opts.record)
+		if err := ctl.StartRecording(); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+
 	done := make(chan bool)
 	var fadingOut bool
 
@@ -177,12 +314,29 @@ Loop:
 				go ctl.Stop(done, true)
 			}
 
+			if signal == ui.SignalRecord {
+				if err := ctl.ToggleRecording(); err != nil {
+					logger.Error(err.Error())
+				}
+			}
+
+		case err := <-streamErr:
+			// the reader went away, so there is no point in fading out
+			streamErr <- err
+			break Loop
+
 		case <-done:
 			break Loop
 		}
 	}
 
-	time.Sleep(200 * time.Millisecond) // avoid clipping at the end
-	ui.LineBreaks(2)
+	if err := ctl.StopRecording(); err != nil {
+		logger.Error(err.Error())
+	}
+
+	if opts.output != outputStdout {
+		time.Sleep(200 * time.Millisecond) // avoid clipping at the end
+	}
+	ui.LineBreaks(terminal, 2)
 	return nil
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iljarotar/synth/config"
+	"github.com/iljarotar/synth/file"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Check a patch for problems without playing it",
+	Long: `Check a patch for problems without playing it.
+
+Each problem is printed with the line and column it refers to. Values that are out of range are reported as warnings,
+as they are limited rather than rejected. The command exits with a non-zero status if any errors are found.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename, c, err := prepare(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		diagnostics, err := file.Validate(filename, float64(c.SampleRate))
+		if err != nil {
+			return err
+		}
+
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+
+		if file.HasErrors(diagnostics) {
+			return fmt.Errorf("%s is not a valid patch", filename)
+		}
+		if len(diagnostics) == 0 {
+			fmt.Printf("%s is valid\n", filename)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	defaultConfigPath, err := config.GetDefaultConfigPath()
+	if err != nil {
+		os.Exit(1)
+	}
+	validateCmd.Flags().IntP("sample-rate", "s", config.DefaultSampleRate, "sample rate")
+	validateCmd.Flags().StringP("config", "c", defaultConfigPath, "path to your config file")
+
+	rootCmd.AddCommand(validateCmd)
+}
@@ -1,6 +1,11 @@
 package control
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
 	"github.com/iljarotar/synth/config"
 	"github.com/iljarotar/synth/log"
 	"github.com/iljarotar/synth/synth"
@@ -11,6 +16,11 @@ type control struct {
 	config    *config.Config
 	synth     *synth.Synth
 	maxOutput float64
+
+	recordFile string
+	takes      int
+	mu         sync.Mutex
+	recorder   *recorder
 }
 
 func NewControl(logger *log.Logger, c *config.Config) (*control, error) {
@@ -29,6 +39,22 @@ func (c *control) ReadSample() [2]float64 {
 	sample[0] = o.Left
 	sample[1] = o.Right
 
+	c.mu.Lock()
+	if c.recorder != nil {
+		c.recorder.record(sample)
+		// a full recording is saved right away, the next take starts a new file
+		if c.recorder.full.Load() {
+			r := c.recorder
+			c.recorder = nil
+			go func() {
+				if err := c.saveRecording(r); err != nil {
+					c.logger.Error(err.Error())
+				}
+			}()
+		}
+	}
+	c.mu.Unlock()
+
 	c.logger.SendTime(o.Time)
 
 	return sample
@@ -61,10 +87,97 @@ func (c *control) LoadSynth(synth *synth.Synth) error {
 
 	if c.synth != nil {
 		c.maxOutput = 0
-		return c.synth.Update(synth)
+		err := c.synth.Update(synth)
+		if err != nil {
+			return err
+		}
+		c.logFeedback()
+		return nil
 	}
 
 	c.synth = synth
 	c.synth.FadeIn(c.config.FadeIn)
+	c.logFeedback()
 	return nil
 }
+
+func (c *control) logFeedback() {
+	for _, f := range c.synth.Feedback() {
+		c.logger.Info(fmt.Sprintf("feedback cycle: %s with a delay of one sample", f))
+	}
+}
+
+// SetRecordFile sets the file that recordings are written to
+// every further take is written to a numbered file next to it
+func (c *control) SetRecordFile(file string) {
+	c.recordFile = file
+}
+
+func (c *control) ToggleRecording() error {
+	c.mu.Lock()
+	recording := c.recorder != nil
+	c.mu.Unlock()
+
+	if recording {
+		return c.StopRecording()
+	}
+	return c.StartRecording()
+}
+
+func (c *control) StartRecording() error {
+	if c.recordFile == "" {
+		return fmt.Errorf("no file to record to - pass one with the --record flag")
+	}
+
+	c.takes++
+	file := takeFile(c.recordFile, c.takes)
+	r, err := newRecorder(file, c.config.SampleRate)
+	if err != nil {
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	c.mu.Lock()
+	c.recorder = r
+	c.mu.Unlock()
+
+	c.logger.Info(fmt.Sprintf("recording to %s", file))
+	return nil
+}
+
+// StopRecording finalizes the current recording, if there is one
+func (c *control) StopRecording() error {
+	c.mu.Lock()
+	r := c.recorder
+	c.recorder = nil
+	c.mu.Unlock()
+
+	if r == nil {
+		return nil
+	}
+	return c.saveRecording(r)
+}
+
+// saveRecording waits until all frames of a recording are written and reports how it went
+func (c *control) saveRecording(r *recorder) error {
+	err := r.stop()
+	if err != nil {
+		return fmt.Errorf("failed to save recording %s: %w", r.file, err)
+	}
+
+	if r.full.Load() {
+		c.logger.Warning(fmt.Sprintf("stopped recording %s at the maximum size of a wav file", r.file))
+	}
+	if r.dropped > 0 {
+		c.logger.Warning(fmt.Sprintf("dropped %d frames while recording, disk was too slow", r.dropped))
+	}
+	c.logger.Info(fmt.Sprintf("saved recording %s", r.file))
+	return nil
+}
+
+func takeFile(file string, take int) string {
+	if take <= 1 {
+		return file
+	}
+	ext := filepath.Ext(file)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(file, ext), take, ext)
+}
@@ -0,0 +1,166 @@
+package control
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iljarotar/synth/config"
+	"github.com/iljarotar/synth/log"
+)
+
+func Test_takeFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		take int
+		want string
+	}{
+		{
+			name: "first take",
+			file: "rec.wav",
+			take: 1,
+			want: "rec.wav",
+		},
+		{
+			name: "second take",
+			file: "rec.wav",
+			take: 2,
+			want: "rec-2.wav",
+		},
+		{
+			name: "third take in a directory",
+			file: "out/rec.wav",
+			take: 3,
+			want: "out/rec-3.wav",
+		},
+		{
+			name: "no extension",
+			file: "rec",
+			take: 2,
+			want: "rec-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := takeFile(tt.file, tt.take); got != tt.want {
+				t.Errorf("takeFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestControl(t *testing.T) (*control, chan string) {
+	t.Helper()
+	logs := make(chan string, 100)
+	logger := log.NewLogger(10)
+	logger.SubscribeToLogs(logs)
+
+	c, err := NewControl(logger, &config.Config{SampleRate: 100})
+	if err != nil {
+		t.Fatalf("NewControl() error = %v", err)
+	}
+	return c, logs
+}
+
+func TestControl_recording(t *testing.T) {
+	c, _ := newTestControl(t)
+	dir := t.TempDir()
+	c.SetRecordFile(filepath.Join(dir, "rec.wav"))
+
+	frames := []int{3, 1, 2}
+	for _, n := range frames {
+		if err := c.ToggleRecording(); err != nil {
+			t.Fatalf("control.ToggleRecording() error = %v", err)
+		}
+		for range n {
+			c.recorder.record([2]float64{0.5, -0.5})
+		}
+		if err := c.ToggleRecording(); err != nil {
+			t.Fatalf("control.ToggleRecording() error = %v", err)
+		}
+	}
+
+	for i, file := range []string{"rec.wav", "rec-2.wav", "rec-3.wav"} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("failed to read take %d: %v", i+1, err)
+		}
+
+		// 32 bit float files have a 58 byte header
+		if got := binary.LittleEndian.Uint32(data[4:]); int(got) != len(data)-8 {
+			t.Errorf("%s: riff size = %v, want %v", file, got, len(data)-8)
+		}
+		wantDataSize := frames[i] * recordFormat.BytesPerFrame()
+		if got := binary.LittleEndian.Uint32(data[54:]); int(got) != wantDataSize {
+			t.Errorf("%s: data size = %v, want %v", file, got, wantDataSize)
+		}
+		if len(data) != 58+wantDataSize {
+			t.Errorf("%s: file size = %v, want %v", file, len(data), 58+wantDataSize)
+		}
+	}
+}
+
+func TestControl_StartRecording_noFile(t *testing.T) {
+	c, _ := newTestControl(t)
+	if err := c.StartRecording(); err == nil {
+		t.Errorf("control.StartRecording() expected an error without a record file")
+	}
+}
+
+func Test_recorder_record(t *testing.T) {
+	r := &recorder{frames: make(chan [2]float64, 2)}
+	for range 5 {
+		r.record([2]float64{})
+	}
+	if r.dropped != 3 {
+		t.Errorf("recorder.dropped = %v, want 3", r.dropped)
+	}
+}
+
+func TestControl_StopRecording_dropped(t *testing.T) {
+	c, logs := newTestControl(t)
+	c.SetRecordFile(filepath.Join(t.TempDir(), "rec.wav"))
+
+	if err := c.StartRecording(); err != nil {
+		t.Fatalf("control.StartRecording() error = %v", err)
+	}
+	c.recorder.dropped = 42
+	if err := c.StopRecording(); err != nil {
+		t.Fatalf("control.StopRecording() error = %v", err)
+	}
+
+	if !logged(logs, "dropped 42 frames") {
+		t.Errorf("control.StopRecording() didn't report the dropped frames")
+	}
+}
+
+func TestControl_StopRecording_full(t *testing.T) {
+	c, logs := newTestControl(t)
+	c.SetRecordFile(filepath.Join(t.TempDir(), "rec.wav"))
+
+	if err := c.StartRecording(); err != nil {
+		t.Fatalf("control.StartRecording() error = %v", err)
+	}
+	c.recorder.full.Store(true)
+	if err := c.StopRecording(); err != nil {
+		t.Fatalf("control.StopRecording() error = %v", err)
+	}
+
+	if !logged(logs, "at the maximum size of a wav file") {
+		t.Errorf("control.StopRecording() didn't report that the recording was full")
+	}
+}
+
+// logged reports whether any of the logs sent so far contains msg
+func logged(logs chan string, msg string) bool {
+	close(logs)
+	for l := range logs {
+		if strings.Contains(l, msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package control
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"github.com/iljarotar/synth/pcm"
+	"github.com/iljarotar/synth/wav"
+)
+
+const (
+	recordFormat = pcm.FormatF32LE
+
+	// number of seconds the recorder can fall behind before frames are dropped
+	recordBufferSeconds = 5
+)
+
+type recorder struct {
+	file    string
+	frames  chan [2]float64
+	done    chan error
+	dropped int
+	// full is set once the file has reached the maximum size of a wav file, later frames are discarded
+	full atomic.Bool
+}
+
+// newRecorder creates the file and starts writing frames on a background goroutine
+func newRecorder(file string, sampleRate int) (*recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := wav.NewWriter(f, sampleRate, recordFormat)
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+
+	r := &recorder{
+		file:   file,
+		frames: make(chan [2]float64, sampleRate*recordBufferSeconds),
+		done:   make(chan error),
+	}
+	go r.write(f, w)
+
+	return r, nil
+}
+
+// record never blocks, if the buffer is full the frame is dropped
+func (r *recorder) record(frame [2]float64) {
+	select {
+	case r.frames <- frame:
+	default:
+		r.dropped++
+	}
+}
+
+// stop waits until all buffered frames are written and the file is finalized
+// record must not be called after stop
+func (r *recorder) stop() error {
+	close(r.frames)
+	return <-r.done
+}
+
+func (r *recorder) write(f *os.File, w *wav.Writer) {
+	var err error
+	for frame := range r.frames {
+		if err != nil || r.full.Load() {
+			continue
+		}
+		err = w.WriteFrame(frame)
+		if errors.Is(err, wav.ErrTooLarge) {
+			r.full.Store(true)
+			err = nil
+		}
+	}
+
+	if err == nil {
+		err = w.Close()
+	}
+	r.done <- errors.Join(err, f.Close())
+}
@@ -1,9 +1,13 @@
 package file
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/iljarotar/synth/log"
@@ -19,9 +23,16 @@ type (
 
 		watcher *fsnotify.Watcher
 		active  bool
+
+		mu sync.Mutex
+		// files holds the absolute paths of the patch file and all files it includes
+		files []string
 	}
 
 	callbackFunc func(*s.Synth) error
+
+	// decodeFunc decodes the contents of a single patch file without resolving its includes
+	decodeFunc func(file string, data []byte) (*s.Synth, error)
 )
 
 func NewLoader(logger *log.Logger, filename string, callback callbackFunc) (*Loader, error) {
@@ -56,18 +67,22 @@ func (l *Loader) LoadAndWatch() error {
 		return err
 	}
 
-	data, err := os.ReadFile(l.file)
+	synth, files, err := load(l.file, decode)
 	if err != nil {
 		return err
 	}
 
-	var synth s.Synth
-	err = yaml.Unmarshal(data, &synth)
-	if err != nil {
-		return err
+	for _, f := range files {
+		err := l.Watch(f)
+		if err != nil {
+			return err
+		}
 	}
+	l.mu.Lock()
+	l.files = files
+	l.mu.Unlock()
 
-	err = l.callback(&synth)
+	err = l.callback(synth)
 	if err != nil {
 		return err
 	}
@@ -75,6 +90,99 @@ func (l *Loader) LoadAndWatch() error {
 	return nil
 }
 
+// Load reads a patch file and all files it includes without watching them
+func Load(file string) (*s.Synth, error) {
+	synth, _, err := load(file, decode)
+	return synth, err
+}
+
+func decode(_ string, data []byte) (*s.Synth, error) {
+	var synth s.Synth
+	err := yaml.Unmarshal(data, &synth)
+	if err != nil {
+		return nil, err
+	}
+	return &synth, nil
+}
+
+// load decodes a patch file and merges the modules of all included files into it
+// include paths are relative to the including file, a file that is included more than once is only merged once
+// it returns the absolute paths of all loaded files
+func load(file string, decode decodeFunc) (*s.Synth, []string, error) {
+	var (
+		files []string
+		// origins maps module names to the file they are defined in
+		origins = map[string]string{}
+	)
+
+	var loadFile func(file string, parents []string) (*s.Synth, error)
+	loadFile = func(file string, parents []string) (*s.Synth, error) {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(parents, path) {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(parents, path), " -> "))
+		}
+		files = append(files, path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		synth, err := decode(file, data)
+		if err != nil {
+			return nil, err
+		}
+		synth.ResolvePaths(filepath.Dir(path))
+		for _, name := range synth.Names() {
+			if _, ok := origins[name]; !ok {
+				origins[name] = file
+			}
+		}
+
+		ancestors := append(parents, path)
+		for _, include := range synth.Include {
+			includePath := include
+			if !filepath.IsAbs(includePath) {
+				includePath = filepath.Join(filepath.Dir(path), include)
+			}
+			includePath = filepath.Clean(includePath)
+			if slices.Contains(files, includePath) && !slices.Contains(ancestors, includePath) {
+				continue
+			}
+
+			included, err := loadFile(includePath, ancestors)
+			if err != nil {
+				return nil, fmt.Errorf("failed to include %s: %w", include, err)
+			}
+
+			err = synth.Merge(included)
+			var mergeErr *s.MergeError
+			if errors.As(err, &mergeErr) {
+				var conflicts []string
+				for _, name := range mergeErr.Names {
+					conflicts = append(conflicts, fmt.Sprintf("module %s is defined in %s and %s", name, origins[name], includePath))
+				}
+				return nil, fmt.Errorf("failed to include %s: %s", include, strings.Join(conflicts, "; "))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to include %s: %w", include, err)
+			}
+		}
+
+		return synth, nil
+	}
+
+	synth, err := loadFile(file, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return synth, files, nil
+}
+
 func (l *Loader) Watch(file string) error {
 	absolutePath, err := filepath.Abs(file)
 	if err != nil {
@@ -89,7 +197,7 @@ func (l *Loader) StartWatching() {
 	for l.active {
 		select {
 		case event := <-l.watcher.Events:
-			if event.Op&fsnotify.Write == fsnotify.Write {
+			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 && l.isLoaded(event.Name) {
 				err := l.LoadAndWatch()
 				if err != nil {
 					l.logger.Error(fmt.Sprintf("failed to load file:%v", err))
@@ -103,3 +211,15 @@ func (l *Loader) StartWatching() {
 		}
 	}
 }
+
+// isLoaded reports whether file is the patch file or one of its includes
+func (l *Loader) isLoaded(file string) bool {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.files, path)
+}
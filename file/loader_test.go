@@ -0,0 +1,156 @@
+package file
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_load(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantNames []string
+		wantFiles []string
+		wantErr   string
+	}{
+		{
+			name: "includes are relative to the including file",
+			files: map[string]string{
+				"patch.yaml": "include: [sub/osc.yaml]\nout: osc\n",
+				"sub/osc.yaml": `include: [lfo.yaml]
+oscillators:
+  osc:
+    type: Sine
+    cv: lfo
+`,
+				"sub/lfo.yaml": "oscillators:\n  lfo:\n    type: Sine\n",
+			},
+			wantNames: []string{"lfo", "osc"},
+			wantFiles: []string{"patch.yaml", "sub/osc.yaml", "sub/lfo.yaml"},
+		},
+		{
+			name: "a file included more than once is merged once",
+			files: map[string]string{
+				"patch.yaml": "include: [left.yaml, right.yaml]\nout: main\n",
+				"left.yaml":  "include: [osc.yaml]\nmixers:\n  left:\n    in:\n      osc: 1\n",
+				"right.yaml": "include: [osc.yaml]\nmixers:\n  right:\n    in:\n      osc: 1\n",
+				"osc.yaml":   "oscillators:\n  osc:\n    type: Sine\n",
+			},
+			wantNames: []string{"left", "osc", "right"},
+			wantFiles: []string{"patch.yaml", "left.yaml", "osc.yaml", "right.yaml"},
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"patch.yaml": "include: [a.yaml]\n",
+				"a.yaml":     "include: [b.yaml]\n",
+				"b.yaml":     "include: [a.yaml]\n",
+			},
+			wantErr: "include cycle: {dir}/patch.yaml -> {dir}/a.yaml -> {dir}/b.yaml -> {dir}/a.yaml",
+		},
+		{
+			name: "patch file includes itself",
+			files: map[string]string{
+				"patch.yaml": "include: [patch.yaml]\n",
+			},
+			wantErr: "include cycle: {dir}/patch.yaml -> {dir}/patch.yaml",
+		},
+		{
+			name: "module defined in two files",
+			files: map[string]string{
+				"patch.yaml": "include: [osc.yaml]\noscillators:\n  osc:\n    type: Sine\n",
+				"osc.yaml":   "oscillators:\n  osc:\n    type: Square\n",
+			},
+			wantErr: "failed to include osc.yaml: module osc is defined in {dir}/patch.yaml and {dir}/osc.yaml",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"patch.yaml": "include: [missing.yaml]\n",
+			},
+			wantErr: "failed to include missing.yaml: open {dir}/missing.yaml: no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+
+			synth, files, err := load(filepath.Join(dir, "patch.yaml"), decode)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("load() error = nil, want %q", tt.wantErr)
+				}
+				// the message of a cycle is nested in the message of each include
+				if want := strings.ReplaceAll(tt.wantErr, "{dir}", dir); !strings.HasSuffix(err.Error(), want) {
+					t.Errorf("load() error = %q, want suffix %q", err, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.wantNames, synth.Names()); diff != "" {
+				t.Errorf("load() names diff = %s", diff)
+			}
+			var wantFiles []string
+			for _, f := range tt.wantFiles {
+				wantFiles = append(wantFiles, filepath.Join(dir, f))
+			}
+			if diff := cmp.Diff(wantFiles, files); diff != "" {
+				t.Errorf("load() files diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestLoader_isLoaded(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"patch.yaml": "include: [osc.yaml]\nout: osc\n",
+		"osc.yaml":   "oscillators:\n  osc:\n    type: Sine\n",
+		"other.yaml": "oscillators:\n  other:\n    type: Sine\n",
+	})
+
+	_, files, err := load(filepath.Join(dir, "patch.yaml"), decode)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	l := &Loader{files: files}
+
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{
+			name: "patch file",
+			file: filepath.Join(dir, "patch.yaml"),
+			want: true,
+		},
+		{
+			name: "included file",
+			file: filepath.Join(dir, "osc.yaml"),
+			want: true,
+		},
+		{
+			name: "unclean path of an included file",
+			file: filepath.Join(dir, "sub") + "/../osc.yaml",
+			want: true,
+		},
+		{
+			name: "other file in the same directory",
+			file: filepath.Join(dir, "other.yaml"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.isLoaded(tt.file); got != tt.want {
+				t.Errorf("Loader.isLoaded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iljarotar/synth/module"
+	s "github.com/iljarotar/synth/synth"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type (
+	// Diagnostic is a problem in a patch file, Line and Column are 0 if the position is unknown
+	Diagnostic struct {
+		File     string
+		Line     int
+		Column   int
+		Severity string
+		Message  string
+	}
+
+	location struct {
+		file         string
+		line, column int
+	}
+
+	validator struct {
+		diagnostics []Diagnostic
+		// locations maps paths in the merged patch to the position in the file they are defined in
+		locations map[string]location
+		// instances maps the names of instances to their templates, so that modules like lead.osc can be located in the template
+		instances map[string]string
+	}
+)
+
+var (
+	lineRegex = regexp.MustCompile(`line (\d+): `)
+
+	// errInvalid is returned while decoding a file whose problems have already been reported as diagnostics
+	errInvalid = errors.New("invalid patch file")
+
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+	// shapes holds the types that types with custom decoding are decoded like if they are given as a mapping
+	shapes = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(s.Template{}): reflect.TypeOf(struct {
+			s.Synth `yaml:",inline"`
+			Params  map[string]any `yaml:"params"`
+		}{}),
+		reflect.TypeOf(module.SequencerStep{}): reflect.TypeOf(module.SequencerStep{}),
+		reflect.TypeOf(module.Reverb{}):        reflect.TypeOf(module.Reverb{}),
+	}
+)
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// Validate reports all problems of a patch file and the files it includes without playing it
+// the error is only returned if the patch file can't be read
+func Validate(file string, sampleRate float64) ([]Diagnostic, error) {
+	_, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{
+		locations: map[string]location{},
+		instances: map[string]string{},
+	}
+
+	synth, _, err := load(file, v.decode)
+	if errors.Is(err, errInvalid) {
+		return sortDiagnostics(v.diagnostics), nil
+	}
+	if err != nil {
+		loc := v.lookup(file, "include")
+		v.diagnostics = append(v.diagnostics, Diagnostic{
+			File:     loc.file,
+			Line:     loc.line,
+			Column:   loc.column,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		})
+		return sortDiagnostics(v.diagnostics), nil
+	}
+
+	for name, instance := range synth.Instances {
+		if instance != nil {
+			v.instances[name] = instance.Template
+		}
+	}
+
+	for _, p := range synth.Check(sampleRate) {
+		severity := SeverityError
+		if p.Warning {
+			severity = SeverityWarning
+		}
+		loc := v.lookup(file, p.Path)
+		v.diagnostics = append(v.diagnostics, Diagnostic{
+			File:     loc.file,
+			Line:     loc.line,
+			Column:   loc.column,
+			Severity: severity,
+			Message:  p.String(),
+		})
+	}
+
+	return sortDiagnostics(v.diagnostics), nil
+}
+
+// decode reports syntax errors, unknown fields and type errors of a single file
+func (v *validator) decode(file string, data []byte) (*s.Synth, error) {
+	var root yamlv3.Node
+	err := yamlv3.Unmarshal(data, &root)
+	if err != nil {
+		v.diagnostics = append(v.diagnostics, errorDiagnostic(file, err))
+		return nil, errInvalid
+	}
+
+	if len(root.Content) > 0 {
+		v.walk(file, root.Content[0], reflect.TypeOf(s.Synth{}), "")
+	}
+
+	synth, err := decode(file, data)
+	if err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			v.diagnostics = append(v.diagnostics, errorDiagnostic(file, err))
+			return nil, errInvalid
+		}
+		for _, msg := range typeErr.Errors {
+			v.diagnostics = append(v.diagnostics, errorDiagnostic(file, errors.New(msg)))
+		}
+		return nil, errInvalid
+	}
+
+	return synth, nil
+}
+
+// HasErrors reports whether any of the diagnostics is an error rather than a warning
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// errorDiagnostic takes the position from yaml error messages like "yaml: line 3: did not find expected key"
+func errorDiagnostic(file string, err error) Diagnostic {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	d := Diagnostic{
+		File:     file,
+		Severity: SeverityError,
+		Message:  msg,
+	}
+
+	if m := lineRegex.FindStringSubmatch(msg); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Column = 1
+		d.Message = strings.Replace(msg, m[0], "", 1)
+	}
+
+	return d
+}
+
+// walk compares the document with the fields of t, reports unknown keys and records the location of each path
+// if a path is defined in several files, the location of the first one is kept
+func (v *validator) walk(file string, node *yamlv3.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// types with custom decoding are checked by the fields they are decoded into if they are given as a mapping
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		shape, ok := shapes[t]
+		if !ok || node.Kind != yamlv3.MappingNode {
+			return
+		}
+		t = shape
+	}
+
+	switch {
+	case node.Kind == yamlv3.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			p := join(path, key.Value)
+			v.locate(p, file, key)
+			v.walk(file, value, t.Elem(), p)
+		}
+
+	case node.Kind == yamlv3.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			p := join(path, key.Value)
+			v.locate(p, file, key)
+
+			field, ok := fields[key.Value]
+			if !ok {
+				v.diagnostics = append(v.diagnostics, Diagnostic{
+					File:     file,
+					Line:     key.Line,
+					Column:   key.Column,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s: unknown field", p),
+				})
+				continue
+			}
+			v.walk(file, value, field, p)
+		}
+
+	case node.Kind == yamlv3.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for i, item := range node.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			v.locate(p, file, item)
+			v.walk(file, item, t.Elem(), p)
+		}
+	}
+}
+
+func (v *validator) locate(path, file string, node *yamlv3.Node) {
+	if _, ok := v.locations[path]; !ok {
+		v.locations[path] = location{file: file, line: node.Line, column: node.Column}
+	}
+}
+
+// yamlFields maps the keys of a struct to the types of its fields the same way yaml.v2 does
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+// lookup returns the location of the longest prefix of path that is defined in any file
+// modules of expanded instances like lead.osc are located in the body of their template or else at the instance
+// paths that aren't defined at all are attributed to the patch file without a position
+func (v *validator) lookup(file, path string) location {
+	if loc, ok := v.lookupInstance(path); ok {
+		return loc
+	}
+
+	for path != "" {
+		if loc, ok := v.locations[path]; ok {
+			return loc
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return location{file: file}
+}
+
+// lookupInstance locates a path like oscillators.lead.osc.freq in the template of the instance lead
+func (v *validator) lookupInstance(path string) (location, bool) {
+	section, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return location{}, false
+	}
+	if _, ok := v.locations[path]; ok {
+		return location{}, false
+	}
+
+	for instance, template := range v.instances {
+		name, found := strings.CutPrefix(rest, instance+".")
+		if !found {
+			continue
+		}
+
+		// the longest prefix in the body must at least contain the module
+		p := join(fmt.Sprintf("templates.%s.%s", template, section), name)
+		for p != "" {
+			if loc, ok := v.locations[p]; ok && strings.Count(p, ".") >= 3 {
+				return loc, true
+			}
+			i := strings.LastIndex(p, ".")
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+
+		if loc, ok := v.locations["instances."+instance]; ok {
+			return loc, true
+		}
+	}
+
+	return location{}, false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics
+}
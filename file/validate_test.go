@@ -0,0 +1,311 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writeFiles writes files relative to a temporary directory and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create the directory of %s: %v", name, err)
+		}
+		err := os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Diagnostic
+	}{
+		{
+			name: "valid patch",
+			files: map[string]string{
+				"patch.yaml": `vol: 1
+out: osc
+oscillators:
+  osc:
+    type: Sine
+    freq: 440
+`,
+			},
+			want: nil,
+		},
+		{
+			name: "syntax error",
+			files: map[string]string{
+				"patch.yaml": `vol: 1
+out: osc
+oscillators:
+  osc:
+    type: Sine
+    freq: 440: 1
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 6, Column: 1, Severity: SeverityError, Message: "mapping values are not allowed in this context"},
+			},
+		},
+		{
+			name: "unknown fields",
+			files: map[string]string{
+				"patch.yaml": `out: osc
+oscillators:
+  osc:
+    type: Sine
+    frq: 440
+volume: 1
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 5, Column: 5, Severity: SeverityError, Message: "oscillators.osc.frq: unknown field"},
+				{File: "patch.yaml", Line: 6, Column: 1, Severity: SeverityError, Message: "volume: unknown field"},
+			},
+		},
+		{
+			name: "unknown field in a template",
+			files: map[string]string{
+				"patch.yaml": `out: lead.osc
+templates:
+  voice:
+    params:
+      freq: 440
+    oscillators:
+      osc:
+        type: Sine
+        frq: ${freq}
+instances:
+  lead:
+    template: voice
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 9, Column: 9, Severity: SeverityError, Message: "templates.voice.oscillators.osc.frq: unknown field"},
+			},
+		},
+		{
+			name: "unknown field in a sequencer step",
+			files: map[string]string{
+				"patch.yaml": `out: seq
+sequencers:
+  seq:
+    sequence:
+      - a_4
+      - note: e_2
+        velo: 0.8
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 7, Column: 9, Severity: SeverityError, Message: "sequencers.seq.sequence[1].velo: unknown field"},
+			},
+		},
+		{
+			name: "problems of each sequencer step",
+			files: map[string]string{
+				"patch.yaml": `out: seq
+sequencers:
+  seq:
+    sequence:
+      - a_4 v2
+      - h_4
+      - a_4
+      - note: a_4 vx
+      - {note: a_4, ratchet: 100}
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 5, Column: 9, Severity: SeverityWarning, Message: "sequencers.seq.sequence[0].vel: value 2 is out of range and will be limited to 1"},
+				{File: "patch.yaml", Line: 6, Column: 9, Severity: SeverityError, Message: "sequencers.seq.sequence[1]: unknown note h"},
+				{File: "patch.yaml", Line: 8, Column: 9, Severity: SeverityError, Message: `sequencers.seq.sequence[3]: invalid modifier "vx" in step "a_4 vx"`},
+				{File: "patch.yaml", Line: 9, Column: 21, Severity: SeverityWarning, Message: "sequencers.seq.sequence[4].ratchet: value 100 is out of range and will be limited to 16"},
+			},
+		},
+		{
+			name: "unknown field in a reverb",
+			files: map[string]string{
+				"patch.yaml": `out: verb
+reverbs:
+  verb:
+    size: 0.5
+    wdth: 0
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 5, Column: 5, Severity: SeverityError, Message: "reverbs.verb.wdth: unknown field"},
+			},
+		},
+		{
+			name: "problems are attributed to the included file",
+			files: map[string]string{
+				"patch.yaml": `include: [osc.yaml]
+out: osc
+`,
+				"osc.yaml": `oscillators:
+  osc:
+    type: Sine
+    freq: 30000
+`,
+			},
+			want: []Diagnostic{
+				{File: "osc.yaml", Line: 4, Column: 5, Severity: SeverityWarning, Message: "oscillators.osc.freq: value 30000 is out of range and will be limited to 20000"},
+			},
+		},
+		{
+			name: "modules of instances are located in their template",
+			files: map[string]string{
+				"patch.yaml": `out: lead.osc
+templates:
+  voice:
+    oscillators:
+      osc:
+        type: Sin
+        freq: 30000
+instances:
+  lead:
+    template: voice
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 6, Column: 9, Severity: SeverityError, Message: "oscillators.lead.osc.type: unknown oscillator type Sin"},
+				{File: "patch.yaml", Line: 7, Column: 9, Severity: SeverityWarning, Message: "oscillators.lead.osc.freq: value 30000 is out of range and will be limited to 20000"},
+			},
+		},
+		{
+			name: "params of instances are located at their placeholder",
+			files: map[string]string{
+				"patch.yaml": `out: main
+templates:
+  voice:
+    params:
+      freq: 440
+    oscillators:
+      osc:
+        type: Sine
+        freq: ${freq}
+instances:
+  lead:
+    template: voice
+    params:
+      freq: 30000
+mixers:
+  main:
+    in:
+      lead.osc: 1
+      lead.lfo: 1
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 9, Column: 9, Severity: SeverityWarning, Message: "oscillators.lead.osc.freq: value 30000 is out of range and will be limited to 20000"},
+				{File: "patch.yaml", Line: 19, Column: 7, Severity: SeverityError, Message: `mixers.main.in.lead.lfo: unknown module "lead.lfo"`},
+			},
+		},
+		{
+			name: "modules of nested instances are located at the instance",
+			files: map[string]string{
+				"patch.yaml": `out: lead.sub.osc
+templates:
+  inner:
+    oscillators:
+      osc:
+        type: Sin
+  voice:
+    instances:
+      sub:
+        template: inner
+instances:
+  lead:
+    template: voice
+`,
+			},
+			want: []Diagnostic{
+				{File: "patch.yaml", Line: 12, Column: 3, Severity: SeverityError, Message: "oscillators.lead.sub.osc.type: unknown oscillator type Sin"},
+			},
+		},
+		{
+			name: "ordering",
+			files: map[string]string{
+				"patch.yaml": `include: [b.yaml, a.yaml]
+out: main
+mixers:
+  main:
+    gain: 2
+    in:
+      missing: 1
+`,
+				"a.yaml": `oscillators:
+  a:
+    type: Sine
+    freq: 30000
+`,
+				"b.yaml": `oscillators:
+  b:
+    type: Sine
+    freq: 30000
+`,
+			},
+			want: []Diagnostic{
+				{File: "a.yaml", Line: 4, Column: 5, Severity: SeverityWarning, Message: "oscillators.a.freq: value 30000 is out of range and will be limited to 20000"},
+				{File: "b.yaml", Line: 4, Column: 5, Severity: SeverityWarning, Message: "oscillators.b.freq: value 30000 is out of range and will be limited to 20000"},
+				{File: "patch.yaml", Line: 5, Column: 5, Severity: SeverityWarning, Message: "mixers.main.gain: value 2 is out of range and will be limited to 1"},
+				{File: "patch.yaml", Line: 7, Column: 7, Severity: SeverityError, Message: `mixers.main.in.missing: unknown module "missing"`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+
+			got, err := Validate(filepath.Join(dir, "patch.yaml"), 44100)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			for i := range got {
+				got[i].File, _ = filepath.Rel(dir, got[i].File)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Validate() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func Test_errorDiagnostic(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want Diagnostic
+	}{
+		{
+			name: "position",
+			msg:  "yaml: line 3: did not find expected key",
+			want: Diagnostic{File: "patch.yaml", Line: 3, Column: 1, Severity: SeverityError, Message: "did not find expected key"},
+		},
+		{
+			name: "no position",
+			msg:  "yaml: control characters are not allowed",
+			want: Diagnostic{File: "patch.yaml", Severity: SeverityError, Message: "control characters are not allowed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorDiagnostic("patch.yaml", errors.New(tt.msg))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("errorDiagnostic() diff = %s", diff)
+			}
+		})
+	}
+}
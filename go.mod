@@ -24,4 +24,5 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0
 	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,286 @@
+package module
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Chorus mixes its input with a copy whose delay is swept by an lfo
+	// the delayed signal is read between samples, so the delay can be swept smoothly
+	Chorus struct {
+		Module
+		Type     chorusType `yaml:"type"`
+		Rate     float64    `yaml:"rate"`
+		Depth    float64    `yaml:"depth"`
+		Delay    float64    `yaml:"delay"`
+		Feedback float64    `yaml:"feedback"`
+		Spread   float64    `yaml:"spread"`
+		Mix      float64    `yaml:"mix"`
+		Mod      string     `yaml:"mod"`
+		In       string     `yaml:"in"`
+		Fade     float64    `yaml:"fade"`
+
+		sampleRate  float64
+		phase       float64
+		left, right *fractionalDelay
+
+		rateFader     *fader
+		depthFader    *fader
+		delayFader    *fader
+		feedbackFader *fader
+		mixFader      *fader
+	}
+
+	ChorusMap  map[string]*Chorus
+	chorusType string
+
+	// chorusPreset holds the default delay and depth in milliseconds and the default rate of a chorus type
+	chorusPreset struct {
+		delay, depth, rate float64
+	}
+
+	// fractionalDelay is a delay line that interpolates linearly between samples
+	fractionalDelay struct {
+		buf []float64
+		idx int
+	}
+)
+
+const (
+	chorusTypeChorus  chorusType = "Chorus"
+	chorusTypeFlanger chorusType = "Flanger"
+	chorusTypeVibrato chorusType = "Vibrato"
+
+	defaultChorusMix = 0.5
+)
+
+var chorusPresets = map[chorusType]chorusPreset{
+	chorusTypeChorus:  {delay: 20, depth: 5, rate: 0.8},
+	chorusTypeFlanger: {delay: 3, depth: 2, rate: 0.2},
+	chorusTypeVibrato: {delay: 5, depth: 1, rate: 5},
+}
+
+func (m ChorusMap) Initialize(sampleRate float64) error {
+	for name, c := range m {
+		if c == nil {
+			continue
+		}
+		if err := c.initialize(sampleRate); err != nil {
+			return fmt.Errorf("failed to initialize chorus %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Chorus) initialize(sampleRate float64) error {
+	// an unknown type has no defaults, the values are still limited so that all problems can be reported at once
+	preset, ok := chorusPresets[c.Type]
+
+	c.sampleRate = sampleRate
+	if c.Delay == 0 {
+		c.Delay = preset.delay
+	}
+	c.Delay = calc.Limit(c.Delay, chorusDelayRange)
+	if c.Depth == 0 {
+		c.Depth = preset.depth
+	}
+	c.Depth = calc.Limit(c.Depth, chorusDepthRange)
+	if c.Rate == 0 {
+		c.Rate = preset.rate
+	}
+	c.Rate = calc.Limit(c.Rate, lfoRange)
+	c.Feedback = calc.Limit(c.Feedback, feedbackRange)
+	c.Spread = calc.Limit(c.Spread, positionRange)
+	if c.Mix == 0 {
+		c.Mix = defaultChorusMix
+	}
+	c.Mix = calc.Limit(c.Mix, mixRange)
+	c.Fade = calc.Limit(c.Fade, fadeRange)
+
+	if !ok {
+		return &FieldError{Field: "type", Err: fmt.Errorf("unknown chorus type %s", c.Type)}
+	}
+
+	// the buffers are large enough for the longest delay, so they never have to be resized
+	length := int(math.Ceil((chorusDelayRange.Max+chorusDepthRange.Max)/1000*sampleRate)) + 2
+	c.left = &fractionalDelay{buf: make([]float64, length)}
+	c.right = &fractionalDelay{buf: make([]float64, length)}
+
+	c.rateFader = &fader{
+		current: c.Rate,
+		target:  c.Rate,
+	}
+	c.depthFader = &fader{
+		current: c.Depth,
+		target:  c.Depth,
+	}
+	c.delayFader = &fader{
+		current: c.Delay,
+		target:  c.Delay,
+	}
+	c.feedbackFader = &fader{
+		current: c.Feedback,
+		target:  c.Feedback,
+	}
+	c.mixFader = &fader{
+		current: c.Mix,
+		target:  c.Mix,
+	}
+	c.initializeFaders()
+
+	return nil
+}
+
+func (c *Chorus) Update(new *Chorus) {
+	if new == nil {
+		return
+	}
+
+	c.Type = new.Type
+	c.Spread = new.Spread
+	c.Mod = new.Mod
+	c.In = new.In
+	c.Fade = new.Fade
+
+	if c.rateFader != nil {
+		c.rateFader.target = new.Rate
+	}
+	if c.depthFader != nil {
+		c.depthFader.target = new.Depth
+	}
+	if c.delayFader != nil {
+		c.delayFader.target = new.Delay
+	}
+	if c.feedbackFader != nil {
+		c.feedbackFader.target = new.Feedback
+	}
+	if c.mixFader != nil {
+		c.mixFader.target = new.Mix
+	}
+	c.initializeFaders()
+}
+
+func (c *Chorus) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":  c.In,
+		"mod": c.Mod,
+	})
+}
+
+func (c *Chorus) RenameInputs(rename func(string) string) {
+	c.In = rename(c.In)
+	c.Mod = rename(c.Mod)
+}
+
+func (c *Chorus) Step(modules *ModuleMap) {
+	sweepLeft, sweepRight := c.lfo(modules)
+	left, right := getOutput(modules, c.In).channels()
+
+	wetLeft := c.tap(c.left, left, sweepLeft)
+	wetRight := c.tap(c.right, right, sweepRight)
+
+	// vibrato only outputs the delayed signal
+	mix := c.Mix
+	if c.Type == chorusTypeVibrato {
+		mix = 1
+	}
+	c.current = stereoOutput(
+		calc.Limit(left*(1-mix)+wetLeft*mix, outputRange),
+		calc.Limit(right*(1-mix)+wetRight*mix, outputRange),
+	)
+
+	c.phase = math.Mod(c.phase+c.Rate/c.sampleRate, 1)
+	c.fade()
+}
+
+// lfo returns the sweep of both channels in range [-1, 1]
+// the internal lfo of the right channel is ahead by spread, a modulator sweeps both channels the same way
+func (c *Chorus) lfo(modules *ModuleMap) (float64, float64) {
+	if c.Mod != "" {
+		mod := calc.Limit(getMono(modules, c.Mod), outputRange)
+		return mod, mod
+	}
+	return math.Sin(2 * math.Pi * c.phase), math.Sin(2 * math.Pi * (c.phase + c.Spread))
+}
+
+// tap reads the delayed signal at the current sweep and writes the input with feedback
+func (c *Chorus) tap(d *fractionalDelay, x, sweep float64) float64 {
+	delay := (c.Delay + c.Depth*sweep) / 1000 * c.sampleRate
+	y := d.read(delay)
+	d.write(x + y*c.Feedback)
+	return y
+}
+
+func (c *Chorus) initializeFaders() {
+	if c.rateFader != nil {
+		c.rateFader.initialize(c.Fade, c.sampleRate)
+	}
+	if c.depthFader != nil {
+		c.depthFader.initialize(c.Fade, c.sampleRate)
+	}
+	if c.delayFader != nil {
+		c.delayFader.initialize(c.Fade, c.sampleRate)
+	}
+	if c.feedbackFader != nil {
+		c.feedbackFader.initialize(c.Fade, c.sampleRate)
+	}
+	if c.mixFader != nil {
+		c.mixFader.initialize(c.Fade, c.sampleRate)
+	}
+}
+
+func (c *Chorus) fade() {
+	if c.rateFader != nil {
+		c.Rate = c.rateFader.fade()
+	}
+	if c.depthFader != nil {
+		c.Depth = c.depthFader.fade()
+	}
+	if c.delayFader != nil {
+		c.Delay = c.delayFader.fade()
+	}
+	if c.feedbackFader != nil {
+		c.Feedback = c.feedbackFader.fade()
+	}
+	if c.mixFader != nil {
+		c.Mix = c.mixFader.fade()
+	}
+}
+
+// read returns the input from delay samples ago, the delay is at least one sample
+func (d *fractionalDelay) read(delay float64) float64 {
+	length := len(d.buf)
+	if length < 3 {
+		return 0
+	}
+
+	delay = calc.Limit(delay, calc.Range{Min: 1, Max: float64(length - 2)})
+	pos := float64(d.idx) - delay
+	if pos < 0 {
+		pos += float64(length)
+	}
+
+	i := int(pos)
+	frac := pos - float64(i)
+	next := (i + 1) % length
+
+	return d.buf[i] + frac*(d.buf[next]-d.buf[i])
+}
+
+// comb adds the signal delayed by a number of samples to x and feeds the sum back into the line
+func (d *fractionalDelay) comb(x, delay, mix float64) float64 {
+	y := x + d.read(delay)*mix
+	d.write(y)
+	return y
+}
+
+func (d *fractionalDelay) write(x float64) {
+	if len(d.buf) == 0 {
+		return
+	}
+	d.buf[d.idx] = x
+	d.idx = (d.idx + 1) % len(d.buf)
+}
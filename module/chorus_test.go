@@ -0,0 +1,195 @@
+package module
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestChorus_initialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *Chorus
+		want    *Chorus
+		wantErr bool
+	}{
+		{
+			name: "chorus defaults",
+			c:    &Chorus{Type: chorusTypeChorus},
+			want: &Chorus{Type: chorusTypeChorus, Delay: 20, Depth: 5, Rate: 0.8, Mix: 0.5},
+		},
+		{
+			name: "flanger defaults",
+			c:    &Chorus{Type: chorusTypeFlanger, Feedback: 0.7},
+			want: &Chorus{Type: chorusTypeFlanger, Delay: 3, Depth: 2, Rate: 0.2, Feedback: 0.7, Mix: 0.5},
+		},
+		{
+			name: "limits",
+			c:    &Chorus{Type: chorusTypeVibrato, Delay: 100, Depth: 30, Rate: 100, Feedback: 1, Spread: 2, Mix: 2},
+			want: &Chorus{Type: chorusTypeVibrato, Delay: 50, Depth: 20, Rate: 20, Feedback: 0.95, Spread: 1, Mix: 1},
+		},
+		{
+			name:    "unknown type",
+			c:       &Chorus{Type: "Phaser"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.initialize(44100)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Chorus.initialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, tt.c, cmpopts.IgnoreUnexported(Module{}, Chorus{})); diff != "" {
+				t.Errorf("Chorus.initialize() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestChorus_Update(t *testing.T) {
+	sampleRate := 44100.0
+
+	c := &Chorus{
+		Type:          chorusTypeChorus,
+		Rate:          1,
+		Depth:         5,
+		Delay:         20,
+		Mix:           0.5,
+		In:            "in",
+		sampleRate:    sampleRate,
+		phase:         0.25,
+		rateFader:     &fader{current: 1, target: 1},
+		depthFader:    &fader{current: 5, target: 5},
+		delayFader:    &fader{current: 20, target: 20},
+		feedbackFader: &fader{},
+		mixFader:      &fader{current: 0.5, target: 0.5},
+	}
+	c.Update(&Chorus{
+		Type:     chorusTypeFlanger,
+		Rate:     2,
+		Depth:    2,
+		Delay:    4,
+		Feedback: 0.5,
+		Spread:   0.5,
+		Mix:      1,
+		Mod:      "new-mod",
+		In:       "new-in",
+		Fade:     1,
+	})
+
+	want := &Chorus{
+		Type:          chorusTypeFlanger,
+		Rate:          1,
+		Depth:         5,
+		Delay:         20,
+		Spread:        0.5,
+		Mix:           0.5,
+		Mod:           "new-mod",
+		In:            "new-in",
+		Fade:          1,
+		sampleRate:    sampleRate,
+		phase:         0.25,
+		rateFader:     &fader{current: 1, target: 2, step: 1 / sampleRate},
+		depthFader:    &fader{current: 5, target: 2, step: -3 / sampleRate},
+		delayFader:    &fader{current: 20, target: 4, step: -16 / sampleRate},
+		feedbackFader: &fader{target: 0.5, step: 0.5 / sampleRate},
+		mixFader:      &fader{current: 0.5, target: 1, step: 0.5 / sampleRate},
+	}
+	if diff := cmp.Diff(want, c, cmp.AllowUnexported(Module{}, Chorus{}, fader{})); diff != "" {
+		t.Errorf("Chorus.Update() diff = %s", diff)
+	}
+}
+
+func TestChorus_Step(t *testing.T) {
+	// at this sample rate one millisecond is one sample
+	sampleRate := 1000.0
+
+	tests := []struct {
+		name string
+		c    *Chorus
+		want []float64
+	}{
+		{
+			name: "delay",
+			c:    &Chorus{Type: chorusTypeVibrato, Delay: 2, Depth: 1},
+			want: []float64{0, 0, 1, 0, 0, 0},
+		},
+		{
+			name: "delay between samples",
+			c:    &Chorus{Type: chorusTypeVibrato, Delay: 2.5, Depth: 1},
+			want: []float64{0, 0, 0.5, 0.5, 0, 0},
+		},
+		{
+			name: "feedback",
+			c:    &Chorus{Type: chorusTypeFlanger, Delay: 2, Depth: 1, Feedback: 0.5, Mix: 1},
+			want: []float64{0, 0, 1, 0, 0.5, 0, 0.25},
+		},
+		{
+			name: "mix",
+			c:    &Chorus{Type: chorusTypeChorus, Delay: 2, Depth: 1, Mix: 0.25},
+			want: []float64{0.75, 0, 0.25, 0, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.initialize(sampleRate); err != nil {
+				t.Fatalf("Chorus.initialize() error = %v", err)
+			}
+			// a constant modulator keeps the delay still
+			tt.c.Mod = "mod"
+			tt.c.In = "in"
+
+			in := &Module{}
+			mod := &Module{}
+			modules := NewModuleMap(map[string]IModule{"in": in, "mod": mod})
+
+			var got []float64
+			for i := range tt.want {
+				in.current = Output{}
+				if i == 0 {
+					in.current = monoOutput(1)
+				}
+				tt.c.Step(modules)
+				got = append(got, tt.c.Current().Mono)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Chorus.Step() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestChorus_spread(t *testing.T) {
+	sampleRate := 44100.0
+
+	for _, spread := range []float64{0, 0.5} {
+		c := &Chorus{Type: chorusTypeChorus, Spread: spread, In: "in"}
+		if err := c.initialize(sampleRate); err != nil {
+			t.Fatalf("Chorus.initialize() error = %v", err)
+		}
+
+		in := &Module{}
+		modules := NewModuleMap(map[string]IModule{"in": in})
+
+		var diff float64
+		for i := range int(sampleRate) {
+			in.current = monoOutput(math.Sin(2 * math.Pi * 440 * float64(i) / sampleRate))
+			c.Step(modules)
+			diff = math.Max(diff, math.Abs(c.Current().Left-c.Current().Right))
+		}
+
+		if spread == 0 && diff != 0 {
+			t.Errorf("spread 0: channels differ by %v", diff)
+		}
+		if spread > 0 && diff < 0.01 {
+			t.Errorf("spread %v: channels don't differ", spread)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package module
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Clock counts the bars played at the tempo of the patch
+	// synced modules derive their position from the clock instead of counting on their own,
+	// so they stay in phase with each other when the tempo changes
+	Clock struct {
+		BPM  float64
+		Fade float64
+
+		bars       float64
+		sampleRate float64
+
+		bpmFader *fader
+	}
+
+	// Division is a note length in bars, it is either a number or a fraction like 1/8
+	Division float64
+)
+
+const (
+	beatsPerBar = 4
+	defaultBPM  = 120
+)
+
+func NewClock(bpm, fade, sampleRate float64) *Clock {
+	c := &Clock{
+		BPM:        clockBPM(bpm),
+		Fade:       calc.Limit(fade, fadeRange),
+		sampleRate: sampleRate,
+	}
+	c.bpmFader = &fader{
+		current: c.BPM,
+		target:  c.BPM,
+	}
+	c.bpmFader.initialize(c.Fade, sampleRate)
+	return c
+}
+
+// Update fades to the new tempo, the position of the clock is kept
+func (c *Clock) Update(bpm, fade float64) {
+	c.Fade = calc.Limit(fade, fadeRange)
+	if c.bpmFader != nil {
+		c.bpmFader.target = clockBPM(bpm)
+		c.bpmFader.initialize(c.Fade, c.sampleRate)
+	}
+}
+
+func (c *Clock) Step() {
+	if c.sampleRate == 0 {
+		return
+	}
+
+	c.bars += c.BPM / 60 / beatsPerBar / c.sampleRate
+	if c.bpmFader != nil {
+		c.BPM = c.bpmFader.fade()
+	}
+}
+
+// Bars returns the number of bars played so far
+func (c *Clock) Bars() float64 {
+	return c.bars
+}
+
+// position returns the number of divisions played so far
+func (c *Clock) position(d Division) float64 {
+	if d == 0 {
+		return 0
+	}
+	return c.bars / float64(d)
+}
+
+// seconds returns the length of a division at the current tempo
+func (c *Clock) seconds(d Division) float64 {
+	if c.BPM == 0 {
+		return 0
+	}
+	return float64(d) * beatsPerBar * 60 / c.BPM
+}
+
+func clockBPM(bpm float64) float64 {
+	if bpm == 0 {
+		return defaultBPM
+	}
+	return calc.Limit(bpm, bpmRange)
+}
+
+func (d *Division) UnmarshalYAML(unmarshal func(any) error) error {
+	var bars float64
+	if err := unmarshal(&bars); err == nil {
+		*d = Division(bars)
+		return nil
+	}
+
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	division, err := parseDivision(s)
+	if err != nil {
+		return err
+	}
+	*d = division
+	return nil
+}
+
+func parseDivision(s string) (Division, error) {
+	num, den, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid division %q", s)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid division %q", s)
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("invalid division %q", s)
+	}
+
+	return Division(n / d), nil
+}
@@ -0,0 +1,154 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gopkg.in/yaml.v2"
+)
+
+func TestClock_Step(t *testing.T) {
+	sampleRate := 100.0
+
+	tests := []struct {
+		name     string
+		c        *Clock
+		newBPM   float64
+		steps    int
+		wantBars float64
+		wantBPM  float64
+	}{
+		{
+			name:     "default tempo",
+			c:        NewClock(0, 0, sampleRate),
+			steps:    100,
+			wantBars: 0.5,
+			wantBPM:  120,
+		},
+		{
+			name:     "constant tempo",
+			c:        NewClock(60, 0, sampleRate),
+			steps:    200,
+			wantBars: 0.5,
+			wantBPM:  60,
+		},
+		{
+			name:   "tempo change without fade",
+			c:      NewClock(60, 0, sampleRate),
+			newBPM: 240,
+			steps:  100,
+			// the first step still runs at the old tempo
+			wantBars: 0.9925,
+			wantBPM:  240,
+		},
+		{
+			name:     "tempo change with fade",
+			c:        NewClock(60, 1, sampleRate),
+			newBPM:   120,
+			steps:    100,
+			wantBars: 0.37375,
+			wantBPM:  120,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.newBPM != 0 {
+				tt.c.Update(tt.newBPM, tt.c.Fade)
+			}
+			for range tt.steps {
+				tt.c.Step()
+			}
+
+			if diff := cmp.Diff(tt.wantBars, tt.c.Bars(), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Clock.Bars() diff = %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantBPM, tt.c.BPM, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Clock.BPM diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestClock_seconds(t *testing.T) {
+	tests := []struct {
+		name string
+		bpm  float64
+		d    Division
+		want float64
+	}{
+		{
+			name: "quarter note",
+			bpm:  120,
+			d:    0.25,
+			want: 0.5,
+		},
+		{
+			name: "dotted eighth",
+			bpm:  60,
+			d:    0.1875,
+			want: 0.75,
+		},
+		{
+			name: "bar",
+			bpm:  240,
+			d:    1,
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Clock{BPM: tt.bpm}
+			if got := c.seconds(tt.d); got != tt.want {
+				t.Errorf("Clock.seconds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivision_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    Division
+		wantErr bool
+	}{
+		{
+			name: "fraction",
+			yaml: "1/8",
+			want: 0.125,
+		},
+		{
+			name: "fraction with spaces",
+			yaml: "3 / 16",
+			want: 0.1875,
+		},
+		{
+			name: "number of bars",
+			yaml: "2",
+			want: 2,
+		},
+		{
+			name:    "no fraction",
+			yaml:    "eighth",
+			wantErr: true,
+		},
+		{
+			name:    "division by zero",
+			yaml:    "1/0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Division
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Division.UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Division.UnmarshalYAML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,28 @@
 package module
 
-import "github.com/iljarotar/synth/calc"
+import (
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
 
 type (
 	Delay struct {
 		Module
-		Time float64 `yaml:"time"`
-		Gain float64 `yaml:"gain"`
-		In   string  `yaml:"in"`
-		CV   string  `yaml:"cv"`
-		Mod  string  `yaml:"mod"`
-		Fade float64 `yaml:"fade"`
+		Time float64  `yaml:"time"`
+		Sync Division `yaml:"sync"`
+		Gain float64  `yaml:"gain"`
+		In   string   `yaml:"in"`
+		CV   string   `yaml:"cv"`
+		Mod  string   `yaml:"mod"`
+		Fade float64  `yaml:"fade"`
 
 		sampleRate float64
-		c          *comb
+		clock      *Clock
+		// left and right delay the channels separately
+		left, right *comb
+		// synced delays read between samples instead, so that their time follows tempo changes without clicks
+		syncedLeft, syncedRight *fractionalDelay
 
 		mixFader *fader
 	}
@@ -33,10 +42,14 @@ func (m DelayMap) Initialize(sampleRate float64) {
 func (d *Delay) initialize(sampleRate float64) {
 	d.sampleRate = sampleRate
 	d.Time = calc.Limit(d.Time, combTimeRange)
+	d.Sync = Division(calc.Limit(float64(d.Sync), divisionRange))
 	d.Gain = calc.Limit(d.Gain, combMixRange)
 	d.Fade = calc.Limit(d.Fade, fadeRange)
-	d.c = &comb{}
-	d.c.initialize(d.Time/1000, sampleRate)
+	d.left = &comb{}
+	d.left.initialize(d.Time/1000, sampleRate)
+	d.right = &comb{}
+	d.right.initialize(d.Time/1000, sampleRate)
+	d.initializeSynced()
 
 	d.mixFader = &fader{
 		current: d.Gain,
@@ -53,16 +66,53 @@ func (d *Delay) Update(new *Delay) {
 	d.In = new.In
 	d.CV = new.CV
 	d.Mod = new.Mod
-	d.Time = new.Time
+	d.Sync = new.Sync
 	d.Fade = new.Fade
+	// the time of a synced delay is set by the clock
+	if d.clock == nil || d.Sync == 0 {
+		d.Time = new.Time
+	}
 
 	if d.mixFader != nil {
 		d.mixFader.target = new.Gain
 		d.mixFader.initialize(d.Fade, d.sampleRate)
 	}
-	if d.c != nil {
-		d.c.update(d.Time / 1000)
+	if d.left != nil {
+		d.left.update(d.Time / 1000)
+	}
+	if d.right != nil {
+		d.right.update(d.Time / 1000)
+	}
+	d.initializeSynced()
+}
+
+// initializeSynced creates the delay lines of a synced delay, they are large enough for the longest delay
+func (d *Delay) initializeSynced() {
+	if d.Sync == 0 || d.syncedLeft != nil {
+		return
 	}
+	length := int(math.Ceil(combTimeRange.Max/1000*d.sampleRate)) + 2
+	d.syncedLeft = &fractionalDelay{buf: make([]float64, length)}
+	d.syncedRight = &fractionalDelay{buf: make([]float64, length)}
+}
+
+// SetClock syncs the delay time to the clock, if sync is set
+func (d *Delay) SetClock(c *Clock) {
+	d.clock = c
+}
+
+func (d *Delay) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":  d.In,
+		"cv":  d.CV,
+		"mod": d.Mod,
+	})
+}
+
+func (d *Delay) RenameInputs(rename func(string) string) {
+	d.In = rename(d.In)
+	d.CV = rename(d.CV)
+	d.Mod = rename(d.Mod)
 }
 
 func (d *Delay) Step(modules *ModuleMap) {
@@ -72,11 +122,15 @@ func (d *Delay) Step(modules *ModuleMap) {
 	}
 	mix = modulate(mix, combMixRange, getMono(modules, d.Mod))
 
-	val := d.c.step(getMono(modules, d.In), mix)
-	d.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
+	left, right := getOutput(modules, d.In).channels()
+
+	// a synced delay follows the tempo, during tempo changes its echoes bend like those of a tape delay
+	if d.clock != nil && d.Sync > 0 && d.syncedLeft != nil {
+		d.Time = calc.Limit(d.clock.seconds(d.Sync)*1000, combTimeRange)
+		samples := d.Time / 1000 * d.sampleRate
+		d.current = stereoOutput(d.syncedLeft.comb(left, samples, mix), d.syncedRight.comb(right, samples, mix))
+	} else {
+		d.current = stereoOutput(d.left.step(left, mix), d.right.step(right, mix))
 	}
 
 	d.fade()
@@ -1,6 +1,7 @@
 package module
 
 import (
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -17,9 +18,7 @@ func TestDelay_Update(t *testing.T) {
 			name: "no update necessary",
 			d: &Delay{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Time:       30,
 				Gain:       0.5,
@@ -28,7 +27,11 @@ func TestDelay_Update(t *testing.T) {
 				Mod:        "mod",
 				Fade:       1,
 				sampleRate: 44100,
-				c: &comb{
+				left: &comb{
+					y:          []float64{0.5, 0, 0, 0.25},
+					sampleRate: 44100,
+				},
+				right: &comb{
 					y:          []float64{0.5, 0, 0, 0.25},
 					sampleRate: 44100,
 				},
@@ -41,9 +44,7 @@ func TestDelay_Update(t *testing.T) {
 			new: nil,
 			want: &Delay{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Time:       30,
 				Gain:       0.5,
@@ -52,7 +53,11 @@ func TestDelay_Update(t *testing.T) {
 				Mod:        "mod",
 				Fade:       1,
 				sampleRate: 44100,
-				c: &comb{
+				left: &comb{
+					y:          []float64{0.5, 0, 0, 0.25},
+					sampleRate: 44100,
+				},
+				right: &comb{
 					y:          []float64{0.5, 0, 0, 0.25},
 					sampleRate: 44100,
 				},
@@ -67,9 +72,7 @@ func TestDelay_Update(t *testing.T) {
 			name: "update all",
 			d: &Delay{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Time:       900,
 				Gain:       0.5,
@@ -78,7 +81,11 @@ func TestDelay_Update(t *testing.T) {
 				Mod:        "mod",
 				Fade:       1,
 				sampleRate: 6,
-				c: &comb{
+				left: &comb{
+					y:          []float64{0.5, 0, 0, 0, 0.25},
+					sampleRate: 6,
+				},
+				right: &comb{
 					y:          []float64{0.5, 0, 0, 0, 0.25},
 					sampleRate: 6,
 				},
@@ -98,9 +105,7 @@ func TestDelay_Update(t *testing.T) {
 			},
 			want: &Delay{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Time:       1000,
 				Gain:       0.5,
@@ -109,7 +114,11 @@ func TestDelay_Update(t *testing.T) {
 				Mod:        "new-mod",
 				Fade:       2,
 				sampleRate: 6,
-				c: &comb{
+				left: &comb{
+					y:          []float64{0.5, 0, 0, 0, 0.25, 0},
+					sampleRate: 6,
+				},
+				right: &comb{
 					y:          []float64{0.5, 0, 0, 0, 0.25, 0},
 					sampleRate: 6,
 				},
@@ -142,15 +151,17 @@ func TestDelay_Step(t *testing.T) {
 			name: "time zero",
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 			}),
 			d: &Delay{
 				Gain: 0.25,
 				In:   "in",
-				c: &comb{
+				left: &comb{
+					y:   []float64{},
+					idx: 2,
+				},
+				right: &comb{
 					y:   []float64{},
 					idx: 2,
 				},
@@ -161,15 +172,17 @@ func TestDelay_Step(t *testing.T) {
 			name: "non-zero time",
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 			}),
 			d: &Delay{
 				Gain: 0.25,
 				In:   "in",
-				c: &comb{
+				left: &comb{
+					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
+					idx: 1,
+				},
+				right: &comb{
 					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
 					idx: 1,
 				},
@@ -180,21 +193,21 @@ func TestDelay_Step(t *testing.T) {
 			name: "cv",
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				"cv": &Module{
-					current: Output{
-						Mono: 0.5,
-					},
+					current: monoOutput(0.5),
 				},
 			}),
 			d: &Delay{
 				Gain: 0.25,
 				In:   "in",
 				CV:   "cv",
-				c: &comb{
+				left: &comb{
+					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
+					idx: 1,
+				},
+				right: &comb{
 					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
 					idx: 1,
 				},
@@ -205,19 +218,13 @@ func TestDelay_Step(t *testing.T) {
 			name: "mod and cv",
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				"cv": &Module{
-					current: Output{
-						Mono: 0.5,
-					},
+					current: monoOutput(0.5),
 				},
 				"mod": &Module{
-					current: Output{
-						Mono: -0.2,
-					},
+					current: monoOutput(-0.2),
 				},
 			}),
 			d: &Delay{
@@ -225,7 +232,11 @@ func TestDelay_Step(t *testing.T) {
 				In:   "in",
 				CV:   "cv",
 				Mod:  "mod",
-				c: &comb{
+				left: &comb{
+					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
+					idx: 1,
+				},
+				right: &comb{
 					y:   []float64{0.5, 0.25, 0, 0, 0, 0},
 					idx: 1,
 				},
@@ -296,3 +307,104 @@ func TestDelay_fade(t *testing.T) {
 		})
 	}
 }
+
+func TestDelay_stereo(t *testing.T) {
+	d := &Delay{Time: 2, Gain: 0.5, In: "in"}
+	d.initialize(1000)
+
+	in := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in})
+
+	var left, right []float64
+	for i := range 6 {
+		in.current = Output{}
+		if i == 0 {
+			in.current = stereoOutput(1, -1)
+		}
+		d.Step(modules)
+		l, r := d.Current().channels()
+		left = append(left, l)
+		right = append(right, r)
+	}
+
+	if diff := cmp.Diff([]float64{1, 0, 0.5, 0, 0.25, 0}, left); diff != "" {
+		t.Errorf("left channel diff = %s", diff)
+	}
+	if diff := cmp.Diff([]float64{-1, 0, -0.5, 0, -0.25, 0}, right); diff != "" {
+		t.Errorf("right channel diff = %s", diff)
+	}
+}
+
+func TestDelay_sync(t *testing.T) {
+	sampleRate := 1000.0
+	clock := NewClock(120, 0, sampleRate)
+
+	d := &Delay{Time: 100, Sync: 0.125, Gain: 0.5, In: "in"}
+	d.initialize(sampleRate)
+	d.SetClock(clock)
+
+	in := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in})
+
+	// an eighth note at 120 bpm lasts 250 milliseconds
+	var out []float64
+	for i := range 251 {
+		in.current = Output{}
+		if i == 0 {
+			in.current = monoOutput(1)
+		}
+		d.Step(modules)
+		out = append(out, d.Current().Mono)
+	}
+	if d.Time != 250 {
+		t.Errorf("Delay.Step() time = %v, want 250", d.Time)
+	}
+	if out[250] != 0.5 || out[249] != 0 {
+		t.Errorf("Delay.Step() echo = %v after %v, want 0.5 after 0", out[250], out[249])
+	}
+
+	// the time of a synced delay is not reset by an update
+	d.Update(&Delay{Time: 100, Sync: 0.125, Gain: 0.5, In: "in"})
+	clock.Update(60, 0)
+	clock.Step()
+	d.Step(modules)
+	if d.Time != 500 {
+		t.Errorf("Delay.Step() time after tempo change = %v, want 500", d.Time)
+	}
+}
+
+func TestDelay_sync_tempoFade(t *testing.T) {
+	sampleRate := 1000.0
+	clock := NewClock(120, 0, sampleRate)
+
+	d := &Delay{Sync: 0.125, Gain: 0.5, In: "in"}
+	d.initialize(sampleRate)
+	d.SetClock(clock)
+
+	in := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in})
+
+	var (
+		previous float64
+		maxJump  float64
+	)
+	for i := range 3000 {
+		if i == 1000 {
+			clock.Update(60, 1)
+		}
+		in.current = monoOutput(math.Sin(2 * math.Pi * 5 * float64(i) / sampleRate))
+		d.Step(modules)
+		clock.Step()
+
+		current := d.Current().Mono
+		if i > 0 {
+			maxJump = max(maxJump, math.Abs(current-previous))
+		}
+		previous = current
+	}
+
+	// the input changes by at most 0.03 per sample, the echoes add about the same again
+	if maxJump > 0.1 {
+		t.Errorf("Delay.Step() jumps by %v between samples during a tempo fade", maxJump)
+	}
+}
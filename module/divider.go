@@ -0,0 +1,205 @@
+package module
+
+import (
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Divider turns a stream of triggers into a slower or faster stream of gates
+	// every div-th trigger starts a cycle, which is split into mult pulses
+	// the length of a cycle is measured from the time between the last two triggers
+	Divider struct {
+		Module
+		Div     int     `yaml:"div"`
+		Mult    int     `yaml:"mult"`
+		Swing   float64 `yaml:"swing"`
+		Width   float64 `yaml:"width"`
+		Trigger string  `yaml:"trigger"`
+		Reset   string  `yaml:"reset"`
+		Fade    float64 `yaml:"fade"`
+
+		sampleRate   float64
+		triggerValue float64
+		resetValue   float64
+		// count is the number of triggers since the last reset
+		count int
+		// cycles is the number of cycles since the last reset, it decides which pulses are swung
+		cycles int
+		// period is the number of samples between the last two triggers, zero until it is known
+		period float64
+		// sinceTrigger and elapsed count the samples since the last trigger and since the start of the cycle
+		sinceTrigger float64
+		elapsed      float64
+		triggered    bool
+		running      bool
+
+		swingFader *fader
+		widthFader *fader
+	}
+
+	DividerMap map[string]*Divider
+)
+
+const maxDivision = 256
+
+func (m DividerMap) Initialize(sampleRate float64) {
+	for _, d := range m {
+		if d == nil {
+			continue
+		}
+		d.initialize(sampleRate)
+	}
+}
+
+func (d *Divider) initialize(sampleRate float64) {
+	d.sampleRate = sampleRate
+	d.Div = limitDivision(d.Div)
+	d.Mult = limitDivision(d.Mult)
+	d.Swing = calc.Limit(d.Swing, positionRange)
+	if d.Width == 0 {
+		d.Width = defaultWidth
+	}
+	d.Width = calc.Limit(d.Width, widthRange)
+	d.Fade = calc.Limit(d.Fade, fadeRange)
+	d.current = monoOutput(-1)
+
+	d.swingFader = &fader{
+		current: d.Swing,
+		target:  d.Swing,
+	}
+	d.widthFader = &fader{
+		current: d.Width,
+		target:  d.Width,
+	}
+	d.initializeFaders()
+}
+
+func (d *Divider) Update(new *Divider) {
+	if new == nil {
+		return
+	}
+
+	d.Div = new.Div
+	d.Mult = new.Mult
+	d.Trigger = new.Trigger
+	d.Reset = new.Reset
+	d.Fade = new.Fade
+
+	if d.swingFader != nil {
+		d.swingFader.target = new.Swing
+	}
+	if d.widthFader != nil {
+		d.widthFader.target = new.Width
+	}
+	d.initializeFaders()
+}
+
+func (d *Divider) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"trigger": d.Trigger,
+		"reset":   d.Reset,
+	})
+}
+
+func (d *Divider) RenameInputs(rename func(string) string) {
+	d.Trigger = rename(d.Trigger)
+	d.Reset = rename(d.Reset)
+}
+
+func (d *Divider) Step(modules *ModuleMap) {
+	// a reset ends the current cycle, so the next trigger starts a new one
+	resetValue := getMono(modules, d.Reset)
+	if resetValue > 0 && d.resetValue <= 0 {
+		d.count = 0
+		d.cycles = 0
+		d.running = false
+	}
+	d.resetValue = resetValue
+
+	triggerValue := getMono(modules, d.Trigger)
+	if triggerValue > 0 && d.triggerValue <= 0 {
+		d.trigger()
+	}
+	d.triggerValue = triggerValue
+
+	val := -1.0
+	if d.high() {
+		val = 1
+	}
+	d.current = monoOutput(val)
+
+	d.sinceTrigger++
+	d.elapsed++
+	d.fade()
+}
+
+func (d *Divider) trigger() {
+	if d.triggered {
+		d.period = d.sinceTrigger
+	}
+	d.sinceTrigger = 0
+	d.triggered = true
+
+	if d.count%max(d.Div, 1) == 0 {
+		if d.running {
+			d.cycles++
+		}
+		d.elapsed = 0
+		d.running = true
+	}
+	d.count++
+}
+
+// high reports whether the output is in one of the pulses of the current cycle
+// every second pulse is delayed by half the swing
+func (d *Divider) high() bool {
+	if !d.running {
+		return false
+	}
+
+	mult := max(d.Mult, 1)
+	// until the period is known, the first pulse lasts until the next trigger
+	if d.period == 0 {
+		return true
+	}
+
+	interval := d.period * float64(max(d.Div, 1)) / float64(mult)
+	pos := d.elapsed / interval
+	pulse := math.Floor(pos)
+	if pulse >= float64(mult) {
+		return false
+	}
+
+	phase := pos - pulse
+	if (d.cycles*mult+int(pulse))%2 == 1 {
+		phase -= d.Swing / 2
+	}
+	return phase >= 0 && phase < d.Width
+}
+
+func (d *Divider) initializeFaders() {
+	if d.swingFader != nil {
+		d.swingFader.initialize(d.Fade, d.sampleRate)
+	}
+	if d.widthFader != nil {
+		d.widthFader.initialize(d.Fade, d.sampleRate)
+	}
+}
+
+func (d *Divider) fade() {
+	if d.swingFader != nil {
+		d.Swing = d.swingFader.fade()
+	}
+	if d.widthFader != nil {
+		d.Width = d.widthFader.fade()
+	}
+}
+
+func limitDivision(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return int(calc.Limit(float64(n), calc.Range{Min: 1, Max: maxDivision}))
+}
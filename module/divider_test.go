@@ -0,0 +1,177 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDivider_Step(t *testing.T) {
+	// the trigger rises every 100 samples and stays high for 10 samples
+	trigger := func(i int) float64 {
+		if i%100 < 10 {
+			return 1
+		}
+		return -1
+	}
+
+	tests := []struct {
+		name  string
+		d     *Divider
+		reset func(i int) float64
+		// want maps samples to the expected output
+		want map[int]float64
+	}{
+		{
+			name: "pass through",
+			d:    &Divider{},
+			want: map[int]float64{0: 1, 99: 1, 100: 1, 149: 1, 150: -1, 199: -1, 200: 1},
+		},
+		{
+			name: "first pulse lasts until the period is known",
+			d:    &Divider{Div: 2},
+			want: map[int]float64{0: 1, 99: 1, 100: -1, 199: -1, 200: 1, 299: 1, 300: -1},
+		},
+		{
+			name: "divide",
+			d:    &Divider{Div: 4, Width: 0.25},
+			want: map[int]float64{400: 1, 499: 1, 500: -1, 799: -1, 800: 1},
+		},
+		{
+			name: "multiply",
+			d:    &Divider{Mult: 2},
+			want: map[int]float64{100: 1, 124: 1, 125: -1, 149: -1, 150: 1, 174: 1, 175: -1, 200: 1},
+		},
+		{
+			name: "divide and multiply",
+			d:    &Divider{Div: 2, Mult: 3, Width: 0.1},
+			want: map[int]float64{200: 1, 206: 1, 207: -1, 266: -1, 267: 1, 334: 1, 342: -1, 400: 1},
+		},
+		{
+			name: "swing delays every second pulse",
+			d:    &Divider{Swing: 0.5},
+			want: map[int]float64{100: -1, 124: -1, 125: 1, 174: 1, 175: -1, 200: 1, 249: 1, 250: -1, 300: -1, 325: 1},
+		},
+		{
+			name: "swing with multiply",
+			d:    &Divider{Mult: 2, Swing: 1},
+			want: map[int]float64{100: 1, 124: 1, 125: -1, 149: -1, 150: -1, 174: -1, 175: 1, 199: 1, 200: 1},
+		},
+		{
+			name: "reset restarts the division",
+			d:    &Divider{Div: 3, Width: 0.1},
+			reset: func(i int) float64 {
+				if i == 450 {
+					return 1
+				}
+				return -1
+			},
+			want: map[int]float64{300: 1, 329: 1, 330: -1, 450: -1, 500: 1, 529: 1, 530: -1, 600: -1, 700: -1, 800: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.initialize(44100)
+			tt.d.Trigger = "trigger"
+			tt.d.Reset = "reset"
+
+			trig, reset := &Module{}, &Module{}
+			modules := NewModuleMap(map[string]IModule{"trigger": trig, "reset": reset})
+
+			got := map[int]float64{}
+			for i := range 1000 {
+				trig.current = monoOutput(trigger(i))
+				reset.current = monoOutput(-1)
+				if tt.reset != nil {
+					reset.current = monoOutput(tt.reset(i))
+				}
+
+				tt.d.Step(modules)
+				if _, ok := tt.want[i]; ok {
+					got[i] = tt.d.Current().Mono
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Divider.Step() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestDivider_Update(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name string
+		d    *Divider
+		new  *Divider
+		want *Divider
+	}{
+		{
+			name: "no update necessary",
+			d: &Divider{
+				Div:        2,
+				Trigger:    "trigger",
+				sampleRate: sampleRate,
+				swingFader: &fader{},
+				widthFader: &fader{current: 0.5, target: 0.5},
+			},
+			new: nil,
+			want: &Divider{
+				Div:        2,
+				Trigger:    "trigger",
+				sampleRate: sampleRate,
+				swingFader: &fader{},
+				widthFader: &fader{current: 0.5, target: 0.5},
+			},
+		},
+		{
+			name: "update all",
+			d: &Divider{
+				Div:        2,
+				Mult:       1,
+				Swing:      0,
+				Width:      0.5,
+				Trigger:    "trigger",
+				Reset:      "reset",
+				sampleRate: sampleRate,
+				count:      3,
+				period:     100,
+				swingFader: &fader{},
+				widthFader: &fader{current: 0.5, target: 0.5},
+			},
+			new: &Divider{
+				Div:     4,
+				Mult:    3,
+				Swing:   0.5,
+				Width:   0.25,
+				Trigger: "new-trigger",
+				Reset:   "new-reset",
+				Fade:    1,
+			},
+			want: &Divider{
+				Div:        4,
+				Mult:       3,
+				Swing:      0,
+				Width:      0.5,
+				Trigger:    "new-trigger",
+				Reset:      "new-reset",
+				Fade:       1,
+				sampleRate: sampleRate,
+				count:      3,
+				period:     100,
+				swingFader: &fader{target: 0.5, step: 0.5 / sampleRate},
+				widthFader: &fader{current: 0.5, target: 0.25, step: -0.25 / sampleRate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.d, cmp.AllowUnexported(Module{}, Divider{}, fader{})); diff != "" {
+				t.Errorf("Divider.Update() diff = %s", diff)
+			}
+		})
+	}
+}
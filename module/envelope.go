@@ -1,58 +1,118 @@
 package module
 
 import (
+	"fmt"
+	"math"
+
 	"github.com/iljarotar/synth/calc"
 )
 
 type (
 	Envelope struct {
 		Module
-		Attack  float64 `yaml:"attack"`
-		Decay   float64 `yaml:"decay"`
-		Release float64 `yaml:"release"`
-		Peak    float64 `yaml:"peak"`
-		Level   float64 `yaml:"level"`
-		Gate    string  `yaml:"gate"`
-		Fade    float64 `yaml:"fade"`
+		Attack       float64       `yaml:"attack"`
+		Hold         float64       `yaml:"hold"`
+		Decay        float64       `yaml:"decay"`
+		Release      float64       `yaml:"release"`
+		Peak         float64       `yaml:"peak"`
+		Level        float64       `yaml:"level"`
+		AttackCurve  envelopeCurve `yaml:"attack-curve"`
+		DecayCurve   envelopeCurve `yaml:"decay-curve"`
+		ReleaseCurve envelopeCurve `yaml:"release-curve"`
+		Velocity     bool          `yaml:"velocity"`
+		Retrigger    retrigger     `yaml:"retrigger"`
+		Gate         string        `yaml:"gate"`
+		Fade         float64       `yaml:"fade"`
 
 		triggeredAt float64
 		releasedAt  float64
 		gateValue   float64
 		level       float64
-		sampleRate  float64
+		start       float64
+		velocity    float64
+		legato      bool
+		// running is true from a trigger until the release has finished, end is 1 for the sample in which it finished and -1 otherwise
+		running    bool
+		end        float64
+		sampleRate float64
 
-		attackFader  *fader
-		decayFader   *fader
-		releaseFader *fader
-		peakFader    *fader
-		levelFader   *fader
+		attackFader       *fader
+		holdFader         *fader
+		decayFader        *fader
+		releaseFader      *fader
+		peakFader         *fader
+		levelFader        *fader
+		attackCurveFader  *fader
+		decayCurveFader   *fader
+		releaseCurveFader *fader
 	}
 
 	EnvelopeMap map[string]*Envelope
+
+	// envelopeCurve bends a segment of an envelope, it is either a name or a curvature
+	// at 0 the segment is linear, positive values bend it like an exponential and negative values like a logarithm
+	envelopeCurve float64
+
+	// retrigger decides where the envelope starts when it is triggered while it is still sounding
+	retrigger string
+)
+
+const (
+	curveLinear      envelopeCurve = 0
+	curveExponential envelopeCurve = 5
+	curveLogarithmic envelopeCurve = -5
 )
 
-func (m EnvelopeMap) Initialize(sampleRate float64) {
-	for _, e := range m {
+const (
+	retriggerReset   retrigger = "reset"
+	retriggerCurrent retrigger = "current"
+	retriggerLegato  retrigger = "legato"
+)
+
+var curveNames = map[string]envelopeCurve{
+	"linear":      curveLinear,
+	"exponential": curveExponential,
+	"logarithmic": curveLogarithmic,
+}
+
+func (m EnvelopeMap) Initialize(sampleRate float64) error {
+	for name, e := range m {
 		if e == nil {
 			continue
 		}
-		e.initialize(sampleRate)
+		if err := e.initialize(sampleRate); err != nil {
+			return fmt.Errorf("failed to initialize envelope %s: %w", name, err)
+		}
 	}
+	return nil
 }
 
-func (e *Envelope) initialize(sampleRate float64) {
+func (e *Envelope) initialize(sampleRate float64) error {
 	e.sampleRate = sampleRate
 	e.Attack = calc.Limit(e.Attack, envelopeRange)
+	e.Hold = calc.Limit(e.Hold, envelopeRange)
 	e.Decay = calc.Limit(e.Decay, envelopeRange)
 	e.Release = calc.Limit(e.Release, envelopeRange)
 	e.Peak = calc.Limit(e.Peak, gainRange)
 	e.Level = calc.Limit(e.Level, gainRange)
+	e.AttackCurve = envelopeCurve(calc.Limit(float64(e.AttackCurve), curveRange))
+	e.DecayCurve = envelopeCurve(calc.Limit(float64(e.DecayCurve), curveRange))
+	e.ReleaseCurve = envelopeCurve(calc.Limit(float64(e.ReleaseCurve), curveRange))
 	e.Fade = calc.Limit(e.Fade, fadeRange)
 
+	// values are limited before the retrigger mode is validated, so that all problems can be reported at once
+	if err := validateRetrigger(e.Retrigger); err != nil {
+		return &FieldError{Field: "retrigger", Err: err}
+	}
+
 	e.attackFader = &fader{
 		current: e.Attack,
 		target:  e.Attack,
 	}
+	e.holdFader = &fader{
+		current: e.Hold,
+		target:  e.Hold,
+	}
 	e.decayFader = &fader{
 		current: e.Decay,
 		target:  e.Decay,
@@ -69,7 +129,21 @@ func (e *Envelope) initialize(sampleRate float64) {
 		current: e.Level,
 		target:  e.Level,
 	}
+	e.attackCurveFader = &fader{
+		current: float64(e.AttackCurve),
+		target:  float64(e.AttackCurve),
+	}
+	e.decayCurveFader = &fader{
+		current: float64(e.DecayCurve),
+		target:  float64(e.DecayCurve),
+	}
+	e.releaseCurveFader = &fader{
+		current: float64(e.ReleaseCurve),
+		target:  float64(e.ReleaseCurve),
+	}
 	e.initializeFaders()
+
+	return nil
 }
 
 func (e *Envelope) Update(new *Envelope) {
@@ -77,12 +151,17 @@ func (e *Envelope) Update(new *Envelope) {
 		return
 	}
 
+	e.Velocity = new.Velocity
+	e.Retrigger = new.Retrigger
 	e.Gate = new.Gate
 	e.Fade = new.Fade
 
 	if e.attackFader != nil {
 		e.attackFader.target = new.Attack
 	}
+	if e.holdFader != nil {
+		e.holdFader.target = new.Hold
+	}
 	if e.decayFader != nil {
 		e.decayFader.target = new.Decay
 	}
@@ -95,15 +174,34 @@ func (e *Envelope) Update(new *Envelope) {
 	if e.levelFader != nil {
 		e.levelFader.target = new.Level
 	}
+	if e.attackCurveFader != nil {
+		e.attackCurveFader.target = float64(new.AttackCurve)
+	}
+	if e.decayCurveFader != nil {
+		e.decayCurveFader.target = float64(new.DecayCurve)
+	}
+	if e.releaseCurveFader != nil {
+		e.releaseCurveFader.target = float64(new.ReleaseCurve)
+	}
 	e.initializeFaders()
 }
 
+func (e *Envelope) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"gate": e.Gate,
+	})
+}
+
+func (e *Envelope) RenameInputs(rename func(string) string) {
+	e.Gate = rename(e.Gate)
+}
+
 func (e *Envelope) Step(t float64, modules *ModuleMap) {
 	gateValue := getMono(modules, e.Gate)
 
 	switch {
 	case e.gateValue <= 0 && gateValue > 0:
-		e.triggeredAt = t
+		e.trigger(t, gateValue)
 	case e.gateValue > 0 && gateValue <= 0:
 		e.releasedAt = t
 		e.level = calc.Transpose(e.current.Mono, cvRange, gainRange)
@@ -112,16 +210,52 @@ func (e *Envelope) Step(t float64, modules *ModuleMap) {
 	}
 
 	val := calc.Transpose(e.getValue(t), gainRange, cvRange)
-	e.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
+	e.current = monoOutput(val)
+
+	e.end = -1
+	if e.running && e.releasedAt >= e.triggeredAt && t-e.releasedAt > e.Release {
+		e.running = false
+		e.end = 1
 	}
 
 	e.gateValue = gateValue
 	e.fade()
 }
 
+// trigger starts the envelope from zero or, depending on the retrigger mode, from its current level
+// in legato mode an envelope that is still sounding skips attack and hold and returns to the sustain level
+func (e *Envelope) trigger(t, gateValue float64) {
+	current := calc.Transpose(e.current.Mono, cvRange, gainRange)
+
+	e.triggeredAt = t
+	e.running = true
+	e.velocity = calc.Limit(gateValue, gainRange)
+	e.start = 0
+	e.legato = false
+
+	switch e.Retrigger {
+	case retriggerCurrent:
+		e.start = current
+	case retriggerLegato:
+		if current > 0 {
+			e.start = current
+			e.legato = true
+		}
+	}
+}
+
+// Ports returns the end of cycle trigger, which fires when the release has finished
+func (e *Envelope) Ports() []string {
+	return []string{"end"}
+}
+
+func (e *Envelope) Port(name string) (Output, bool) {
+	if name != "end" {
+		return Output{}, false
+	}
+	return monoOutput(e.end), true
+}
+
 func (e *Envelope) getValue(t float64) float64 {
 	if e.releasedAt >= e.triggeredAt {
 		if t-e.releasedAt > e.Release {
@@ -130,32 +264,59 @@ func (e *Envelope) getValue(t float64) float64 {
 		return e.release(t)
 	}
 
+	if e.legato {
+		if t-e.triggeredAt < e.Decay {
+			return curve(e.triggeredAt, e.triggeredAt+e.Decay, e.start, e.sustain(), e.DecayCurve, t)
+		}
+		return e.sustain()
+	}
+
 	switch {
 	case t-e.triggeredAt < e.Attack:
 		return e.attack(t)
-	case t-e.triggeredAt < e.Attack+e.Decay:
+	case t-e.triggeredAt < e.Attack+e.Hold:
+		return e.peak()
+	case t-e.triggeredAt < e.Attack+e.Hold+e.Decay:
 		return e.decay(t)
 	default:
-		return e.Level
+		return e.sustain()
 	}
 }
 
 func (e *Envelope) attack(t float64) float64 {
 	start := e.triggeredAt
 	end := start + e.Attack
-	return linear(start, end, 0, e.Peak, t)
+	return curve(start, end, e.start, e.peak(), e.AttackCurve, t)
 }
 
 func (e *Envelope) decay(t float64) float64 {
-	start := e.triggeredAt + e.Attack
+	start := e.triggeredAt + e.Attack + e.Hold
 	end := start + e.Decay
-	return linear(start, end, e.Peak, e.Level, t)
+	return curve(start, end, e.peak(), e.sustain(), e.DecayCurve, t)
+}
+
+// peak and sustain are scaled by the gate value that triggered the envelope, if velocity is enabled
+func (e *Envelope) peak() float64 {
+	if !e.Velocity {
+		return e.Peak
+	}
+	return e.Peak * e.velocity
+}
+
+func (e *Envelope) sustain() float64 {
+	if !e.Velocity {
+		return e.Level
+	}
+	return e.Level * e.velocity
 }
 
 func (e *Envelope) initializeFaders() {
 	if e.attackFader != nil {
 		e.attackFader.initialize(e.Fade, e.sampleRate)
 	}
+	if e.holdFader != nil {
+		e.holdFader.initialize(e.Fade, e.sampleRate)
+	}
 	if e.decayFader != nil {
 		e.decayFader.initialize(e.Fade, e.sampleRate)
 	}
@@ -168,12 +329,24 @@ func (e *Envelope) initializeFaders() {
 	if e.levelFader != nil {
 		e.levelFader.initialize(e.Fade, e.sampleRate)
 	}
+	if e.attackCurveFader != nil {
+		e.attackCurveFader.initialize(e.Fade, e.sampleRate)
+	}
+	if e.decayCurveFader != nil {
+		e.decayCurveFader.initialize(e.Fade, e.sampleRate)
+	}
+	if e.releaseCurveFader != nil {
+		e.releaseCurveFader.initialize(e.Fade, e.sampleRate)
+	}
 }
 
 func (e *Envelope) fade() {
 	if e.attackFader != nil {
 		e.Attack = e.attackFader.fade()
 	}
+	if e.holdFader != nil {
+		e.Hold = e.holdFader.fade()
+	}
 	if e.decayFader != nil {
 		e.Decay = e.decayFader.fade()
 	}
@@ -186,12 +359,65 @@ func (e *Envelope) fade() {
 	if e.levelFader != nil {
 		e.Level = e.levelFader.fade()
 	}
+	if e.attackCurveFader != nil {
+		e.AttackCurve = envelopeCurve(e.attackCurveFader.fade())
+	}
+	if e.decayCurveFader != nil {
+		e.DecayCurve = envelopeCurve(e.decayCurveFader.fade())
+	}
+	if e.releaseCurveFader != nil {
+		e.ReleaseCurve = envelopeCurve(e.releaseCurveFader.fade())
+	}
 }
 
 func (e *Envelope) release(t float64) float64 {
 	start := e.releasedAt
 	end := start + e.Release
-	return linear(start, end, e.level, 0, t)
+	return curve(start, end, e.level, 0, e.ReleaseCurve, t)
+}
+
+func validateRetrigger(r retrigger) error {
+	switch r {
+	case "", retriggerReset, retriggerCurrent, retriggerLegato:
+		return nil
+	default:
+		return fmt.Errorf("unknown retrigger mode %s", r)
+	}
+}
+
+func (c *envelopeCurve) UnmarshalYAML(unmarshal func(any) error) error {
+	var curvature float64
+	if err := unmarshal(&curvature); err == nil {
+		*c = envelopeCurve(curvature)
+		return nil
+	}
+
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	curve, ok := curveNames[name]
+	if !ok {
+		return fmt.Errorf("unknown curve %q", name)
+	}
+	*c = curve
+	return nil
+}
+
+// curve interpolates like linear, but bends the segment by the curvature
+// the curvature applies to the value, so an exponential curve starts slow when rising and fast when falling
+func curve(startAt, endAt, startValue, targetValue float64, curvature envelopeCurve, t float64) float64 {
+	delta := endAt - startAt
+	if curvature == curveLinear || delta == 0 {
+		return linear(startAt, endAt, startValue, targetValue, t)
+	}
+
+	k := float64(curvature)
+	if targetValue < startValue {
+		k = -k
+	}
+	x := (t - startAt) / delta
+	return startValue + (targetValue-startValue)*math.Expm1(k*x)/math.Expm1(k)
 }
 
 func linear(startAt, endAt, startValue, targetValue, t float64) float64 {
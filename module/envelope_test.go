@@ -1,9 +1,11 @@
 package module
 
 import (
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
 )
 
 func TestEnvelope_attack(t *testing.T) {
@@ -205,6 +207,34 @@ func TestEnvelope_getValue(t *testing.T) {
 			},
 			want: 0.75,
 		},
+		{
+			name: "hold",
+			t:    7.5,
+			e: &Envelope{
+				Attack:      1,
+				Hold:        1,
+				Decay:       2,
+				Peak:        1,
+				Level:       0.5,
+				triggeredAt: 6,
+				releasedAt:  2,
+			},
+			want: 1,
+		},
+		{
+			name: "decay half way after hold",
+			t:    9,
+			e: &Envelope{
+				Attack:      1,
+				Hold:        1,
+				Decay:       2,
+				Peak:        1,
+				Level:       0.5,
+				triggeredAt: 6,
+				releasedAt:  2,
+			},
+			want: 0.75,
+		},
 		{
 			name: "sustain",
 			t:    10,
@@ -239,6 +269,9 @@ func TestEnvelope_Step(t *testing.T) {
 		wantReleasedAt  float64
 		wantGateValue   float64
 		wantLevel       float64
+		wantStart       float64
+		wantVelocity    float64
+		wantLegato      bool
 	}{
 		{
 			name: "first trigger",
@@ -260,6 +293,7 @@ func TestEnvelope_Step(t *testing.T) {
 			want:            0,
 			wantTriggeredAt: 2,
 			wantGateValue:   1,
+			wantVelocity:    1,
 		},
 		{
 			name: "release",
@@ -341,6 +375,179 @@ func TestEnvelope_Step(t *testing.T) {
 			wantReleasedAt:  0,
 			wantGateValue:   1,
 		},
+		{
+			name: "retrigger during release starts from zero",
+			e: &Envelope{
+				Module: Module{
+					current: Output{
+						Mono: 0.4,
+					},
+				},
+				Gate:        "gate",
+				Attack:      1,
+				Release:     2,
+				Peak:        1,
+				triggeredAt: 2,
+				releasedAt:  5,
+				gateValue:   -1,
+				level:       0.5,
+			},
+			t: 6,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:            0,
+			wantTriggeredAt: 6,
+			wantReleasedAt:  5,
+			wantGateValue:   1,
+			wantLevel:       0.5,
+			wantVelocity:    1,
+		},
+		{
+			name: "retrigger during release starts from current level",
+			e: &Envelope{
+				Module: Module{
+					current: Output{
+						Mono: 0.4,
+					},
+				},
+				Gate:        "gate",
+				Attack:      1,
+				Release:     2,
+				Peak:        1,
+				Retrigger:   retriggerCurrent,
+				triggeredAt: 2,
+				releasedAt:  5,
+				gateValue:   -1,
+				level:       0.5,
+			},
+			t: 6,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:            0.4,
+			wantTriggeredAt: 6,
+			wantReleasedAt:  5,
+			wantGateValue:   1,
+			wantLevel:       0.5,
+			wantStart:       0.4,
+			wantVelocity:    1,
+		},
+		{
+			name: "legato retrigger during release",
+			e: &Envelope{
+				Module: Module{
+					current: Output{
+						Mono: 0.4,
+					},
+				},
+				Gate:        "gate",
+				Attack:      1,
+				Decay:       2,
+				Release:     2,
+				Peak:        1,
+				Level:       0.75,
+				Retrigger:   retriggerLegato,
+				triggeredAt: 2,
+				releasedAt:  5,
+				gateValue:   -1,
+				level:       0.5,
+			},
+			t: 6,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:            0.4,
+			wantTriggeredAt: 6,
+			wantReleasedAt:  5,
+			wantGateValue:   1,
+			wantLevel:       0.5,
+			wantStart:       0.4,
+			wantVelocity:    1,
+			wantLegato:      true,
+		},
+		{
+			name: "legato trigger after release",
+			e: &Envelope{
+				Gate:        "gate",
+				Attack:      1,
+				Decay:       2,
+				Release:     2,
+				Peak:        1,
+				Retrigger:   retriggerLegato,
+				triggeredAt: 2,
+				releasedAt:  5,
+				gateValue:   -1,
+				level:       0.5,
+			},
+			t: 8,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:            0,
+			wantTriggeredAt: 8,
+			wantReleasedAt:  5,
+			wantGateValue:   1,
+			wantLevel:       0.5,
+			wantVelocity:    1,
+		},
+		{
+			name: "gate value sets velocity",
+			e: &Envelope{
+				Gate:     "gate",
+				Decay:    1,
+				Peak:     1,
+				Velocity: true,
+			},
+			t: 2,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 0.5,
+					},
+				},
+			}),
+			want:            0.5,
+			wantTriggeredAt: 2,
+			wantGateValue:   0.5,
+			wantVelocity:    0.5,
+		},
+		{
+			name: "gate value is ignored without velocity",
+			e: &Envelope{
+				Gate:  "gate",
+				Decay: 1,
+				Peak:  1,
+			},
+			t: 2,
+			modules: NewModuleMap(map[string]IModule{
+				"gate": &Module{
+					current: Output{
+						Mono: 0.5,
+					},
+				},
+			}),
+			want:            1,
+			wantTriggeredAt: 2,
+			wantGateValue:   0.5,
+			wantVelocity:    0.5,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -360,10 +567,46 @@ func TestEnvelope_Step(t *testing.T) {
 			if tt.e.level != tt.wantLevel {
 				t.Errorf("Envelope.Step() level = %v, want %v", tt.e.level, tt.wantLevel)
 			}
+			if tt.e.start != tt.wantStart {
+				t.Errorf("Envelope.Step() start = %v, want %v", tt.e.start, tt.wantStart)
+			}
+			if tt.e.velocity != tt.wantVelocity {
+				t.Errorf("Envelope.Step() velocity = %v, want %v", tt.e.velocity, tt.wantVelocity)
+			}
+			if tt.e.legato != tt.wantLegato {
+				t.Errorf("Envelope.Step() legato = %v, want %v", tt.e.legato, tt.wantLegato)
+			}
 		})
 	}
 }
 
+func TestEnvelope_end(t *testing.T) {
+	e := &Envelope{Gate: "gate", Attack: 0.01, Decay: 0.01, Release: 0.025, Peak: 1, Level: 0.5}
+	if err := e.initialize(100); err != nil {
+		t.Fatalf("Envelope.initialize() error = %v", err)
+	}
+
+	gate := &Module{current: monoOutput(-1)}
+	modules := NewModuleMap(map[string]IModule{"gate": gate, "env": e})
+
+	// the gate opens at sample 10 and closes at sample 50, so the release finishes at sample 53
+	var ends []int
+	for i := range 100 {
+		gate.current = monoOutput(-1)
+		if i >= 10 && i < 50 {
+			gate.current = monoOutput(1)
+		}
+		e.Step(float64(i)/100, modules)
+		if getMono(modules, "env.end") > 0 {
+			ends = append(ends, i)
+		}
+	}
+
+	if diff := cmp.Diff([]int{53}, ends); diff != "" {
+		t.Errorf("Envelope.Step() end diff = %s", diff)
+	}
+}
+
 func Test_linear(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -420,6 +663,103 @@ func Test_linear(t *testing.T) {
 	}
 }
 
+func Test_curve(t *testing.T) {
+	tests := []struct {
+		name        string
+		startValue  float64
+		targetValue float64
+		curvature   envelopeCurve
+		t           float64
+		want        float64
+	}{
+		{
+			name:        "linear",
+			startValue:  0,
+			targetValue: 1,
+			curvature:   curveLinear,
+			t:           0.5,
+			want:        0.5,
+		},
+		{
+			name:        "exponential rise starts slow",
+			startValue:  0,
+			targetValue: 1,
+			curvature:   curveExponential,
+			t:           0.5,
+			want:        math.Expm1(2.5) / math.Expm1(5),
+		},
+		{
+			name:        "exponential fall starts fast",
+			startValue:  1,
+			targetValue: 0,
+			curvature:   curveExponential,
+			t:           0.5,
+			want:        1 - math.Expm1(-2.5)/math.Expm1(-5),
+		},
+		{
+			name:        "logarithmic rise starts fast",
+			startValue:  0,
+			targetValue: 1,
+			curvature:   curveLogarithmic,
+			t:           0.5,
+			want:        math.Expm1(-2.5) / math.Expm1(-5),
+		},
+		{
+			name:        "curved segment ends at target",
+			startValue:  0.8,
+			targetValue: 0.2,
+			curvature:   3,
+			t:           1,
+			want:        0.2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := curve(0, 1, tt.startValue, tt.targetValue, tt.curvature, tt.t)
+			if math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("curve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_envelopeCurve_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    envelopeCurve
+		wantErr bool
+	}{
+		{
+			name: "name",
+			data: "exponential",
+			want: curveExponential,
+		},
+		{
+			name: "curvature",
+			data: "-2.5",
+			want: -2.5,
+		},
+		{
+			name:    "unknown name",
+			data:    "cubic",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got envelopeCurve
+			err := yaml.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envelopeCurve.UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("envelopeCurve.UnmarshalYAML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEnvelope_Update(t *testing.T) {
 	sampleRate := 44100.0
 
@@ -539,6 +879,59 @@ func TestEnvelope_Update(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "update velocity and retrigger",
+			e: &Envelope{
+				Gate:        "gate",
+				triggeredAt: 1,
+				start:       0.5,
+				velocity:    0.5,
+				legato:      true,
+			},
+			new: &Envelope{
+				Velocity:  true,
+				Retrigger: retriggerLegato,
+				Gate:      "gate",
+			},
+			want: &Envelope{
+				Velocity:    true,
+				Retrigger:   retriggerLegato,
+				Gate:        "gate",
+				triggeredAt: 1,
+				start:       0.5,
+				velocity:    0.5,
+				legato:      true,
+			},
+		},
+		{
+			name: "update hold and curves",
+			e: &Envelope{
+				Hold:              1,
+				AttackCurve:       curveExponential,
+				sampleRate:        sampleRate,
+				holdFader:         &fader{current: 1, target: 1},
+				attackCurveFader:  &fader{current: 5, target: 5},
+				decayCurveFader:   &fader{},
+				releaseCurveFader: &fader{},
+			},
+			new: &Envelope{
+				Hold:         2,
+				AttackCurve:  curveLinear,
+				DecayCurve:   curveExponential,
+				ReleaseCurve: curveLogarithmic,
+				Fade:         1,
+			},
+			want: &Envelope{
+				Hold:              1,
+				AttackCurve:       curveExponential,
+				Fade:              1,
+				sampleRate:        sampleRate,
+				holdFader:         &fader{current: 1, target: 2, step: 1 / sampleRate},
+				attackCurveFader:  &fader{current: 5, target: 0, step: -5 / sampleRate},
+				decayCurveFader:   &fader{target: 5, step: 5 / sampleRate},
+				releaseCurveFader: &fader{target: -5, step: -5 / sampleRate},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,209 @@
+package module
+
+import (
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Euclid is a gate that distributes pulses as evenly as possible over a number of steps
+	// it advances by its own tempo, by the clock of the patch if it is synced or by each trigger
+	Euclid struct {
+		Module
+		Steps       int      `yaml:"steps"`
+		Pulses      int      `yaml:"pulses"`
+		Rotation    int      `yaml:"rotation"`
+		BPM         float64  `yaml:"bpm"`
+		Sync        Division `yaml:"sync"`
+		Width       float64  `yaml:"width"`
+		Trigger     string   `yaml:"trigger"`
+		CV          string   `yaml:"cv"`
+		Mod         string   `yaml:"mod"`
+		StepsCV     string   `yaml:"steps-cv"`
+		StepsMod    string   `yaml:"steps-mod"`
+		PulsesCV    string   `yaml:"pulses-cv"`
+		PulsesMod   string   `yaml:"pulses-mod"`
+		RotationCV  string   `yaml:"rotation-cv"`
+		RotationMod string   `yaml:"rotation-mod"`
+		Fade        float64  `yaml:"fade"`
+
+		sampleRate   float64
+		idx          float64
+		triggerValue float64
+		clock        *Clock
+
+		bpmFader *fader
+	}
+
+	EuclidMap map[string]*Euclid
+)
+
+func (m EuclidMap) Initialize(sampleRate float64) {
+	for _, e := range m {
+		if e == nil {
+			continue
+		}
+		e.initialize(sampleRate)
+	}
+}
+
+func (e *Euclid) initialize(sampleRate float64) {
+	e.sampleRate = sampleRate
+	e.Steps = int(calc.Limit(float64(e.Steps), stepsRange))
+	e.Pulses = int(calc.Limit(float64(e.Pulses), stepsRange))
+	e.Rotation = int(calc.Limit(float64(e.Rotation), stepsRange))
+	e.BPM = calc.Limit(e.BPM, bpmRange)
+	e.Sync = Division(calc.Limit(float64(e.Sync), divisionRange))
+	if e.Width == 0 {
+		e.Width = defaultWidth
+	}
+	e.Width = calc.Limit(e.Width, widthRange)
+	e.Fade = calc.Limit(e.Fade, fadeRange)
+	e.current = monoOutput(-1)
+
+	// the first trigger plays the first step
+	if e.Trigger != "" {
+		e.idx = -1
+	}
+
+	e.bpmFader = &fader{
+		current: e.BPM,
+		target:  e.BPM,
+	}
+	e.bpmFader.initialize(e.Fade, sampleRate)
+}
+
+func (e *Euclid) Update(new *Euclid) {
+	if new == nil {
+		return
+	}
+
+	e.Steps = new.Steps
+	e.Pulses = new.Pulses
+	e.Rotation = new.Rotation
+	e.Sync = new.Sync
+	e.Width = new.Width
+	e.Trigger = new.Trigger
+	e.CV = new.CV
+	e.Mod = new.Mod
+	e.StepsCV = new.StepsCV
+	e.StepsMod = new.StepsMod
+	e.PulsesCV = new.PulsesCV
+	e.PulsesMod = new.PulsesMod
+	e.RotationCV = new.RotationCV
+	e.RotationMod = new.RotationMod
+	e.Fade = new.Fade
+
+	if e.bpmFader != nil {
+		e.bpmFader.target = new.BPM
+		e.bpmFader.initialize(e.Fade, e.sampleRate)
+	}
+}
+
+// SetClock syncs the euclid to the clock, if sync is set
+func (e *Euclid) SetClock(c *Clock) {
+	e.clock = c
+}
+
+func (e *Euclid) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"trigger":      e.Trigger,
+		"cv":           e.CV,
+		"mod":          e.Mod,
+		"steps-cv":     e.StepsCV,
+		"steps-mod":    e.StepsMod,
+		"pulses-cv":    e.PulsesCV,
+		"pulses-mod":   e.PulsesMod,
+		"rotation-cv":  e.RotationCV,
+		"rotation-mod": e.RotationMod,
+	})
+}
+
+func (e *Euclid) RenameInputs(rename func(string) string) {
+	e.Trigger = rename(e.Trigger)
+	e.CV = rename(e.CV)
+	e.Mod = rename(e.Mod)
+	e.StepsCV = rename(e.StepsCV)
+	e.StepsMod = rename(e.StepsMod)
+	e.PulsesCV = rename(e.PulsesCV)
+	e.PulsesMod = rename(e.PulsesMod)
+	e.RotationCV = rename(e.RotationCV)
+	e.RotationMod = rename(e.RotationMod)
+}
+
+func (e *Euclid) Step(modules *ModuleMap) {
+	steps := e.count(modules, e.Steps, e.StepsCV, e.StepsMod)
+	pulses := e.count(modules, e.Pulses, e.PulsesCV, e.PulsesMod)
+	rotation := e.count(modules, e.Rotation, e.RotationCV, e.RotationMod)
+
+	// with a trigger the gate of a pulse is open while the trigger is, otherwise it is open for the width of a step
+	var open bool
+	switch {
+	case e.Trigger != "":
+		triggerValue := getMono(modules, e.Trigger)
+		if triggerValue > 0 && e.triggerValue <= 0 {
+			e.idx++
+		}
+		e.triggerValue = triggerValue
+		open = triggerValue > 0 && e.idx >= 0
+	case e.clock != nil && e.Sync > 0:
+		e.idx = e.clock.position(e.Sync)
+		open = e.idx-math.Floor(e.idx) < e.Width
+	default:
+		open = e.idx-math.Floor(e.idx) < e.Width
+	}
+
+	val := -1.0
+	if open && euclid(int(math.Floor(e.idx)), steps, pulses, rotation) {
+		val = 1
+	}
+	e.current = monoOutput(val)
+
+	if e.Trigger == "" && (e.clock == nil || e.Sync == 0) {
+		e.advance(modules)
+	}
+	e.fade()
+}
+
+// advance moves to the next step at the tempo of the euclid
+func (e *Euclid) advance(modules *ModuleMap) {
+	bpm := e.BPM
+	if e.CV != "" {
+		bpm = cv(bpmRange, getMono(modules, e.CV))
+	}
+	bpm = modulate(bpm, bpmRange, getMono(modules, e.Mod))
+
+	spb := samplesPerBeat(e.sampleRate, bpm)
+	if spb == 0 {
+		return
+	}
+	e.idx += 1 / spb
+}
+
+// count returns the value of a parameter of the rhythm given by its static value, cv and modulator
+func (e *Euclid) count(modules *ModuleMap, val int, cvName, modName string) int {
+	x := float64(val)
+	if cvName != "" {
+		x = cv(stepsRange, getMono(modules, cvName))
+	}
+	x = modulate(x, stepsRange, getMono(modules, modName))
+	return int(math.Round(x))
+}
+
+func (e *Euclid) fade() {
+	if e.bpmFader != nil {
+		e.BPM = e.bpmFader.fade()
+	}
+}
+
+// euclid reports whether a step of the rhythm is a pulse, the rhythm is rotated to the left by rotation
+// pulses are spread like a line is drawn on a grid, which results in the same rhythms as Bjorklund's algorithm up to rotation
+func euclid(step, steps, pulses, rotation int) bool {
+	if steps <= 0 || pulses <= 0 || step < 0 {
+		return false
+	}
+	pulses = min(pulses, steps)
+	i := (step + rotation) % steps
+	return i*pulses%steps < pulses
+}
@@ -0,0 +1,314 @@
+package module
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/iljarotar/synth/calc"
+)
+
+func Test_euclid(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    int
+		pulses   int
+		rotation int
+		want     string
+	}{
+		{
+			name:   "tresillo",
+			steps:  8,
+			pulses: 3,
+			want:   "x..x..x.",
+		},
+		{
+			name:   "cinquillo",
+			steps:  8,
+			pulses: 5,
+			want:   "x.x.xx.x",
+		},
+		{
+			name:     "rotated",
+			steps:    8,
+			pulses:   3,
+			rotation: 1,
+			want:     "..x..x.x",
+		},
+		{
+			name:     "rotated by more than one cycle",
+			steps:    4,
+			pulses:   1,
+			rotation: 5,
+			want:     "...x",
+		},
+		{
+			name:   "four on the floor",
+			steps:  16,
+			pulses: 4,
+			want:   "x...x...x...x...",
+		},
+		{
+			name:   "more pulses than steps",
+			steps:  4,
+			pulses: 6,
+			want:   "xxxx",
+		},
+		{
+			name:   "no pulses",
+			steps:  4,
+			pulses: 0,
+			want:   "....",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strings.Builder
+			for step := range tt.steps {
+				if euclid(step, tt.steps, tt.pulses, tt.rotation) {
+					got.WriteString("x")
+				} else {
+					got.WriteString(".")
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("euclid() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEuclid_Step(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name    string
+		e       *Euclid
+		modules *ModuleMap
+		want    float64
+		wantIdx float64
+	}{
+		{
+			name: "pulse",
+			e: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				BPM:        60,
+				Width:      0.5,
+				sampleRate: sampleRate,
+				idx:        3,
+			},
+			modules: &ModuleMap{},
+			want:    1,
+			wantIdx: 3 + 1/sampleRate,
+		},
+		{
+			name: "pulse closed after width",
+			e: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				BPM:        60,
+				Width:      0.5,
+				sampleRate: sampleRate,
+				idx:        3.5,
+			},
+			modules: &ModuleMap{},
+			want:    -1,
+			wantIdx: 3.5 + 1/sampleRate,
+		},
+		{
+			name: "rest",
+			e: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				BPM:        60,
+				Width:      0.5,
+				sampleRate: sampleRate,
+				idx:        4,
+			},
+			modules: &ModuleMap{},
+			want:    -1,
+			wantIdx: 4 + 1/sampleRate,
+		},
+		{
+			name: "cv and mod",
+			e: &Euclid{
+				Steps:       8,
+				Pulses:      3,
+				BPM:         60,
+				Width:       0.5,
+				PulsesCV:    "cv",
+				RotationMod: "mod",
+				sampleRate:  sampleRate,
+				idx:         1,
+			},
+			modules: NewModuleMap(map[string]IModule{
+				"cv": &Module{
+					current: Output{
+						Mono: calc.Transpose(5, stepsRange, cvRange),
+					},
+				},
+				"mod": &Module{
+					current: Output{
+						Mono: 1.0 / 32,
+					},
+				},
+			}),
+			// five pulses rotated by one step
+			want:    1,
+			wantIdx: 1 + 1/sampleRate,
+		},
+		{
+			name: "trigger",
+			e: &Euclid{
+				Steps:      4,
+				Pulses:     1,
+				Trigger:    "trigger",
+				sampleRate: sampleRate,
+				idx:        -1,
+			},
+			modules: NewModuleMap(map[string]IModule{
+				"trigger": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:    1,
+			wantIdx: 0,
+		},
+		{
+			name: "trigger on a rest",
+			e: &Euclid{
+				Steps:      4,
+				Pulses:     1,
+				Trigger:    "trigger",
+				sampleRate: sampleRate,
+				idx:        0,
+			},
+			modules: NewModuleMap(map[string]IModule{
+				"trigger": &Module{
+					current: Output{
+						Mono: 1,
+					},
+				},
+			}),
+			want:    -1,
+			wantIdx: 1,
+		},
+		{
+			name: "synced",
+			e: &Euclid{
+				Steps:      4,
+				Pulses:     2,
+				BPM:        60,
+				Sync:       0.25,
+				Width:      0.5,
+				sampleRate: sampleRate,
+				clock:      &Clock{BPM: 120, bars: 0.5},
+			},
+			modules: &ModuleMap{},
+			want:    1,
+			wantIdx: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.e.Step(tt.modules)
+
+			if tt.e.current.Mono != tt.want {
+				t.Errorf("Euclid.Step() = %v, want %v", tt.e.current.Mono, tt.want)
+			}
+			if tt.e.idx != tt.wantIdx {
+				t.Errorf("Euclid.Step() idx = %v, want %v", tt.e.idx, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestEuclid_Update(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name string
+		e    *Euclid
+		new  *Euclid
+		want *Euclid
+	}{
+		{
+			name: "no update necessary",
+			e: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				sampleRate: sampleRate,
+				bpmFader:   &fader{},
+			},
+			new: nil,
+			want: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				sampleRate: sampleRate,
+				bpmFader:   &fader{},
+			},
+		},
+		{
+			name: "update all",
+			e: &Euclid{
+				Steps:      8,
+				Pulses:     3,
+				BPM:        120,
+				Width:      0.5,
+				sampleRate: sampleRate,
+				idx:        5,
+				bpmFader:   &fader{current: 120, target: 120},
+			},
+			new: &Euclid{
+				Steps:       16,
+				Pulses:      5,
+				Rotation:    2,
+				BPM:         240,
+				Sync:        0.125,
+				Width:       0.25,
+				Trigger:     "trigger",
+				CV:          "cv",
+				Mod:         "mod",
+				StepsCV:     "steps-cv",
+				StepsMod:    "steps-mod",
+				PulsesCV:    "pulses-cv",
+				PulsesMod:   "pulses-mod",
+				RotationCV:  "rotation-cv",
+				RotationMod: "rotation-mod",
+				Fade:        1,
+			},
+			want: &Euclid{
+				Steps:       16,
+				Pulses:      5,
+				Rotation:    2,
+				BPM:         120,
+				Sync:        0.125,
+				Width:       0.25,
+				Trigger:     "trigger",
+				CV:          "cv",
+				Mod:         "mod",
+				StepsCV:     "steps-cv",
+				StepsMod:    "steps-mod",
+				PulsesCV:    "pulses-cv",
+				PulsesMod:   "pulses-mod",
+				RotationCV:  "rotation-cv",
+				RotationMod: "rotation-mod",
+				Fade:        1,
+				sampleRate:  sampleRate,
+				idx:         5,
+				bpmFader:    &fader{current: 120, target: 240, step: 120 / sampleRate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.e.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.e, cmp.AllowUnexported(Module{}, Euclid{}, fader{})); diff != "" {
+				t.Errorf("Euclid.Update() diff = %s", diff)
+			}
+		})
+	}
+}
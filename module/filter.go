@@ -8,36 +8,73 @@ import (
 )
 
 type (
+	// Filter is a state variable filter in the topology-preserving transform, so it stays stable under fast modulation
 	Filter struct {
 		Module
 		Type  filterType `yaml:"type"`
 		Freq  float64    `yaml:"freq"`
 		Width float64    `yaml:"width"`
+		Q     float64    `yaml:"q"`
+		Gain  float64    `yaml:"gain"`
 		CV    string     `yaml:"cv"`
 		Mod   string     `yaml:"mod"`
+		QCV   string     `yaml:"q-cv"`
+		QMod  string     `yaml:"q-mod"`
 		In    string     `yaml:"in"`
 		Fade  float64    `yaml:"fade"`
 
-		sampleRate             float64
-		a0, a1, a2, b0, b1, b2 float64
-		inputs                 filterInputs
+		sampleRate float64
+		coeffs     filterCoeffs
+		// params holds the parameters the coefficients were calculated for
+		params filterParams
+		// state holds the states of the left and the right channel
+		state [2]filterState
+		// low, band and high are the responses of the state variable filter, which are available as ports
+		low, band, high Output
 
 		freqFader  *fader
 		widthFader *fader
+		qFader     *fader
+		gainFader  *fader
 	}
 
 	FilterMap  map[string]*Filter
 	filterType string
 
-	filterInputs struct {
-		x0, x1, x2, y0, y1 float64
+	filterParams struct {
+		freq, q, gain float64
+	}
+
+	// filterCoeffs hold the gains of the integrators a1, a2, a3, the amounts of input, band and low pass in the output m0, m1, m2
+	// and the damping k
+	filterCoeffs struct {
+		a1, a2, a3, m0, m1, m2, k float64
+	}
+
+	// filterResponse holds the output of a filter along with the low, band and high pass responses it is mixed from
+	filterResponse struct {
+		out, low, band, high float64
+	}
+
+	// filterState holds the states of both integrators
+	filterState struct {
+		ic1eq, ic2eq float64
 	}
 )
 
 const (
-	filterTypeLowPass  filterType = "LowPass"
-	filterTypeHighPass filterType = "HighPass"
-	filterTypeBandPass filterType = "BandPass"
+	filterTypeLowPass   filterType = "LowPass"
+	filterTypeHighPass  filterType = "HighPass"
+	filterTypeBandPass  filterType = "BandPass"
+	filterTypeNotch     filterType = "Notch"
+	filterTypePeak      filterType = "Peak"
+	filterTypeLowShelf  filterType = "LowShelf"
+	filterTypeHighShelf filterType = "HighShelf"
+	filterTypeAllPass   filterType = "AllPass"
+
+	defaultQ = 1 / math.Sqrt2
+	// the cutoff must stay below the nyquist frequency
+	maxCutoff = 0.49
 )
 
 func (m FilterMap) Initialize(sampleRate float64) error {
@@ -53,14 +90,21 @@ func (m FilterMap) Initialize(sampleRate float64) error {
 }
 
 func (f *Filter) initialize(sampleRate float64) error {
-	if err := validateFilterType(f.Type); err != nil {
-		return err
-	}
-
 	f.sampleRate = sampleRate
 	f.Freq = calc.Limit(f.Freq, freqRange)
+	f.Width = calc.Limit(f.Width, freqRange)
+	if f.Q == 0 {
+		f.Q = defaultQ
+	}
+	f.Q = calc.Limit(f.Q, qRange)
+	f.Gain = calc.Limit(f.Gain, filterGainRange)
 	f.Fade = calc.Limit(f.Fade, fadeRange)
 
+	// values are limited before the type is validated, so that all problems can be reported at once
+	if err := validateFilterType(f.Type); err != nil {
+		return &FieldError{Field: "type", Err: err}
+	}
+
 	f.freqFader = &fader{
 		current: f.Freq,
 		target:  f.Freq,
@@ -69,9 +113,17 @@ func (f *Filter) initialize(sampleRate float64) error {
 		current: f.Width,
 		target:  f.Width,
 	}
+	f.qFader = &fader{
+		current: f.Q,
+		target:  f.Q,
+	}
+	f.gainFader = &fader{
+		current: f.Gain,
+		target:  f.Gain,
+	}
 	f.initializeFaders()
 
-	f.calculateCoeffs(f.Freq)
+	f.calculateCoeffs(filterParams{freq: f.Freq, q: f.q(f.Freq, f.Q), gain: f.Gain})
 
 	return nil
 }
@@ -84,15 +136,13 @@ func (f *Filter) Update(new *Filter) {
 	f.Type = new.Type
 	f.CV = new.CV
 	f.Mod = new.Mod
+	f.QCV = new.QCV
+	f.QMod = new.QMod
 	f.In = new.In
 	f.Fade = new.Fade
 
-	f.a0 = new.a0
-	f.a1 = new.a1
-	f.a2 = new.a2
-	f.b0 = new.b0
-	f.b1 = new.b1
-	f.b2 = new.b2
+	f.coeffs = new.coeffs
+	f.params = new.params
 
 	if f.freqFader != nil {
 		f.freqFader.target = new.Freq
@@ -100,9 +150,33 @@ func (f *Filter) Update(new *Filter) {
 	if f.widthFader != nil {
 		f.widthFader.target = new.Width
 	}
+	if f.qFader != nil {
+		f.qFader.target = new.Q
+	}
+	if f.gainFader != nil {
+		f.gainFader.target = new.Gain
+	}
 	f.initializeFaders()
 }
 
+func (f *Filter) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":    f.In,
+		"cv":    f.CV,
+		"mod":   f.Mod,
+		"q-cv":  f.QCV,
+		"q-mod": f.QMod,
+	})
+}
+
+func (f *Filter) RenameInputs(rename func(string) string) {
+	f.In = rename(f.In)
+	f.CV = rename(f.CV)
+	f.Mod = rename(f.Mod)
+	f.QCV = rename(f.QCV)
+	f.QMod = rename(f.QMod)
+}
+
 func (f *Filter) Step(modules *ModuleMap) {
 	freq := f.Freq
 	if f.CV != "" {
@@ -110,96 +184,114 @@ func (f *Filter) Step(modules *ModuleMap) {
 	}
 	freq = modulate(freq, freqRange, getMono(modules, f.Mod))
 
-	f.calculateCoeffs(freq)
-	x := getMono(modules, f.In)
-	y := calc.Limit(f.tap(x, freq), outputRange)
+	q := f.Q
+	if f.QCV != "" {
+		q = cv(qRange, getMono(modules, f.QCV))
+	}
+	q = modulate(q, qRange, getMono(modules, f.QMod))
 
-	f.current = Output{
-		Mono:  y,
-		Left:  y / 2,
-		Right: y / 2,
+	params := filterParams{freq: freq, q: f.q(freq, q), gain: f.Gain}
+	if params != f.params {
+		f.calculateCoeffs(params)
 	}
 
+	left, right := getOutput(modules, f.In).channels()
+	l := f.tap(left, &f.state[0])
+	r := f.tap(right, &f.state[1])
+	f.current = stereoOutput(calc.Limit(l.out, outputRange), calc.Limit(r.out, outputRange))
+	f.low = stereoOutput(calc.Limit(l.low, outputRange), calc.Limit(r.low, outputRange))
+	f.band = stereoOutput(calc.Limit(l.band, outputRange), calc.Limit(r.band, outputRange))
+	f.high = stereoOutput(calc.Limit(l.high, outputRange), calc.Limit(r.high, outputRange))
+
 	f.fade()
 }
 
-func (f *Filter) tap(x, freq float64) float64 {
-	y := f.getY(freq, f.inputs)
+// Ports returns the low, band and high pass responses, which the filter provides regardless of its type
+func (f *Filter) Ports() []string {
+	return []string{"lp", "bp", "hp"}
+}
 
-	inputs := filterInputs{
-		x0: f.inputs.x1,
-		x1: f.inputs.x2,
-		x2: x,
-		y0: f.inputs.y1,
-		y1: y,
+func (f *Filter) Port(name string) (Output, bool) {
+	switch name {
+	case "lp":
+		return f.low, true
+	case "bp":
+		return f.band, true
+	case "hp":
+		return f.high, true
+	default:
+		return Output{}, false
 	}
-	f.inputs = inputs
-
-	return y
 }
 
-func (f *Filter) getY(freq float64, inputs filterInputs) float64 {
-	if freq == 0 {
-		return 0
+// q returns the quality of the filter, which for a band pass with a width is given by the width
+func (f *Filter) q(freq, q float64) float64 {
+	if f.Type == filterTypeBandPass && f.Width > 0 {
+		return calc.Limit(freq/f.Width, qRange)
 	}
+	return q
+}
 
-	return (f.b0/f.a0)*inputs.x2 + (f.b1/f.a0)*inputs.x1 + (f.b2/f.a0)*inputs.x0 - (f.a1/f.a0)*inputs.y1 - (f.a2/f.a0)*inputs.y0
+func (f *Filter) tap(x float64, state *filterState) filterResponse {
+	var (
+		c  = f.coeffs
+		v3 = x - state.ic2eq
+		v1 = c.a1*state.ic1eq + c.a2*v3
+		v2 = state.ic2eq + c.a2*state.ic1eq + c.a3*v3
+	)
+	state.ic1eq = 2*v1 - state.ic1eq
+	state.ic2eq = 2*v2 - state.ic2eq
+
+	return filterResponse{
+		out:  c.m0*x + c.m1*v1 + c.m2*v2,
+		low:  v2,
+		band: c.k * v1,
+		high: x - c.k*v1 - v2,
+	}
 }
 
-func (f *Filter) calculateCoeffs(freq float64) {
+// calculateCoeffs follows Andrew Simper's linear trapezoidal state variable filter
+func (f *Filter) calculateCoeffs(params filterParams) {
+	f.params = params
+
+	var (
+		g = math.Tan(math.Pi * math.Min(params.freq/f.sampleRate, maxCutoff))
+		k = 1 / params.q
+		// amplitude of the peak and shelf filters
+		a = math.Pow(10, params.gain/40)
+		m filterCoeffs
+	)
+
 	switch f.Type {
 	case filterTypeLowPass:
-		f.calculateLowPassCoeffs(freq)
+		m = filterCoeffs{m0: 0, m1: 0, m2: 1}
 	case filterTypeHighPass:
-		f.calculateHighPassCoeffs(freq)
+		m = filterCoeffs{m0: 1, m1: -k, m2: -1}
 	case filterTypeBandPass:
-		f.calculateBandPassCoeffs(freq, f.Width)
+		// the band is scaled to unity gain at its center
+		m = filterCoeffs{m0: 0, m1: k, m2: 0}
+	case filterTypeNotch:
+		m = filterCoeffs{m0: 1, m1: -k, m2: 0}
+	case filterTypePeak:
+		k = 1 / (params.q * a)
+		m = filterCoeffs{m0: 1, m1: k * (a*a - 1), m2: 0}
+	case filterTypeLowShelf:
+		g /= math.Sqrt(a)
+		m = filterCoeffs{m0: 1, m1: k * (a - 1), m2: a*a - 1}
+	case filterTypeHighShelf:
+		g *= math.Sqrt(a)
+		m = filterCoeffs{m0: a * a, m1: k * (1 - a) * a, m2: 1 - a*a}
+	case filterTypeAllPass:
+		m = filterCoeffs{m0: 1, m1: -2 * k, m2: 0}
 	default:
 		// noop filter type should have been validated before calling this function
 	}
-}
 
-func (f *Filter) calculateLowPassCoeffs(freq float64) {
-	omega := getOmega(freq, f.sampleRate)
-	alpha := getAlphaLPHP(omega)
-	f.b1 = 1 - math.Cos(omega)
-	f.b0 = f.b1 / 2
-	f.b2 = f.b0
-	f.a0 = 1 + alpha
-	f.a1 = -2 * math.Cos(omega)
-	f.a2 = 1 - alpha
-}
-
-func (f *Filter) calculateHighPassCoeffs(freq float64) {
-	omega := getOmega(freq, f.sampleRate)
-	alpha := getAlphaLPHP(omega)
-	f.b0 = (1 + math.Cos(omega)) / 2
-	f.b1 = -(1 + math.Cos(omega))
-	f.b2 = f.b0
-	f.a0 = 1 + alpha
-	f.a1 = -2 * math.Cos(omega)
-	f.a2 = 1 - alpha
-}
-
-func (f *Filter) calculateBandPassCoeffs(freq, width float64) {
-	if freq < width/2 {
-		return
-	}
-
-	var (
-		lowCutoff  = freq - width/2
-		highCutoff = freq + width/2
-	)
-
-	bw := math.Log2(highCutoff / lowCutoff)
-	omega := getOmega(freq, f.sampleRate)
-	alpha := getAlphaBP(omega, bw)
-	f.b0 = alpha
-	f.b1 = 0
-	f.b2 = -alpha
-	f.a0 = 1 + alpha
-	f.a1 = -2 * math.Cos(omega)
-	f.a2 = 1 - alpha
+	m.k = k
+	m.a1 = 1 / (1 + g*(g+k))
+	m.a2 = g * m.a1
+	m.a3 = g * m.a2
+	f.coeffs = m
 }
 
 func (f *Filter) initializeFaders() {
@@ -209,6 +301,12 @@ func (f *Filter) initializeFaders() {
 	if f.widthFader != nil {
 		f.widthFader.initialize(f.Fade, f.sampleRate)
 	}
+	if f.qFader != nil {
+		f.qFader.initialize(f.Fade, f.sampleRate)
+	}
+	if f.gainFader != nil {
+		f.gainFader.initialize(f.Fade, f.sampleRate)
+	}
 }
 
 func (f *Filter) fade() {
@@ -218,28 +316,18 @@ func (f *Filter) fade() {
 	if f.widthFader != nil {
 		f.Width = f.widthFader.fade()
 	}
-}
-
-func getOmega(freq float64, sampleRate float64) float64 {
-	return 2 * math.Pi * (freq / sampleRate)
-}
-
-func getAlphaLPHP(omega float64) float64 {
-	root := math.Sqrt(2)
-	factor := math.Sin(omega) / 2
-	return factor * root
-}
-
-func getAlphaBP(omega, bandwidth float64) float64 {
-	a := math.Log10(2) / 2
-	b := omega / math.Sin(omega)
-	sinh := math.Sinh(a * b * bandwidth)
-	return math.Sin(omega) * sinh
+	if f.qFader != nil {
+		f.Q = f.qFader.fade()
+	}
+	if f.gainFader != nil {
+		f.Gain = f.gainFader.fade()
+	}
 }
 
 func validateFilterType(fType filterType) error {
 	switch fType {
-	case filterTypeLowPass, filterTypeHighPass, filterTypeBandPass:
+	case filterTypeLowPass, filterTypeHighPass, filterTypeBandPass, filterTypeNotch,
+		filterTypePeak, filterTypeLowShelf, filterTypeHighShelf, filterTypeAllPass:
 		return nil
 	default:
 		return fmt.Errorf("unknown filter type %s", fType)
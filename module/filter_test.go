@@ -1,6 +1,7 @@
 package module
 
 import (
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -19,79 +20,93 @@ func TestFilter_Update(t *testing.T) {
 			name: "no update necessary",
 			f: &Filter{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Type:       "BandPass",
 				Freq:       440,
 				Width:      50,
+				Q:          2,
+				Gain:       6,
 				CV:         "cv",
 				Mod:        "mod",
+				QCV:        "q-cv",
+				QMod:       "q-mod",
 				In:         "in",
 				Fade:       1,
 				sampleRate: sampleRate,
-				a0:         1,
-				a1:         1,
-				a2:         1,
-				b0:         1,
-				b1:         1,
-				b2:         1,
-				inputs: filterInputs{
-					x0: 1,
-					x1: 1,
-					x2: 1,
-					y0: 1,
-					y1: 1,
+				coeffs:     filterCoeffs{a1: 1, a2: 1, a3: 1, m0: 1, m1: 1, m2: 1},
+				params:     filterParams{freq: 440, q: 8.8, gain: 6},
+				state: [2]filterState{
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
 				},
 				freqFader: &fader{
 					current: 440,
 					target:  440,
-					step:    0,
 				},
 				widthFader: &fader{
 					current: 50,
 					target:  50,
-					step:    0,
+				},
+				qFader: &fader{
+					current: 2,
+					target:  2,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  6,
 				},
 			},
 			new: nil,
 			want: &Filter{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Type:       "BandPass",
 				Freq:       440,
 				Width:      50,
+				Q:          2,
+				Gain:       6,
 				CV:         "cv",
 				Mod:        "mod",
+				QCV:        "q-cv",
+				QMod:       "q-mod",
 				In:         "in",
 				Fade:       1,
 				sampleRate: sampleRate,
-				a0:         1,
-				a1:         1,
-				a2:         1,
-				b0:         1,
-				b1:         1,
-				b2:         1,
-				inputs: filterInputs{
-					x0: 1,
-					x1: 1,
-					x2: 1,
-					y0: 1,
-					y1: 1,
+				coeffs:     filterCoeffs{a1: 1, a2: 1, a3: 1, m0: 1, m1: 1, m2: 1},
+				params:     filterParams{freq: 440, q: 8.8, gain: 6},
+				state: [2]filterState{
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
 				},
 				freqFader: &fader{
 					current: 440,
 					target:  440,
-					step:    0,
 				},
 				widthFader: &fader{
 					current: 50,
 					target:  50,
-					step:    0,
+				},
+				qFader: &fader{
+					current: 2,
+					target:  2,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  6,
 				},
 			},
 		},
@@ -99,83 +114,91 @@ func TestFilter_Update(t *testing.T) {
 			name: "update all",
 			f: &Filter{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Type:       "BandPass",
 				Freq:       440,
 				Width:      50,
+				Q:          2,
+				Gain:       6,
 				CV:         "cv",
 				Mod:        "mod",
+				QCV:        "q-cv",
+				QMod:       "q-mod",
 				In:         "in",
 				Fade:       1,
 				sampleRate: sampleRate,
-				a0:         1,
-				a1:         1,
-				a2:         1,
-				b0:         1,
-				b1:         1,
-				b2:         1,
-				inputs: filterInputs{
-					x0: 1,
-					x1: 1,
-					x2: 1,
-					y0: 1,
-					y1: 1,
+				coeffs:     filterCoeffs{a1: 1, a2: 1, a3: 1, m0: 1, m1: 1, m2: 1},
+				params:     filterParams{freq: 440, q: 8.8, gain: 6},
+				state: [2]filterState{
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
 				},
 				freqFader: &fader{
 					current: 440,
 					target:  440,
-					step:    0,
 				},
 				widthFader: &fader{
 					current: 50,
 					target:  50,
-					step:    0,
+				},
+				qFader: &fader{
+					current: 2,
+					target:  2,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  6,
 				},
 			},
 			new: &Filter{
-				Type:  "LowPass",
-				Freq:  220,
-				Width: 0,
-				CV:    "new-cv",
-				Mod:   "new-mod",
-				In:    "new-in",
-				Fade:  2,
-				a0:    2,
-				a1:    2,
-				a2:    2,
-				b0:    2,
-				b1:    2,
-				b2:    2,
+				Type:   "LowPass",
+				Freq:   220,
+				Width:  0,
+				Q:      4,
+				Gain:   0,
+				CV:     "new-cv",
+				Mod:    "new-mod",
+				QCV:    "new-q-cv",
+				QMod:   "new-q-mod",
+				In:     "new-in",
+				Fade:   2,
+				coeffs: filterCoeffs{a1: 2, a2: 2, a3: 2, m0: 2, m1: 2, m2: 2},
+				params: filterParams{freq: 220, q: 4},
 			},
 			want: &Filter{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Type:       "LowPass",
 				Freq:       440,
 				Width:      50,
+				Q:          2,
+				Gain:       6,
 				CV:         "new-cv",
 				Mod:        "new-mod",
+				QCV:        "new-q-cv",
+				QMod:       "new-q-mod",
 				In:         "new-in",
 				Fade:       2,
 				sampleRate: sampleRate,
-				a0:         2,
-				a1:         2,
-				a2:         2,
-				b0:         2,
-				b1:         2,
-				b2:         2,
-				inputs: filterInputs{
-					x0: 1,
-					x1: 1,
-					x2: 1,
-					y0: 1,
-					y1: 1,
+				coeffs:     filterCoeffs{a1: 2, a2: 2, a3: 2, m0: 2, m1: 2, m2: 2},
+				params:     filterParams{freq: 220, q: 4},
+				state: [2]filterState{
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
+					{
+						ic1eq: 1,
+						ic2eq: 1,
+					},
 				},
 				freqFader: &fader{
 					current: 440,
@@ -187,13 +210,23 @@ func TestFilter_Update(t *testing.T) {
 					target:  0,
 					step:    -25 / sampleRate,
 				},
+				qFader: &fader{
+					current: 2,
+					target:  4,
+					step:    1 / sampleRate,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  0,
+					step:    -3 / sampleRate,
+				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.f.Update(tt.new)
-			if diff := cmp.Diff(tt.want, tt.f, cmp.AllowUnexported(Module{}, Filter{}, fader{}, filterInputs{})); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.f, cmp.AllowUnexported(Module{}, Filter{}, fader{}, filterCoeffs{}, filterParams{}, filterState{})); diff != "" {
 				t.Errorf("Filter.Update() diff = %s", diff)
 			}
 		})
@@ -211,6 +244,8 @@ func TestFilter_fade(t *testing.T) {
 			f: &Filter{
 				Freq:  440,
 				Width: 50,
+				Q:     2,
+				Gain:  6,
 				freqFader: &fader{
 					current: 440,
 					target:  440,
@@ -221,10 +256,22 @@ func TestFilter_fade(t *testing.T) {
 					target:  50,
 					step:    10,
 				},
+				qFader: &fader{
+					current: 2,
+					target:  2,
+					step:    1,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  6,
+					step:    1,
+				},
 			},
 			want: &Filter{
 				Freq:  440,
 				Width: 50,
+				Q:     2,
+				Gain:  6,
 				freqFader: &fader{
 					current: 440,
 					target:  440,
@@ -233,6 +280,14 @@ func TestFilter_fade(t *testing.T) {
 					current: 50,
 					target:  50,
 				},
+				qFader: &fader{
+					current: 2,
+					target:  2,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  6,
+				},
 			},
 		},
 		{
@@ -240,6 +295,8 @@ func TestFilter_fade(t *testing.T) {
 			f: &Filter{
 				Freq:  440,
 				Width: 50,
+				Q:     2,
+				Gain:  6,
 				freqFader: &fader{
 					current: 440,
 					target:  220,
@@ -250,10 +307,22 @@ func TestFilter_fade(t *testing.T) {
 					target:  0,
 					step:    -10,
 				},
+				qFader: &fader{
+					current: 2,
+					target:  4,
+					step:    0.5,
+				},
+				gainFader: &fader{
+					current: 6,
+					target:  0,
+					step:    -2,
+				},
 			},
 			want: &Filter{
 				Freq:  420,
 				Width: 40,
+				Q:     2.5,
+				Gain:  4,
 				freqFader: &fader{
 					current: 420,
 					target:  220,
@@ -264,15 +333,276 @@ func TestFilter_fade(t *testing.T) {
 					target:  0,
 					step:    -10,
 				},
+				qFader: &fader{
+					current: 2.5,
+					target:  4,
+					step:    0.5,
+				},
+				gainFader: &fader{
+					current: 4,
+					target:  0,
+					step:    -2,
+				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.f.fade()
-			if diff := cmp.Diff(tt.want, tt.f, cmp.AllowUnexported(Module{}, Filter{}, fader{}, filterInputs{})); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.f, cmp.AllowUnexported(Module{}, Filter{}, fader{}, filterCoeffs{}, filterParams{}, filterState{})); diff != "" {
 				t.Errorf("Filter.fade() diff = %s", diff)
 			}
 		})
 	}
 }
+
+func TestFilter_response(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name   string
+		f      *Filter
+		inFreq float64
+		// want is the expected gain at inFreq
+		want float64
+	}{
+		{
+			name:   "low pass passes low frequencies",
+			f:      &Filter{Type: "LowPass", Freq: 1000},
+			inFreq: 50,
+			want:   1,
+		},
+		{
+			name:   "low pass is 3dB down at the cutoff",
+			f:      &Filter{Type: "LowPass", Freq: 1000},
+			inFreq: 1000,
+			want:   1 / math.Sqrt2,
+		},
+		{
+			name:   "low pass attenuates 12dB per octave",
+			f:      &Filter{Type: "LowPass", Freq: 1000},
+			inFreq: 4000,
+			want:   0.06,
+		},
+		{
+			name:   "resonance",
+			f:      &Filter{Type: "LowPass", Freq: 1000, Q: 10},
+			inFreq: 1000,
+			want:   10,
+		},
+		{
+			name:   "high pass attenuates low frequencies",
+			f:      &Filter{Type: "HighPass", Freq: 1000},
+			inFreq: 250,
+			want:   0.06,
+		},
+		{
+			name:   "high pass passes high frequencies",
+			f:      &Filter{Type: "HighPass", Freq: 1000},
+			inFreq: 10000,
+			want:   1,
+		},
+		{
+			name:   "band pass has unity gain at its center",
+			f:      &Filter{Type: "BandPass", Freq: 1000, Width: 100},
+			inFreq: 1000,
+			want:   1,
+		},
+		{
+			name:   "band pass attenuates outside of its width",
+			f:      &Filter{Type: "BandPass", Freq: 1000, Width: 100},
+			inFreq: 2000,
+			want:   0.066,
+		},
+		{
+			name:   "notch",
+			f:      &Filter{Type: "Notch", Freq: 1000, Q: 2},
+			inFreq: 1000,
+			want:   0,
+		},
+		{
+			name:   "peak boosts its center",
+			f:      &Filter{Type: "Peak", Freq: 1000, Gain: 12},
+			inFreq: 1000,
+			want:   math.Pow(10, 12.0/20),
+		},
+		{
+			name:   "peak leaves other frequencies",
+			f:      &Filter{Type: "Peak", Freq: 1000, Gain: 12},
+			inFreq: 50,
+			want:   1,
+		},
+		{
+			name:   "low shelf",
+			f:      &Filter{Type: "LowShelf", Freq: 1000, Gain: -12},
+			inFreq: 50,
+			want:   math.Pow(10, -12.0/20),
+		},
+		{
+			name:   "high shelf",
+			f:      &Filter{Type: "HighShelf", Freq: 1000, Gain: 6},
+			inFreq: 15000,
+			want:   math.Pow(10, 6.0/20),
+		},
+		{
+			name:   "all pass",
+			f:      &Filter{Type: "AllPass", Freq: 1000},
+			inFreq: 1000,
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.f.initialize(sampleRate); err != nil {
+				t.Fatal(err)
+			}
+			tt.f.In = "in"
+
+			in := &Module{}
+			modules := NewModuleMap(map[string]IModule{"in": in})
+
+			// the input is attenuated so that the filter's output isn't limited
+			amp := 0.05
+			var peak float64
+			for i := range int(sampleRate) {
+				in.current = monoOutput(amp * math.Sin(2*math.Pi*tt.inFreq*float64(i)/sampleRate))
+				tt.f.Step(modules)
+				// wait for the filter to settle
+				if i > int(sampleRate)/2 {
+					peak = math.Max(peak, math.Abs(tt.f.Current().Mono))
+				}
+			}
+
+			got := peak / amp
+			if math.Abs(got-tt.want) > 0.02*math.Max(tt.want, 1) {
+				t.Errorf("gain = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_ports(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name   string
+		port   string
+		inFreq float64
+		// want is the expected gain of the port at inFreq
+		want float64
+	}{
+		{
+			name:   "low pass passes low frequencies",
+			port:   "lp",
+			inFreq: 50,
+			want:   1,
+		},
+		{
+			name:   "low pass attenuates high frequencies",
+			port:   "lp",
+			inFreq: 4000,
+			want:   0.06,
+		},
+		{
+			name:   "high pass attenuates low frequencies",
+			port:   "hp",
+			inFreq: 250,
+			want:   0.06,
+		},
+		{
+			name:   "high pass passes high frequencies",
+			port:   "hp",
+			inFreq: 10000,
+			want:   1,
+		},
+		{
+			name:   "band pass has unity gain at the cutoff",
+			port:   "bp",
+			inFreq: 1000,
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the ports don't depend on the type of the filter
+			f := &Filter{Type: filterTypeNotch, Freq: 1000, In: "in"}
+			if err := f.initialize(sampleRate); err != nil {
+				t.Fatal(err)
+			}
+
+			in := &Module{}
+			modules := NewModuleMap(map[string]IModule{"in": in, "f": f})
+
+			amp := 0.05
+			var peak float64
+			for i := range int(sampleRate) {
+				in.current = monoOutput(amp * math.Sin(2*math.Pi*tt.inFreq*float64(i)/sampleRate))
+				f.Step(modules)
+				if i > int(sampleRate)/2 {
+					peak = math.Max(peak, math.Abs(getMono(modules, "f."+tt.port)))
+				}
+			}
+
+			got := peak / amp
+			if math.Abs(got-tt.want) > 0.02*math.Max(tt.want, 1) {
+				t.Errorf("gain = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Step_modulation(t *testing.T) {
+	sampleRate := 44100.0
+	f := &Filter{Type: "LowPass", Freq: 1000, Q: 20, In: "in", Mod: "mod"}
+	if err := f.initialize(sampleRate); err != nil {
+		t.Fatal(err)
+	}
+
+	in := &Module{}
+	mod := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in, "mod": mod})
+
+	// sweeping the cutoff at audio rate must not make the filter unstable
+	for i := range int(sampleRate) {
+		in.current = monoOutput(math.Sin(2 * math.Pi * 110 * float64(i) / sampleRate))
+		mod.current = monoOutput(math.Sin(2 * math.Pi * 3000 * float64(i) / sampleRate))
+		f.Step(modules)
+
+		if math.IsNaN(f.state[0].ic1eq) || math.Abs(f.state[0].ic1eq) > 1000 {
+			t.Fatalf("filter state diverged after %d samples: %v", i, f.state)
+		}
+	}
+}
+
+func TestFilter_stereo(t *testing.T) {
+	sampleRate := 44100.0
+
+	stereo := &Filter{Type: filterTypeLowPass, Freq: 500, In: "in"}
+	mono := &Filter{Type: filterTypeLowPass, Freq: 500, In: "in"}
+	for _, f := range []*Filter{stereo, mono} {
+		if err := f.initialize(sampleRate); err != nil {
+			t.Fatalf("Filter.initialize() error = %v", err)
+		}
+	}
+
+	in := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in})
+
+	// the left channel carries a signal, the right channel is silent
+	for i := range 1000 {
+		x := math.Sin(2 * math.Pi * 440 * float64(i) / sampleRate)
+
+		in.current = stereoOutput(x, 0)
+		stereo.Step(modules)
+		in.current = monoOutput(x)
+		mono.Step(modules)
+
+		left, right := stereo.Current().channels()
+		if right != 0 {
+			t.Fatalf("right channel = %v after %d samples, want 0", right, i)
+		}
+		if want := mono.Current().Mono; math.Abs(left-want) > 1e-12 {
+			t.Fatalf("left channel = %v after %d samples, want %v", left, i, want)
+		}
+	}
+}
@@ -10,6 +10,7 @@ type (
 	Gate struct {
 		Module
 		BPM    float64   `yaml:"bpm"`
+		Sync   Division  `yaml:"sync"`
 		CV     string    `yaml:"cv"`
 		Mod    string    `yaml:"mod"`
 		Signal []float64 `yaml:"signal"`
@@ -18,6 +19,7 @@ type (
 
 		sampleRate float64
 		idx        float64
+		clock      *Clock
 
 		bpmFader *fader
 	}
@@ -37,6 +39,7 @@ func (m GateMap) Initialize(sampleRate float64) {
 func (g *Gate) initialize(sampleRate float64) {
 	g.sampleRate = sampleRate
 	g.BPM = calc.Limit(g.BPM, bpmRange)
+	g.Sync = Division(calc.Limit(float64(g.Sync), divisionRange))
 	g.Fade = calc.Limit(g.Fade, fadeRange)
 	g.Index = int(calc.Limit(float64(g.Index), calc.Range{Min: 0, Max: float64(len(g.Signal) - 1)}))
 	g.idx = float64(g.Index)
@@ -61,6 +64,7 @@ func (g *Gate) Update(new *Gate) {
 		return
 	}
 
+	g.Sync = new.Sync
 	g.CV = new.CV
 	g.Mod = new.Mod
 	g.Signal = new.Signal
@@ -72,16 +76,39 @@ func (g *Gate) Update(new *Gate) {
 	}
 }
 
+// SetClock syncs the gate to the clock, if sync is set
+func (g *Gate) SetClock(c *Clock) {
+	g.clock = c
+}
+
+func (g *Gate) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"cv":  g.CV,
+		"mod": g.Mod,
+	})
+}
+
+func (g *Gate) RenameInputs(rename func(string) string) {
+	g.CV = rename(g.CV)
+	g.Mod = rename(g.Mod)
+}
+
 func (g *Gate) Step(modules *ModuleMap) {
 	if len(g.Signal) < 1 {
 		return
 	}
 
+	// a synced gate plays one value per division, counted from the start of the clock
+	synced := g.clock != nil && g.Sync > 0
+	if synced {
+		g.idx = g.clock.position(g.Sync) + float64(g.Index)
+	}
+
 	val := g.Signal[int(math.Floor(g.idx))%len(g.Signal)]
-	g.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
+	g.current = monoOutput(val)
+	if synced {
+		g.fade()
+		return
 	}
 
 	bpm := g.BPM
@@ -106,6 +106,21 @@ func TestGate_Step(t *testing.T) {
 			want:    -1,
 			wantIdx: 2 + 0.5/sampleRate,
 		},
+		{
+			name: "synced",
+			g: &Gate{
+				BPM:        60,
+				Sync:       0.25,
+				Signal:     []float64{-1, 1, 1, -1},
+				Index:      1,
+				sampleRate: sampleRate,
+				idx:        3,
+				clock:      &Clock{BPM: 120, bars: 0.3},
+			},
+			modules: &ModuleMap{},
+			want:    1,
+			wantIdx: 2.2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
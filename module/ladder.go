@@ -0,0 +1,182 @@
+package module
+
+import (
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Ladder is a 24dB per octave low pass filter modeled after the transistor ladder of Moog synthesizers
+	// it is a zero-delay feedback filter with a saturating feedback path, so it keeps oscillating at high resonance without blowing up
+	Ladder struct {
+		Module
+		Freq      float64 `yaml:"freq"`
+		Resonance float64 `yaml:"resonance"`
+		Drive     float64 `yaml:"drive"`
+		CV        string  `yaml:"cv"`
+		Mod       string  `yaml:"mod"`
+		In        string  `yaml:"in"`
+		Fade      float64 `yaml:"fade"`
+
+		sampleRate float64
+		// g is the gain of a single stage, which is only recalculated when freq changes
+		g, gFreq float64
+		// stages holds the states of the left and the right channel
+		stages [2][4]float64
+
+		freqFader      *fader
+		resonanceFader *fader
+		driveFader     *fader
+	}
+
+	LadderMap map[string]*Ladder
+)
+
+// the feedback needed for self-oscillation
+const ladderMaxFeedback = 4
+
+func (m LadderMap) Initialize(sampleRate float64) {
+	for _, l := range m {
+		if l == nil {
+			continue
+		}
+		l.initialize(sampleRate)
+	}
+}
+
+func (l *Ladder) initialize(sampleRate float64) {
+	l.sampleRate = sampleRate
+	l.Freq = calc.Limit(l.Freq, freqRange)
+	l.Resonance = calc.Limit(l.Resonance, resonanceRange)
+	l.Drive = calc.Limit(l.Drive, driveRange)
+	l.Fade = calc.Limit(l.Fade, fadeRange)
+
+	l.freqFader = &fader{
+		current: l.Freq,
+		target:  l.Freq,
+	}
+	l.resonanceFader = &fader{
+		current: l.Resonance,
+		target:  l.Resonance,
+	}
+	l.driveFader = &fader{
+		current: l.Drive,
+		target:  l.Drive,
+	}
+	l.initializeFaders()
+
+	l.calculateGain(l.Freq)
+}
+
+func (l *Ladder) Update(new *Ladder) {
+	if new == nil {
+		return
+	}
+
+	l.CV = new.CV
+	l.Mod = new.Mod
+	l.In = new.In
+	l.Fade = new.Fade
+
+	if l.freqFader != nil {
+		l.freqFader.target = new.Freq
+	}
+	if l.resonanceFader != nil {
+		l.resonanceFader.target = new.Resonance
+	}
+	if l.driveFader != nil {
+		l.driveFader.target = new.Drive
+	}
+	l.initializeFaders()
+}
+
+func (l *Ladder) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":  l.In,
+		"cv":  l.CV,
+		"mod": l.Mod,
+	})
+}
+
+func (l *Ladder) RenameInputs(rename func(string) string) {
+	l.In = rename(l.In)
+	l.CV = rename(l.CV)
+	l.Mod = rename(l.Mod)
+}
+
+func (l *Ladder) Step(modules *ModuleMap) {
+	freq := l.Freq
+	if l.CV != "" {
+		freq = cv(freqRange, getMono(modules, l.CV))
+	}
+	freq = modulate(freq, freqRange, getMono(modules, l.Mod))
+
+	if freq != l.gFreq {
+		l.calculateGain(freq)
+	}
+
+	left, right := getOutput(modules, l.In).channels()
+	l.current = stereoOutput(
+		calc.Limit(l.tap(left*l.Drive, &l.stages[0]), outputRange),
+		calc.Limit(l.tap(right*l.Drive, &l.stages[1]), outputRange),
+	)
+
+	l.fade()
+}
+
+// tap runs four trapezoidal one-pole low passes in series
+// the output is fed back to the input, which is solved linearly first and then saturated
+func (l *Ladder) tap(x float64, stages *[4]float64) float64 {
+	var (
+		g = l.g / (1 + l.g)
+		k = ladderMaxFeedback * l.Resonance
+		// contribution of the stage states to the output
+		sigma float64
+	)
+	for _, s := range stages {
+		sigma = sigma*g + s/(1+l.g)
+	}
+
+	g4 := g * g * g * g
+	estimate := (g4*x + sigma) / (1 + k*g4)
+	u := math.Tanh(x - k*estimate)
+
+	for i, s := range stages {
+		v := (u - s) * g
+		y := v + s
+		stages[i] = y + v
+		u = y
+	}
+
+	return u
+}
+
+func (l *Ladder) calculateGain(freq float64) {
+	l.gFreq = freq
+	l.g = math.Tan(math.Pi * math.Min(freq/l.sampleRate, maxCutoff))
+}
+
+func (l *Ladder) initializeFaders() {
+	if l.freqFader != nil {
+		l.freqFader.initialize(l.Fade, l.sampleRate)
+	}
+	if l.resonanceFader != nil {
+		l.resonanceFader.initialize(l.Fade, l.sampleRate)
+	}
+	if l.driveFader != nil {
+		l.driveFader.initialize(l.Fade, l.sampleRate)
+	}
+}
+
+func (l *Ladder) fade() {
+	if l.freqFader != nil {
+		l.Freq = l.freqFader.fade()
+	}
+	if l.resonanceFader != nil {
+		l.Resonance = l.resonanceFader.fade()
+	}
+	if l.driveFader != nil {
+		l.Drive = l.driveFader.fade()
+	}
+}
@@ -0,0 +1,195 @@
+package module
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLadder_Update(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name string
+		l    *Ladder
+		new  *Ladder
+		want *Ladder
+	}{
+		{
+			name: "no update necessary",
+			l: &Ladder{
+				Freq:       440,
+				Resonance:  0.5,
+				Drive:      2,
+				CV:         "cv",
+				Mod:        "mod",
+				In:         "in",
+				sampleRate: sampleRate,
+				freqFader:  &fader{current: 440, target: 440},
+			},
+			new: nil,
+			want: &Ladder{
+				Freq:       440,
+				Resonance:  0.5,
+				Drive:      2,
+				CV:         "cv",
+				Mod:        "mod",
+				In:         "in",
+				sampleRate: sampleRate,
+				freqFader:  &fader{current: 440, target: 440},
+			},
+		},
+		{
+			name: "update all",
+			l: &Ladder{
+				Freq:           440,
+				Resonance:      0.5,
+				Drive:          2,
+				CV:             "cv",
+				Mod:            "mod",
+				In:             "in",
+				sampleRate:     sampleRate,
+				stages:         [2][4]float64{{1, 1, 1, 1}, {1, 1, 1, 1}},
+				freqFader:      &fader{current: 440, target: 440},
+				resonanceFader: &fader{current: 0.5, target: 0.5},
+				driveFader:     &fader{current: 2, target: 2},
+			},
+			new: &Ladder{
+				Freq:      220,
+				Resonance: 1,
+				Drive:     1,
+				CV:        "new-cv",
+				Mod:       "new-mod",
+				In:        "new-in",
+				Fade:      2,
+			},
+			want: &Ladder{
+				Freq:           440,
+				Resonance:      0.5,
+				Drive:          2,
+				CV:             "new-cv",
+				Mod:            "new-mod",
+				In:             "new-in",
+				Fade:           2,
+				sampleRate:     sampleRate,
+				stages:         [2][4]float64{{1, 1, 1, 1}, {1, 1, 1, 1}},
+				freqFader:      &fader{current: 440, target: 220, step: -110 / sampleRate},
+				resonanceFader: &fader{current: 0.5, target: 1, step: 0.25 / sampleRate},
+				driveFader:     &fader{current: 2, target: 1, step: -0.5 / sampleRate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.l.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.l, cmp.AllowUnexported(Module{}, Ladder{}, fader{})); diff != "" {
+				t.Errorf("Ladder.Update() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestLadder_cutoff(t *testing.T) {
+	sampleRate := 44100.0
+	cutoff := 500.0
+
+	tests := []struct {
+		name   string
+		inFreq float64
+		want   float64
+	}{
+		{
+			name:   "pass band",
+			inFreq: 20,
+			want:   1,
+		},
+		{
+			name:   "each of the four stages is 3dB down at the cutoff",
+			inFreq: cutoff,
+			want:   0.25,
+		},
+		{
+			name:   "one octave above the cutoff",
+			inFreq: 2 * cutoff,
+			want:   1.0 / 25,
+		},
+		{
+			name:   "two octaves above the cutoff",
+			inFreq: 4 * cutoff,
+			want:   1.0 / 289,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Ladder{Freq: cutoff, In: "in"}
+			l.initialize(sampleRate)
+
+			// the input is kept small, where the saturation is negligible
+			amp := 0.001
+			got := measureGain(l, tt.inFreq, amp, sampleRate)
+			if math.Abs(got-tt.want) > 0.05*tt.want {
+				t.Errorf("gain = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLadder_selfOscillation(t *testing.T) {
+	sampleRate := 44100.0
+
+	for _, freq := range []float64{220, 1000, 3000} {
+		l := &Ladder{Freq: freq, Resonance: 1.1, In: "in"}
+		l.initialize(sampleRate)
+
+		in := &Module{}
+		modules := NewModuleMap(map[string]IModule{"in": in})
+
+		// a single impulse excites the filter, afterwards it oscillates on its own
+		in.current = monoOutput(0.1)
+		l.Step(modules)
+		in.current = Output{}
+
+		var (
+			crossings int
+			peak      float64
+			last      = l.Current().Mono
+		)
+		for i := range int(sampleRate) {
+			l.Step(modules)
+			y := l.Current().Mono
+			if i > int(sampleRate)/2 {
+				peak = math.Max(peak, math.Abs(y))
+				if last < 0 && y >= 0 {
+					crossings++
+				}
+			}
+			last = y
+		}
+
+		if peak < 0.1 {
+			t.Errorf("freq %v: peak = %v, filter doesn't oscillate", freq, peak)
+		}
+		// crossings were counted over half a second
+		if got := float64(crossings) * 2; math.Abs(got-freq) > 0.03*freq {
+			t.Errorf("freq %v: oscillation frequency = %v", freq, got)
+		}
+	}
+}
+
+func measureGain(l *Ladder, freq, amp, sampleRate float64) float64 {
+	in := &Module{}
+	modules := NewModuleMap(map[string]IModule{"in": in})
+
+	var peak float64
+	for i := range int(sampleRate) {
+		in.current = monoOutput(amp * math.Sin(2*math.Pi*freq*float64(i)/sampleRate))
+		l.Step(modules)
+		// wait for the filter to settle
+		if i > int(sampleRate)/2 {
+			peak = math.Max(peak, math.Abs(l.Current().Mono))
+		}
+	}
+
+	return peak / amp
+}
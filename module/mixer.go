@@ -76,6 +76,31 @@ func (m *Mixer) Update(new *Mixer) {
 	m.updateGains(new)
 }
 
+func (m *Mixer) Inputs() map[string]string {
+	fields := map[string]string{
+		"cv":  m.CV,
+		"mod": m.Mod,
+	}
+	for name := range m.In {
+		fields["in."+name] = name
+	}
+	return inputs(fields)
+}
+
+func (m *Mixer) RenameInputs(rename func(string) string) {
+	m.CV = rename(m.CV)
+	m.Mod = rename(m.Mod)
+	if m.In == nil {
+		return
+	}
+
+	in := make(map[string]float64, len(m.In))
+	for name, gain := range m.In {
+		in[rename(name)] = gain
+	}
+	m.In = in
+}
+
 func (m *Mixer) Step(modules *ModuleMap) {
 	var (
 		left, right, mono float64
@@ -83,11 +108,10 @@ func (m *Mixer) Step(modules *ModuleMap) {
 
 	for _, name := range m.in.Keys() {
 		gain, _ := m.in.Get(name)
-		if mod, _ := modules.Get(name); mod != nil {
-			left += mod.Current().Left * gain
-			right += mod.Current().Right * gain
-			mono += mod.Current().Mono * gain
-		}
+		out := getOutput(modules, name)
+		left += out.Left * gain
+		right += out.Right * gain
+		mono += out.Mono * gain
 	}
 
 	gain := m.Gain
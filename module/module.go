@@ -1,6 +1,10 @@
 package module
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/iljarotar/synth/calc"
 	"github.com/iljarotar/synth/concurrency"
 )
@@ -8,6 +12,15 @@ import (
 type (
 	IModule interface {
 		Current() Output
+		// Ports lists the names of the outputs a module provides besides its main output
+		// a port is referenced as <module>.<port>, e.g. lp.hp
+		Ports() []string
+		// Port returns the output of a port and whether the module has a port of that name
+		Port(name string) (Output, bool)
+		// Inputs maps the names of all fields referencing other modules to the referenced module names
+		Inputs() map[string]string
+		// RenameInputs replaces all referenced module names by the result of rename
+		RenameInputs(rename func(string) string)
 	}
 
 	ModuleMap = concurrency.SyncMap[string, IModule]
@@ -19,8 +32,33 @@ type (
 	Output struct {
 		Mono, Left, Right float64
 	}
+
+	// FieldError attributes an error to the field of a module that caused it
+	FieldError struct {
+		Field string
+		Err   error
+	}
+
+	// FieldErrors holds the errors of several fields, so that all of them can be reported at once
+	FieldErrors []*FieldError
+
+	// Limiter is implemented by modules that limit values which aren't plain fields, e.g. the modifiers of sequencer steps
+	Limiter interface {
+		// Limited lists the values that were out of range when the module was initialized
+		Limited() []LimitedValue
+	}
+
+	// LimitedValue is a value that was out of range and has been limited
+	LimitedValue struct {
+		Field string
+		Value float64
+		Limit float64
+	}
 )
 
+// defaultPitch is the frequency of a_4 unless a pitch is given, sequencers and quantizers share it so that their notes agree
+const defaultPitch = 440
+
 var (
 	bpmRange = calc.Range{
 		Min: 0,
@@ -74,6 +112,82 @@ var (
 		Min: 0,
 		Max: 1,
 	}
+	qRange = calc.Range{
+		Min: 0.1,
+		Max: 40,
+	}
+	filterGainRange = calc.Range{
+		Min: -24,
+		Max: 24,
+	}
+	resonanceRange = calc.Range{
+		Min: 0,
+		Max: 1.1,
+	}
+	driveRange = calc.Range{
+		Min: 1,
+		Max: 10,
+	}
+	widthRange = calc.Range{
+		Min: 0.01,
+		Max: 0.99,
+	}
+	speedRange = calc.Range{
+		Min: 0.01,
+		Max: 16,
+	}
+	positionRange = calc.Range{
+		Min: 0,
+		Max: 1,
+	}
+	reverbRange = calc.Range{
+		Min: 0,
+		Max: 1,
+	}
+	predelayRange = calc.Range{
+		Min: 0,
+		Max: 500,
+	}
+	chorusDelayRange = calc.Range{
+		Min: 0,
+		Max: 50,
+	}
+	chorusDepthRange = calc.Range{
+		Min: 0,
+		Max: 20,
+	}
+	lfoRange = calc.Range{
+		Min: 0,
+		Max: 20,
+	}
+	feedbackRange = calc.Range{
+		Min: -0.95,
+		Max: 0.95,
+	}
+	mixRange = calc.Range{
+		Min: 0,
+		Max: 1,
+	}
+	curveRange = calc.Range{
+		Min: -10,
+		Max: 10,
+	}
+	divisionRange = calc.Range{
+		Min: 0,
+		Max: 64,
+	}
+	stepsRange = calc.Range{
+		Min: 0,
+		Max: 64,
+	}
+	probabilityRange = calc.Range{
+		Min: 0,
+		Max: 1,
+	}
+	ratchetRange = calc.Range{
+		Min: 1,
+		Max: 16,
+	}
 )
 
 func NewModuleMap(m map[string]IModule) *ModuleMap {
@@ -84,6 +198,20 @@ func (m *Module) Current() Output {
 	return m.current
 }
 
+func (m *Module) Ports() []string {
+	return nil
+}
+
+func (m *Module) Port(name string) (Output, bool) {
+	return Output{}, false
+}
+
+func (m *Module) Inputs() map[string]string {
+	return nil
+}
+
+func (m *Module) RenameInputs(func(string) string) {}
+
 func modulate(x float64, rng calc.Range, mod float64) float64 {
 	if mod == 0 {
 		return x
@@ -100,10 +228,128 @@ func cv(rng calc.Range, val float64) float64 {
 	return calc.Transpose(val, cvRange, rng)
 }
 
+// inputs drops all fields that don't reference any module
+func inputs(fields map[string]string) map[string]string {
+	for field, name := range fields {
+		if name == "" {
+			delete(fields, field)
+		}
+	}
+	return fields
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", err.Field, err.Err)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// limit limits value to rng and records it in limited if it was out of range
+func limit(value float64, rng calc.Range, field string, limited *[]LimitedValue) float64 {
+	limit := calc.Limit(value, rng)
+	if limit != value {
+		*limited = append(*limited, LimitedValue{Field: field, Value: value, Limit: limit})
+	}
+	return limit
+}
+
 func getMono(modules *ModuleMap, name string) float64 {
+	return getOutput(modules, name).Mono
+}
+
+// getOutput returns the output of a module or a port, which is silent if it doesn't exist
+func getOutput(modules *ModuleMap, ref string) Output {
+	out, _ := Lookup(modules, ref)
+	return out
+}
+
+// Lookup returns the output a reference points to, which is either the name of a module or <module>.<port>
+// a module whose name is the whole reference is preferred over a port, since module names may contain dots
+func Lookup(modules *ModuleMap, ref string) (Output, bool) {
+	if mod, _ := modules.Get(ref); mod != nil {
+		return mod.Current(), true
+	}
+
+	name, port, ok := SplitPort(ref)
+	if !ok {
+		return Output{}, false
+	}
 	mod, _ := modules.Get(name)
 	if mod == nil {
-		return 0
+		return Output{}, false
+	}
+	return mod.Port(port)
+}
+
+// Resolve returns the name of the module that a reference points to
+func Resolve(modules *ModuleMap, ref string) (string, bool) {
+	if _, ok := modules.Get(ref); ok {
+		return ref, true
+	}
+
+	name, port, ok := SplitPort(ref)
+	if !ok {
+		return "", false
+	}
+	mod, _ := modules.Get(name)
+	if !HasPort(mod, port) {
+		return "", false
+	}
+	return name, true
+}
+
+// SplitPort splits a reference to a port at its last dot into the module name and the port name
+func SplitPort(ref string) (name, port string, ok bool) {
+	i := strings.LastIndex(ref, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	return ref[:i], ref[i+1:], true
+}
+
+// HasPort reports whether a module has a port of the given name
+func HasPort(mod IModule, port string) bool {
+	return mod != nil && slices.Contains(mod.Ports(), port)
+}
+
+// channels returns the left and the right channel at full level
+// each channel of an output holds half of the signal, so that the channels of a mono signal add up to the mono signal
+func (o Output) channels() (float64, float64) {
+	return 2 * o.Left, 2 * o.Right
+}
+
+// monoOutput returns the output of a mono signal, which is split evenly between both channels
+func monoOutput(val float64) Output {
+	return Output{
+		Mono:  val,
+		Left:  val / 2,
+		Right: val / 2,
+	}
+}
+
+// stereoOutput returns the output of a stereo signal given by its channels at full level
+func stereoOutput(left, right float64) Output {
+	return Output{
+		Mono:  (left + right) / 2,
+		Left:  left / 2,
+		Right: right / 2,
 	}
-	return mod.Current().Mono
 }
@@ -73,3 +73,61 @@ func Test_modulate(t *testing.T) {
 		})
 	}
 }
+
+func TestLookup(t *testing.T) {
+	filter := &Filter{low: monoOutput(0.25)}
+	modules := NewModuleMap(map[string]IModule{
+		"osc":     &Module{current: monoOutput(0.5)},
+		"lp":      filter,
+		"lead.lp": &Module{current: monoOutput(0.75)},
+	})
+
+	tests := []struct {
+		name   string
+		ref    string
+		want   Output
+		wantOk bool
+	}{
+		{
+			name:   "module",
+			ref:    "osc",
+			want:   monoOutput(0.5),
+			wantOk: true,
+		},
+		{
+			name:   "port",
+			ref:    "lp.lp",
+			want:   monoOutput(0.25),
+			wantOk: true,
+		},
+		{
+			name:   "module names with dots are preferred over ports",
+			ref:    "lead.lp",
+			want:   monoOutput(0.75),
+			wantOk: true,
+		},
+		{
+			name: "unknown port",
+			ref:  "lp.notch",
+		},
+		{
+			name: "module without ports",
+			ref:  "osc.sine",
+		},
+		{
+			name: "unknown module",
+			ref:  "noise",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Lookup(modules, tt.ref)
+			if ok != tt.wantOk {
+				t.Errorf("Lookup() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,9 +13,5 @@ type (
 func (n *Noise) Step() {
 	val := rand.Float64()*2 - 1
 
-	n.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
-	}
+	n.current = monoOutput(val)
 }
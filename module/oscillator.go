@@ -12,17 +12,28 @@ type (
 		Module
 		Type  oscillatorType `yaml:"type"`
 		Freq  float64        `yaml:"freq"`
+		Sync  Division       `yaml:"sync"`
 		CV    string         `yaml:"cv"`
 		Mod   string         `yaml:"mod"`
 		Phase float64        `yaml:"phase"`
+		Width float64        `yaml:"width"`
+		PWM   string         `yaml:"pwm"`
 		Fade  float64        `yaml:"fade"`
 
 		signal     SignalFunc
+		blSignal   BandLimitedSignalFunc
+		pulse      PulseWidthSignalFunc
 		sampleRate float64
 		arg        float64
+		clock      *Clock
+		// x, dt and width are the argument, phase increment and pulse width of the current sample, from which the ports are calculated
+		x     float64
+		dt    float64
+		width float64
 
 		freqFader  *fader
 		phaseFader *fader
+		widthFader *fader
 	}
 
 	OscillatorMap  map[string]*Oscillator
@@ -35,6 +46,21 @@ const (
 	oscillatorTypeSine            oscillatorType = "Sine"
 	oscillatorTypeSquare          oscillatorType = "Square"
 	oscillatorTypeTriangle        oscillatorType = "Triangle"
+
+	// band-limited variants that don't alias at high frequencies
+	oscillatorTypeSawtoothBL        oscillatorType = "SawtoothBL"
+	oscillatorTypeReverseSawtoothBL oscillatorType = "ReverseSawtoothBL"
+	oscillatorTypeSquareBL          oscillatorType = "SquareBL"
+
+	defaultWidth = 0.5
+)
+
+// the waveforms of the ports, sawtooth and square are band-limited
+var (
+	sineSignal     = SineSignalFunc()
+	triangleSignal = TriangleSignalFunc()
+	sawtoothSignal = SawtoothBLSignalFunc()
+	pulseSignal    = PulseBLSignalFunc()
 )
 
 func (m OscillatorMap) Initialize(sampleRate float64) error {
@@ -52,7 +78,12 @@ func (m OscillatorMap) Initialize(sampleRate float64) error {
 func (o *Oscillator) initialize(sampleRate float64) error {
 	o.sampleRate = sampleRate
 	o.Freq = calc.Limit(o.Freq, freqRange)
+	o.Sync = Division(calc.Limit(float64(o.Sync), divisionRange))
 	o.Fade = calc.Limit(o.Fade, fadeRange)
+	if o.Width == 0 {
+		o.Width = defaultWidth
+	}
+	o.Width = calc.Limit(o.Width, widthRange)
 
 	o.freqFader = &fader{
 		current: o.Freq,
@@ -62,11 +93,24 @@ func (o *Oscillator) initialize(sampleRate float64) error {
 		current: o.Phase,
 		target:  o.Phase,
 	}
+	o.widthFader = &fader{
+		current: o.Width,
+		target:  o.Width,
+	}
 	o.initializeFaders()
 
+	if pulse, ok := newPulseWidthSignalFunc(o.Type); ok {
+		o.pulse = pulse
+		return nil
+	}
+	if blSignal, ok := newBandLimitedSignalFunc(o.Type); ok {
+		o.blSignal = blSignal
+		return nil
+	}
+
 	signal, err := newSignalFunc(o.Type)
 	if err != nil {
-		return err
+		return &FieldError{Field: "type", Err: err}
 	}
 	o.signal = signal
 
@@ -79,10 +123,14 @@ func (o *Oscillator) Update(new *Oscillator) {
 	}
 
 	o.Type = new.Type
+	o.Sync = new.Sync
 	o.CV = new.CV
 	o.Mod = new.Mod
+	o.PWM = new.PWM
 	o.Fade = new.Fade
 	o.signal = new.signal
+	o.blSignal = new.blSignal
+	o.pulse = new.pulse
 
 	if o.freqFader != nil {
 		o.freqFader.target = new.Freq
@@ -90,9 +138,31 @@ func (o *Oscillator) Update(new *Oscillator) {
 	if o.phaseFader != nil {
 		o.phaseFader.target = new.Phase
 	}
+	if o.widthFader != nil {
+		o.widthFader.target = new.Width
+	}
 	o.initializeFaders()
 }
 
+// SetClock syncs the oscillator to the clock, if sync is set
+func (o *Oscillator) SetClock(c *Clock) {
+	o.clock = c
+}
+
+func (o *Oscillator) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"cv":  o.CV,
+		"mod": o.Mod,
+		"pwm": o.PWM,
+	})
+}
+
+func (o *Oscillator) RenameInputs(rename func(string) string) {
+	o.CV = rename(o.CV)
+	o.Mod = rename(o.Mod)
+	o.PWM = rename(o.PWM)
+}
+
 func (o *Oscillator) Step(modules *ModuleMap) {
 	twoPi := 2 * math.Pi
 	freq := o.Freq
@@ -102,18 +172,57 @@ func (o *Oscillator) Step(modules *ModuleMap) {
 
 	c := twoPi * o.Phase
 	mod := math.Pow(2, getMono(modules, o.Mod))
+	dt := freq * mod / o.sampleRate
+
+	// a synced oscillator completes one cycle per division, its phase is taken from the clock
+	if o.clock != nil && o.Sync > 0 {
+		o.arg = twoPi * o.clock.position(o.Sync)
+		dt = 0
+		if seconds := o.clock.seconds(o.Sync); seconds > 0 {
+			dt = 1 / (seconds * o.sampleRate)
+		}
+	}
 
-	val := o.signal(o.arg + c)
-	o.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
+	o.x = o.arg + c
+	o.dt = dt
+	o.width = modulate(o.Width, widthRange, getMono(modules, o.PWM))
+
+	var val float64
+	switch {
+	case o.pulse != nil:
+		val = o.pulse(o.x, dt, o.width)
+	case o.blSignal != nil:
+		val = o.blSignal(o.x, dt)
+	default:
+		val = o.signal(o.x)
 	}
+	o.current = monoOutput(val)
 
-	o.arg += twoPi * freq * mod / o.sampleRate
+	o.arg += twoPi * dt
 	o.fade()
 }
 
+// Ports returns the waveforms the oscillator provides in phase with its main output, regardless of its type
+// they are only calculated when they are read
+func (o *Oscillator) Ports() []string {
+	return []string{"sine", "triangle", "sawtooth", "square"}
+}
+
+func (o *Oscillator) Port(name string) (Output, bool) {
+	switch name {
+	case "sine":
+		return monoOutput(sineSignal(o.x)), true
+	case "triangle":
+		return monoOutput(triangleSignal(o.x)), true
+	case "sawtooth":
+		return monoOutput(sawtoothSignal(o.x, o.dt)), true
+	case "square":
+		return monoOutput(pulseSignal(o.x, o.dt, o.width)), true
+	default:
+		return Output{}, false
+	}
+}
+
 func (o *Oscillator) fade() {
 	if o.freqFader != nil {
 		o.Freq = o.freqFader.fade()
@@ -121,6 +230,9 @@ func (o *Oscillator) fade() {
 	if o.phaseFader != nil {
 		o.Phase = o.phaseFader.fade()
 	}
+	if o.widthFader != nil {
+		o.Width = o.widthFader.fade()
+	}
 }
 
 func (o *Oscillator) initializeFaders() {
@@ -130,4 +242,7 @@ func (o *Oscillator) initializeFaders() {
 	if o.phaseFader != nil {
 		o.phaseFader.initialize(o.Fade, o.sampleRate)
 	}
+	if o.widthFader != nil {
+		o.widthFader.initialize(o.Fade, o.sampleRate)
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestOscillator_Step(t *testing.T) {
@@ -95,6 +96,53 @@ func TestOscillator_Step(t *testing.T) {
 			want:    0,
 			wantArg: twoPi * freqRange.Max / sampleRate,
 		},
+		{
+			name:    "pulse width",
+			modules: &ModuleMap{},
+			o: &Oscillator{
+				Freq:       200,
+				Width:      0.25,
+				pulse:      PulseSignalFunc(),
+				sampleRate: sampleRate,
+				Phase:      0.3,
+			},
+			want:    -1,
+			wantArg: twoPi * 200 / sampleRate,
+		},
+		{
+			name: "pulse width modulation",
+			modules: NewModuleMap(map[string]IModule{
+				"pwm": &Module{
+					current: Output{
+						Mono: 0.5,
+					},
+				},
+			}),
+			o: &Oscillator{
+				Freq:       200,
+				Width:      0.25,
+				PWM:        "pwm",
+				pulse:      PulseSignalFunc(),
+				sampleRate: sampleRate,
+				Phase:      0.3,
+			},
+			want:    1,
+			wantArg: twoPi * 200 / sampleRate,
+		},
+		{
+			name:    "synced",
+			modules: &ModuleMap{},
+			o: &Oscillator{
+				Freq:       200,
+				Sync:       0.25,
+				signal:     SineSignalFunc(),
+				sampleRate: sampleRate,
+				arg:        1,
+				clock:      &Clock{BPM: 120, bars: 0.0625},
+			},
+			want:    1,
+			wantArg: twoPi*0.25 + twoPi*2/sampleRate,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -110,6 +158,37 @@ func TestOscillator_Step(t *testing.T) {
 	}
 }
 
+func TestOscillator_ports(t *testing.T) {
+	o := &Oscillator{Type: oscillatorTypeSawtooth, Freq: 1}
+	if err := o.initialize(8); err != nil {
+		t.Fatalf("Oscillator.initialize() error = %v", err)
+	}
+	modules := NewModuleMap(map[string]IModule{"osc": o})
+
+	// the ports are in phase with the main output, the third sample is a quarter cycle past the start
+	for range 3 {
+		o.Step(modules)
+	}
+
+	want := map[string]float64{
+		"osc":          o.Current().Mono,
+		"osc.sine":     1,
+		"osc.triangle": 1,
+		"osc.square":   1,
+		"osc.noise":    0,
+	}
+	got := map[string]float64{}
+	for ref := range want {
+		got[ref] = getMono(modules, ref)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Oscillator.Port() diff = %s", diff)
+	}
+	if got := getMono(modules, "osc.sawtooth"); got != o.Current().Mono {
+		t.Errorf("Oscillator.Port() sawtooth = %v, want %v", got, o.Current().Mono)
+	}
+}
+
 func TestOscillator_Update(t *testing.T) {
 	sampleRate := 44100.0
 
@@ -199,6 +278,10 @@ func TestOscillator_Update(t *testing.T) {
 					target:  0.5,
 					step:    0.1,
 				},
+				widthFader: &fader{
+					current: 0.5,
+					target:  0.5,
+				},
 			},
 			new: &Oscillator{
 				Type:  "Square",
@@ -206,6 +289,8 @@ func TestOscillator_Update(t *testing.T) {
 				CV:    "new-cv",
 				Mod:   "new-mod",
 				Phase: 0,
+				Width: 0.25,
+				PWM:   "new-pwm",
 				Fade:  2,
 			},
 			want: &Oscillator{
@@ -218,6 +303,7 @@ func TestOscillator_Update(t *testing.T) {
 				Freq:       440,
 				CV:         "new-cv",
 				Mod:        "new-mod",
+				PWM:        "new-pwm",
 				Phase:      0.5,
 				Fade:       2,
 				sampleRate: sampleRate,
@@ -232,6 +318,11 @@ func TestOscillator_Update(t *testing.T) {
 					target:  0,
 					step:    -0.25 / sampleRate,
 				},
+				widthFader: &fader{
+					current: 0.5,
+					target:  0.25,
+					step:    -0.125 / sampleRate,
+				},
 			},
 		},
 	}
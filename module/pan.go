@@ -54,16 +54,25 @@ func (p *Pan) Update(new *Pan) {
 	}
 }
 
+func (p *Pan) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":  p.In,
+		"mod": p.Mod,
+	})
+}
+
+func (p *Pan) RenameInputs(rename func(string) string) {
+	p.In = rename(p.In)
+	p.Mod = rename(p.Mod)
+}
+
 func (p *Pan) Step(modules *ModuleMap) {
 	pan := modulate(p.Pan, panRange, getMono(modules, p.Mod))
 	percent := calc.Percentage(pan, panRange)
-	in := getMono(modules, p.In)
+	left, right := getOutput(modules, p.In).channels()
 
-	p.current = Output{
-		Mono:  in,
-		Right: in * percent,
-		Left:  in * (1 - percent),
-	}
+	// each channel is doubled when panned all the way to its side, so a mono input is moved entirely to that side
+	p.current = stereoOutput(2*left*(1-percent), 2*right*percent)
 
 	p.fade()
 }
@@ -21,9 +21,7 @@ func TestPan_Step(t *testing.T) {
 			},
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 			}),
 			want: Output{
@@ -41,14 +39,10 @@ func TestPan_Step(t *testing.T) {
 			},
 			modules: NewModuleMap(map[string]IModule{
 				"in": &Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				"mod": &Module{
-					current: Output{
-						Mono: 0.25,
-					},
+					current: monoOutput(0.25),
 				},
 			}),
 			want: Output{
@@ -57,6 +51,40 @@ func TestPan_Step(t *testing.T) {
 				Right: 0.75,
 			},
 		},
+		{
+			name: "stereo input stays in its channel",
+			p: &Pan{
+				Pan: 0,
+				In:  "in",
+			},
+			modules: NewModuleMap(map[string]IModule{
+				"in": &Module{
+					current: stereoOutput(1, 0),
+				},
+			}),
+			want: Output{
+				Mono:  0.5,
+				Left:  0.5,
+				Right: 0,
+			},
+		},
+		{
+			name: "stereo input is balanced",
+			p: &Pan{
+				Pan: 0.5,
+				In:  "in",
+			},
+			modules: NewModuleMap(map[string]IModule{
+				"in": &Module{
+					current: stereoOutput(0.5, 0.5),
+				},
+			}),
+			want: Output{
+				Mono:  0.5,
+				Left:  0.125,
+				Right: 0.375,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -82,9 +110,7 @@ func TestPan_Update(t *testing.T) {
 			name: "no update necessary",
 			p: &Pan{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Pan:        0.5,
 				Mod:        "mod",
@@ -100,9 +126,7 @@ func TestPan_Update(t *testing.T) {
 			new: nil,
 			want: &Pan{
 				Module: Module{
-					current: Output{
-						Mono: 1,
-					},
+					current: monoOutput(1),
 				},
 				Pan:        0.5,
 				Mod:        "mod",
@@ -0,0 +1,273 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/iljarotar/synth/calc"
+	"github.com/iljarotar/synth/wav"
+)
+
+type (
+	// Player plays a wav file, which is restarted whenever the gate opens
+	Player struct {
+		Module
+		File  string  `yaml:"file"`
+		Gate  string  `yaml:"gate"`
+		Speed float64 `yaml:"speed"`
+		Root  float64 `yaml:"root"`
+		CV    string  `yaml:"cv"`
+		Mod   string  `yaml:"mod"`
+		Start float64 `yaml:"start"`
+		End   float64 `yaml:"end"`
+		Loop  bool    `yaml:"loop"`
+		Fade  float64 `yaml:"fade"`
+
+		sampleRate float64
+		sound      *wav.Sound
+		// pos is the current position in frames of the sound
+		pos       float64
+		playing   bool
+		gateValue float64
+
+		speedFader *fader
+	}
+
+	PlayerMap map[string]*Player
+
+	soundCache struct {
+		mu     sync.Mutex
+		sounds map[string]cachedSound
+	}
+
+	cachedSound struct {
+		modTime time.Time
+		size    int64
+		sound   *wav.Sound
+	}
+)
+
+const (
+	defaultSpeed = 1
+	// frequency of a_4, which is assumed to be the pitch of the sound if no root is given
+	defaultRoot = 440
+)
+
+// sounds holds all decoded files, so that they are only decoded again if they have changed
+var sounds = &soundCache{
+	sounds: map[string]cachedSound{},
+}
+
+func (m PlayerMap) Initialize(sampleRate float64) error {
+	for name, p := range m {
+		if p == nil {
+			continue
+		}
+		if err := p.initialize(sampleRate); err != nil {
+			return fmt.Errorf("failed to initialize player %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PruneSounds removes the sounds of all files from the cache that none of the players plays
+func PruneSounds(players PlayerMap) {
+	files := map[string]bool{}
+	for _, p := range players {
+		if p != nil {
+			files[p.File] = true
+		}
+	}
+	sounds.prune(files)
+}
+
+func (p *Player) initialize(sampleRate float64) error {
+	p.sampleRate = sampleRate
+	if p.Speed == 0 {
+		p.Speed = defaultSpeed
+	}
+	p.Speed = calc.Limit(p.Speed, speedRange)
+	if p.Root == 0 {
+		p.Root = defaultRoot
+	}
+	p.Root = calc.Limit(p.Root, freqRange)
+	if p.End == 0 {
+		p.End = 1
+	}
+	p.Start = calc.Limit(p.Start, positionRange)
+	p.End = calc.Limit(p.End, positionRange)
+	p.Fade = calc.Limit(p.Fade, fadeRange)
+
+	p.speedFader = &fader{
+		current: p.Speed,
+		target:  p.Speed,
+	}
+	p.speedFader.initialize(p.Fade, sampleRate)
+
+	if p.File == "" {
+		return &FieldError{Field: "file", Err: errors.New("no file specified")}
+	}
+	sound, err := sounds.load(p.File)
+	if err != nil {
+		return &FieldError{Field: "file", Err: err}
+	}
+	p.sound = sound
+
+	// without a gate the sound starts right away
+	p.pos, _ = p.bounds()
+	p.playing = p.Gate == ""
+
+	return nil
+}
+
+func (p *Player) Update(new *Player) {
+	if new == nil {
+		return
+	}
+
+	p.Gate = new.Gate
+	p.CV = new.CV
+	p.Mod = new.Mod
+	p.Root = new.Root
+	p.Start = new.Start
+	p.End = new.End
+	p.Loop = new.Loop
+	p.Fade = new.Fade
+
+	if new.sound != p.sound {
+		p.File = new.File
+		p.sound = new.sound
+		p.pos = new.pos
+		p.playing = new.playing
+	}
+
+	if p.speedFader != nil {
+		p.speedFader.target = new.Speed
+		p.speedFader.initialize(p.Fade, p.sampleRate)
+	}
+}
+
+func (p *Player) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"gate": p.Gate,
+		"cv":   p.CV,
+		"mod":  p.Mod,
+	})
+}
+
+func (p *Player) RenameInputs(rename func(string) string) {
+	p.Gate = rename(p.Gate)
+	p.CV = rename(p.CV)
+	p.Mod = rename(p.Mod)
+}
+
+func (p *Player) Step(modules *ModuleMap) {
+	start, end := p.bounds()
+
+	gateValue := getMono(modules, p.Gate)
+	if p.Gate != "" && p.gateValue <= 0 && gateValue > 0 {
+		p.pos = start
+		p.playing = true
+	}
+	p.gateValue = gateValue
+
+	if p.playing && p.pos >= end {
+		if p.Loop && end > start {
+			p.pos = start + math.Mod(p.pos-start, end-start)
+		} else {
+			p.playing = false
+		}
+	}
+
+	if !p.playing {
+		p.current = Output{}
+		p.fade()
+		return
+	}
+
+	speed := p.Speed
+	if p.CV != "" {
+		speed = cv(freqRange, getMono(modules, p.CV)) / p.Root
+	}
+	speed = calc.Limit(speed*math.Pow(2, getMono(modules, p.Mod)), speedRange)
+
+	left, right := p.frame(p.pos)
+	p.current = stereoOutput(left, right)
+
+	p.pos += speed * float64(p.sound.SampleRate) / p.sampleRate
+	p.fade()
+}
+
+// bounds returns the first and the last frame to play
+func (p *Player) bounds() (float64, float64) {
+	if p.sound == nil {
+		return 0, 0
+	}
+	frames := float64(len(p.sound.Frames))
+	return p.Start * frames, p.End * frames
+}
+
+// frame interpolates linearly between the frames around pos
+func (p *Player) frame(pos float64) (float64, float64) {
+	frames := p.sound.Frames
+	if len(frames) == 0 {
+		return 0, 0
+	}
+
+	i := min(int(pos), len(frames)-1)
+	next := min(i+1, len(frames)-1)
+	frac := pos - float64(i)
+
+	left := frames[i][0] + frac*(frames[next][0]-frames[i][0])
+	right := frames[i][1] + frac*(frames[next][1]-frames[i][1])
+	return left, right
+}
+
+func (p *Player) fade() {
+	if p.speedFader != nil {
+		p.Speed = p.speedFader.fade()
+	}
+}
+
+// load returns the cached sound unless the file has been modified since it was decoded
+func (c *soundCache) load(path string) (*wav.Sound, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.sounds[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.sound, nil
+	}
+
+	sound, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c.sounds[path] = cachedSound{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		sound:   sound,
+	}
+
+	return sound, nil
+}
+
+// prune removes all sounds whose path isn't in keep
+func (c *soundCache) prune(keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path := range c.sounds {
+		if !keep[path] {
+			delete(c.sounds, path)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/iljarotar/synth/pcm"
+	"github.com/iljarotar/synth/wav"
+)
+
+func TestPlayer_Step(t *testing.T) {
+	sampleRate := 4.0
+	sound := &wav.Sound{
+		SampleRate: 4,
+		Channels:   2,
+		Frames: [][2]float64{
+			{0, 0.8},
+			{0.2, 0.6},
+			{0.4, 0.4},
+			{0.6, 0.2},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		p     *Player
+		gates []float64
+		want  []float64
+	}{
+		{
+			name: "play once",
+			p:    &Player{Speed: 1, End: 1},
+			want: []float64{0, 0.2, 0.4, 0.6, 0, 0},
+		},
+		{
+			name: "double speed",
+			p:    &Player{Speed: 2, End: 1},
+			want: []float64{0, 0.4, 0, 0},
+		},
+		{
+			name: "half speed interpolates",
+			p:    &Player{Speed: 0.5, End: 1},
+			want: []float64{0, 0.1, 0.2, 0.3},
+		},
+		{
+			name: "start and end",
+			p:    &Player{Speed: 1, Start: 0.25, End: 0.75},
+			want: []float64{0.2, 0.4, 0, 0},
+		},
+		{
+			name: "loop",
+			p:    &Player{Speed: 1, Start: 0.25, End: 0.75, Loop: true},
+			want: []float64{0.2, 0.4, 0.2, 0.4, 0.2},
+		},
+		{
+			name:  "gate restarts",
+			p:     &Player{Speed: 1, End: 1, Gate: "gate"},
+			gates: []float64{0, 1, 1, 0, 1, 1},
+			want:  []float64{0, 0, 0.2, 0.4, 0, 0.2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.p.sampleRate = sampleRate
+			tt.p.sound = sound
+			tt.p.pos, _ = tt.p.bounds()
+			tt.p.playing = tt.p.Gate == ""
+
+			gate := &Module{}
+			modules := NewModuleMap(map[string]IModule{"gate": gate})
+
+			var got []float64
+			for i := range tt.want {
+				if i < len(tt.gates) {
+					gate.current = Output{Mono: tt.gates[i]}
+				}
+				tt.p.Step(modules)
+				got = append(got, tt.p.Current().Left*2)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Player.Step() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestPlayer_stereo(t *testing.T) {
+	p := &Player{
+		Speed:      1,
+		End:        1,
+		sampleRate: 44100,
+		sound: &wav.Sound{
+			SampleRate: 44100,
+			Channels:   2,
+			Frames:     [][2]float64{{0.2, 0.6}},
+		},
+		playing: true,
+	}
+	p.Step(NewModuleMap(nil))
+
+	want := Output{Mono: 0.4, Left: 0.1, Right: 0.3}
+	if diff := cmp.Diff(want, p.Current(), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Player.Step() diff = %s", diff)
+	}
+}
+
+func TestPlayer_Update(t *testing.T) {
+	sampleRate := 44100.0
+	sound := &wav.Sound{SampleRate: 44100}
+	newSound := &wav.Sound{SampleRate: 48000}
+
+	tests := []struct {
+		name string
+		p    *Player
+		new  *Player
+		want *Player
+	}{
+		{
+			name: "no update necessary",
+			p: &Player{
+				File:       "a.wav",
+				Speed:      1,
+				sampleRate: sampleRate,
+				sound:      sound,
+				pos:        10,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 1},
+			},
+			new: nil,
+			want: &Player{
+				File:       "a.wav",
+				Speed:      1,
+				sampleRate: sampleRate,
+				sound:      sound,
+				pos:        10,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 1},
+			},
+		},
+		{
+			name: "unchanged file keeps playing",
+			p: &Player{
+				File:       "a.wav",
+				Speed:      1,
+				sampleRate: sampleRate,
+				sound:      sound,
+				pos:        10,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 1},
+			},
+			new: &Player{
+				File:  "a.wav",
+				Gate:  "gate",
+				Speed: 2,
+				Loop:  true,
+				Fade:  1,
+				sound: sound,
+			},
+			want: &Player{
+				File:       "a.wav",
+				Gate:       "gate",
+				Speed:      1,
+				Loop:       true,
+				Fade:       1,
+				sampleRate: sampleRate,
+				sound:      sound,
+				pos:        10,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 2, step: 1 / sampleRate},
+			},
+		},
+		{
+			name: "changed file starts over",
+			p: &Player{
+				File:       "a.wav",
+				Speed:      1,
+				sampleRate: sampleRate,
+				sound:      sound,
+				pos:        10,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 1},
+			},
+			new: &Player{
+				File:    "b.wav",
+				Speed:   1,
+				sound:   newSound,
+				playing: true,
+			},
+			want: &Player{
+				File:       "b.wav",
+				Speed:      1,
+				sampleRate: sampleRate,
+				sound:      newSound,
+				playing:    true,
+				speedFader: &fader{current: 1, target: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.p.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.p, cmp.AllowUnexported(Module{}, Player{}, fader{})); diff != "" {
+				t.Errorf("Player.Update() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func Test_soundCache_load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sound.wav")
+	writeSound(t, path, []float64{0.5, -0.5})
+
+	c := &soundCache{sounds: map[string]cachedSound{}}
+	first, err := c.load(path)
+	if err != nil {
+		t.Fatalf("soundCache.load() error = %v", err)
+	}
+	second, err := c.load(path)
+	if err != nil {
+		t.Fatalf("soundCache.load() error = %v", err)
+	}
+	if first != second {
+		t.Errorf("soundCache.load() decoded an unchanged file again")
+	}
+
+	writeSound(t, path, []float64{0.5, -0.5, 0.5})
+	third, err := c.load(path)
+	if err != nil {
+		t.Fatalf("soundCache.load() error = %v", err)
+	}
+	if third == first {
+		t.Errorf("soundCache.load() returned a stale sound")
+	}
+	if len(third.Frames) != 3 {
+		t.Errorf("soundCache.load() got %d frames, want 3", len(third.Frames))
+	}
+}
+
+func Test_soundCache_prune(t *testing.T) {
+	dir := t.TempDir()
+	c := &soundCache{sounds: map[string]cachedSound{}}
+	for _, name := range []string{"a.wav", "b.wav"} {
+		path := filepath.Join(dir, name)
+		writeSound(t, path, []float64{0.5})
+		if _, err := c.load(path); err != nil {
+			t.Fatalf("soundCache.load() error = %v", err)
+		}
+	}
+
+	c.prune(map[string]bool{filepath.Join(dir, "a.wav"): true})
+
+	var got []string
+	for path := range c.sounds {
+		got = append(got, filepath.Base(path))
+	}
+	if diff := cmp.Diff([]string{"a.wav"}, got); diff != "" {
+		t.Errorf("soundCache.prune() diff = %s", diff)
+	}
+}
+
+func writeSound(t *testing.T, path string, samples []float64) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := wav.NewWriter(f, 44100, pcm.FormatS16LE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range samples {
+		if err := w.WriteFrame([2]float64{s, s}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
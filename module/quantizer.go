@@ -0,0 +1,204 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Quantizer snaps a pitch to the closest note of a scale or of a tuning
+	// its input and output encode frequencies like the output of a sequencer
+	Quantizer struct {
+		Module
+		In        string    `yaml:"in"`
+		Scale     string    `yaml:"scale"`
+		Intervals []float64 `yaml:"intervals"`
+		Root      string    `yaml:"root"`
+		Pitch     float64   `yaml:"pitch"`
+		Trigger   string    `yaml:"trigger"`
+		Tuning    *Tuning   `yaml:"tuning"`
+
+		// degrees holds the notes of the scale in semitones above the root, sorted and within one period
+		degrees []float64
+		// period is the interval in semitones after which the scale repeats
+		period float64
+		// root is the frequency of the root in the fourth octave
+		root         float64
+		triggerValue float64
+	}
+
+	QuantizerMap map[string]*Quantizer
+)
+
+// scales holds the notes of each scale in semitones above the root
+var scales = map[string][]float64{
+	"chromatic":        {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	"major":            {0, 2, 4, 5, 7, 9, 11},
+	"minor":            {0, 2, 3, 5, 7, 8, 10},
+	"ionian":           {0, 2, 4, 5, 7, 9, 11},
+	"dorian":           {0, 2, 3, 5, 7, 9, 10},
+	"phrygian":         {0, 1, 3, 5, 7, 8, 10},
+	"lydian":           {0, 2, 4, 6, 7, 9, 11},
+	"mixolydian":       {0, 2, 4, 5, 7, 9, 10},
+	"aeolian":          {0, 2, 3, 5, 7, 8, 10},
+	"locrian":          {0, 1, 3, 5, 6, 8, 10},
+	"harmonic-minor":   {0, 2, 3, 5, 7, 8, 11},
+	"melodic-minor":    {0, 2, 3, 5, 7, 9, 11},
+	"major-pentatonic": {0, 2, 4, 7, 9},
+	"minor-pentatonic": {0, 3, 5, 7, 10},
+	"blues":            {0, 3, 5, 6, 7, 10},
+	"whole-tone":       {0, 2, 4, 6, 8, 10},
+}
+
+func (m QuantizerMap) Initialize() error {
+	for name, q := range m {
+		if q == nil {
+			continue
+		}
+		if err := q.initialize(); err != nil {
+			return fmt.Errorf("failed to initialize quantizer %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (q *Quantizer) initialize() error {
+	if q.Pitch == 0 {
+		q.Pitch = defaultPitch
+	}
+	q.Pitch = calc.Limit(q.Pitch, pitchRange)
+
+	if q.Tuning != nil {
+		return q.initializeTuning()
+	}
+	q.period = 12
+
+	if q.Root == "" {
+		q.Root = "c"
+	}
+	offset, ok := noteOffsets[q.Root]
+	if !ok {
+		return &FieldError{Field: "root", Err: fmt.Errorf("unknown note %s", q.Root)}
+	}
+	q.root = q.Pitch * math.Pow(2, float64(offset)/12)
+
+	intervals := q.Intervals
+	if len(intervals) == 0 {
+		if q.Scale == "" {
+			q.Scale = "chromatic"
+		}
+		intervals, ok = scales[q.Scale]
+		if !ok {
+			return &FieldError{Field: "scale", Err: fmt.Errorf("unknown scale %s", q.Scale)}
+		}
+	}
+	q.degrees = makeDegrees(intervals)
+
+	return nil
+}
+
+// initializeTuning uses the degrees of the tuning as the scale and its base as the root
+func (q *Quantizer) initializeTuning() error {
+	if q.Scale != "" || len(q.Intervals) > 0 || q.Root != "" {
+		return &FieldError{Field: "tuning", Err: errors.New("a quantizer with a tuning can't have a scale, intervals or a root")}
+	}
+	if err := q.Tuning.initialize(q.Pitch); err != nil {
+		return &FieldError{Field: "tuning", Err: err}
+	}
+
+	q.degrees = make([]float64, len(q.Tuning.degrees))
+	for i, cents := range q.Tuning.degrees {
+		q.degrees[i] = cents / 100
+	}
+	q.period = q.Tuning.period / 100
+	q.root = q.Tuning.Base
+
+	return nil
+}
+
+func (q *Quantizer) Update(new *Quantizer) {
+	if new == nil {
+		return
+	}
+
+	q.In = new.In
+	q.Scale = new.Scale
+	q.Intervals = new.Intervals
+	q.Root = new.Root
+	q.Pitch = new.Pitch
+	q.Trigger = new.Trigger
+	q.Tuning = new.Tuning
+	q.degrees = new.degrees
+	q.period = new.period
+	q.root = new.root
+}
+
+func (q *Quantizer) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":      q.In,
+		"trigger": q.Trigger,
+	})
+}
+
+func (q *Quantizer) RenameInputs(rename func(string) string) {
+	q.In = rename(q.In)
+	q.Trigger = rename(q.Trigger)
+}
+
+// Step quantizes the input continuously or, if the quantizer has a trigger, holds the quantized input between triggers
+func (q *Quantizer) Step(modules *ModuleMap) {
+	if q.Trigger != "" {
+		triggerValue := getMono(modules, q.Trigger)
+		triggered := triggerValue > 0 && q.triggerValue <= 0
+		q.triggerValue = triggerValue
+		if !triggered {
+			return
+		}
+	}
+
+	freq := cv(freqRange, getMono(modules, q.In))
+	q.current = monoOutput(calc.Transpose(q.quantize(freq), freqRange, cvRange))
+}
+
+// quantize returns the frequency of the note of the scale that is closest to freq in semitones
+func (q *Quantizer) quantize(freq float64) float64 {
+	if freq <= 0 || q.root <= 0 || q.period <= 0 || len(q.degrees) == 0 {
+		return 0
+	}
+
+	semitones := 12 * math.Log2(freq/q.root)
+	period := math.Floor(semitones / q.period)
+	x := semitones - q.period*period
+
+	// the closest note may be in the period below or above
+	closest := math.Inf(1)
+	for _, shift := range []float64{-q.period, 0, q.period} {
+		for _, degree := range q.degrees {
+			if note := degree + shift; math.Abs(note-x) < math.Abs(closest-x) {
+				closest = note
+			}
+		}
+	}
+
+	return calc.Limit(q.root*math.Pow(2, (q.period*period+closest)/12), freqRange)
+}
+
+// makeDegrees moves intervals into a single octave above the root and sorts them
+func makeDegrees(intervals []float64) []float64 {
+	var degrees []float64
+	for _, interval := range intervals {
+		degree := math.Mod(interval, 12)
+		if degree < 0 {
+			degree += 12
+		}
+		if !slices.Contains(degrees, degree) {
+			degrees = append(degrees, degree)
+		}
+	}
+	slices.Sort(degrees)
+	return degrees
+}
@@ -0,0 +1,338 @@
+package module
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/iljarotar/synth/calc"
+)
+
+func TestQuantizer_quantize(t *testing.T) {
+	// semitone returns the frequency of a note given by its distance to a_4 in semitones
+	semitone := func(n float64) float64 {
+		return 440 * math.Pow(2, n/12)
+	}
+
+	tests := []struct {
+		name string
+		q    *Quantizer
+		freq float64
+		want float64
+	}{
+		{
+			name: "chromatic",
+			q:    &Quantizer{},
+			freq: semitone(0.4),
+			want: 440,
+		},
+		{
+			name: "note of the scale",
+			q:    &Quantizer{Scale: "major"},
+			freq: semitone(2),
+			want: semitone(2),
+		},
+		{
+			name: "closest note of the scale",
+			q:    &Quantizer{Scale: "major"},
+			// a# is between a and b in c major
+			freq: semitone(1.2),
+			want: semitone(2),
+		},
+		{
+			name: "root",
+			q:    &Quantizer{Scale: "minor-pentatonic", Root: "a"},
+			// g# is closer to a in the octave above than to g
+			freq: semitone(-0.6),
+			want: 440,
+		},
+		{
+			name: "closest note is in the octave below",
+			q:    &Quantizer{Intervals: []float64{11}},
+			// c is closer to b in the octave below than to b in its own octave
+			freq: semitone(-8.6),
+			want: semitone(-10),
+		},
+		{
+			name: "closest note is in the octave above",
+			q:    &Quantizer{Intervals: []float64{1}},
+			// b is closer to c# in the octave above than to c# in its own octave
+			freq: semitone(-9.4),
+			want: semitone(-8),
+		},
+		{
+			name: "custom intervals beyond an octave",
+			q:    &Quantizer{Intervals: []float64{0, 19}},
+			freq: semitone(-3),
+			want: semitone(-2),
+		},
+		{
+			name: "pitch",
+			q:    &Quantizer{Root: "a", Pitch: 432},
+			freq: 440,
+			want: 432,
+		},
+		{
+			name: "tuning",
+			q:    &Quantizer{Tuning: &Tuning{Steps: []string{"5/4", "3/2", "2"}, Base: 440}},
+			// 6/5 is closer to 5/4 than to the root
+			freq: 440 * 6 / 5,
+			want: 440 * 5 / 4,
+		},
+		{
+			name: "period of a tuning",
+			q:    &Quantizer{Tuning: &Tuning{Steps: []string{"3/2", "3"}, Base: 440}},
+			// the scale repeats at 3, so 4 is closer to 9/2 than to 3
+			freq: 440 * 4,
+			want: 440 * 9 / 2,
+		},
+		{
+			name: "silence",
+			q:    &Quantizer{Scale: "major"},
+			freq: 0,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.q.initialize(); err != nil {
+				t.Fatalf("Quantizer.initialize() error = %v", err)
+			}
+			if got := tt.q.quantize(tt.freq); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Quantizer.quantize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantizer_initialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       *Quantizer
+		want    *Quantizer
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			q:    &Quantizer{},
+			want: &Quantizer{
+				Scale:   "chromatic",
+				Root:    "c",
+				Pitch:   440,
+				degrees: []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+				period:  12,
+				root:    440 * math.Pow(2, -9.0/12),
+			},
+		},
+		{
+			name: "intervals override the scale",
+			q:    &Quantizer{Scale: "major", Intervals: []float64{7, -12, 3, 15}, Root: "a", Pitch: 440},
+			want: &Quantizer{
+				Scale:     "major",
+				Intervals: []float64{7, -12, 3, 15},
+				Root:      "a",
+				Pitch:     440,
+				degrees:   []float64{0, 3, 7},
+				period:    12,
+				root:      440,
+			},
+		},
+		{
+			name:    "unknown scale",
+			q:       &Quantizer{Scale: "majr"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown root",
+			q:       &Quantizer{Root: "h"},
+			wantErr: true,
+		},
+		{
+			name: "tuning",
+			q:    &Quantizer{Tuning: &Tuning{EDO: 5, Base: 220}},
+			want: &Quantizer{
+				Pitch:   440,
+				Tuning:  &Tuning{EDO: 5, Base: 220},
+				degrees: []float64{0, 2.4, 4.8, 7.2, 9.6},
+				period:  12,
+				root:    220,
+			},
+		},
+		{
+			name:    "tuning and scale",
+			q:       &Quantizer{Scale: "major", Tuning: &Tuning{EDO: 19}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.q.initialize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Quantizer.initialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, tt.q, cmp.AllowUnexported(Module{}, Quantizer{}), cmpopts.IgnoreUnexported(Tuning{}), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Quantizer.initialize() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestQuantizer_Step(t *testing.T) {
+	encode := func(freq float64) float64 {
+		return calc.Transpose(freq, freqRange, cvRange)
+	}
+
+	tests := []struct {
+		name    string
+		q       *Quantizer
+		in      float64
+		trigger float64
+		want    float64
+	}{
+		{
+			name: "continuous",
+			q:    &Quantizer{In: "in", Root: "a"},
+			in:   encode(445),
+			want: encode(440),
+		},
+		{
+			name:    "trigger",
+			q:       &Quantizer{In: "in", Root: "a", Trigger: "trigger"},
+			in:      encode(445),
+			trigger: 1,
+			want:    encode(440),
+		},
+		{
+			name: "hold between triggers",
+			q: &Quantizer{
+				Module:       Module{current: monoOutput(encode(220))},
+				In:           "in",
+				Root:         "a",
+				Trigger:      "trigger",
+				triggerValue: 1,
+			},
+			in:      encode(445),
+			trigger: 1,
+			want:    encode(220),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			triggerValue := tt.q.triggerValue
+			if err := tt.q.initialize(); err != nil {
+				t.Fatalf("Quantizer.initialize() error = %v", err)
+			}
+			tt.q.triggerValue = triggerValue
+
+			modules := NewModuleMap(map[string]IModule{
+				"in":      &Module{current: monoOutput(tt.in)},
+				"trigger": &Module{current: monoOutput(tt.trigger)},
+			})
+			tt.q.Step(modules)
+
+			if got := tt.q.Current().Mono; math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Quantizer.Step() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantizer_Step_defaultSequencer(t *testing.T) {
+	s := &Sequencer{
+		Sequence: []SequencerStep{{Note: "a_4"}, {Note: "c#_5"}, {Note: "eb_3"}, {Note: "g_2"}},
+		Trigger:  "trigger",
+	}
+	if err := s.initialize(); err != nil {
+		t.Fatalf("Sequencer.initialize() error = %v", err)
+	}
+	q := &Quantizer{In: "seq"}
+	if err := q.initialize(); err != nil {
+		t.Fatalf("Quantizer.initialize() error = %v", err)
+	}
+
+	trig := &Module{}
+	modules := NewModuleMap(map[string]IModule{"trigger": trig, "seq": s})
+	for i := range len(s.Sequence) * 2 {
+		trig.current = monoOutput(float64(i%2*2 - 1))
+		s.Step(modules)
+		q.Step(modules)
+
+		// the notes of a default sequencer are already in tune with a default quantizer
+		if got, want := q.Current().Mono, s.Current().Mono; math.Abs(got-want) > 1e-12 {
+			t.Errorf("step %d: Quantizer.Step() = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestQuantizer_Update(t *testing.T) {
+	tests := []struct {
+		name string
+		q    *Quantizer
+		new  *Quantizer
+		want *Quantizer
+	}{
+		{
+			name: "no update necessary",
+			q: &Quantizer{
+				In:      "in",
+				Scale:   "major",
+				degrees: []float64{0, 2, 4, 5, 7, 9, 11},
+			},
+			new: nil,
+			want: &Quantizer{
+				In:      "in",
+				Scale:   "major",
+				degrees: []float64{0, 2, 4, 5, 7, 9, 11},
+			},
+		},
+		{
+			name: "update all",
+			q: &Quantizer{
+				Module:       Module{current: monoOutput(0.5)},
+				In:           "in",
+				Scale:        "major",
+				Root:         "c",
+				Pitch:        440,
+				degrees:      []float64{0, 2, 4, 5, 7, 9, 11},
+				root:         261.6,
+				triggerValue: 1,
+			},
+			new: &Quantizer{
+				In:        "new-in",
+				Intervals: []float64{0, 7},
+				Root:      "a",
+				Pitch:     432,
+				Trigger:   "trigger",
+				Tuning:    &Tuning{EDO: 19},
+				degrees:   []float64{0, 7},
+				period:    12,
+				root:      432,
+			},
+			want: &Quantizer{
+				Module:       Module{current: monoOutput(0.5)},
+				In:           "new-in",
+				Intervals:    []float64{0, 7},
+				Root:         "a",
+				Pitch:        432,
+				Trigger:      "trigger",
+				Tuning:       &Tuning{EDO: 19},
+				degrees:      []float64{0, 7},
+				period:       12,
+				root:         432,
+				triggerValue: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.q.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.q, cmp.AllowUnexported(Module{}, Quantizer{}), cmpopts.IgnoreUnexported(Tuning{})); diff != "" {
+				t.Errorf("Quantizer.Update() diff = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package module
+
+import (
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type (
+	// Reverb is a stereo reverb after Jezar's Freeverb
+	// eight damped combs per channel are followed by four all-pass filters for diffusion,
+	// the right channel uses slightly longer delays than the left one to decorrelate both channels
+	Reverb struct {
+		Module
+		Size     float64 `yaml:"size"`
+		Damp     float64 `yaml:"damp"`
+		Predelay float64 `yaml:"predelay"`
+		Width    float64 `yaml:"width"`
+		Mix      float64 `yaml:"mix"`
+		CV       string  `yaml:"cv"`
+		Mod      string  `yaml:"mod"`
+		In       string  `yaml:"in"`
+		Fade     float64 `yaml:"fade"`
+
+		sampleRate  float64
+		predelay    *delayLine
+		left, right reverbChannel
+
+		sizeFader  *fader
+		dampFader  *fader
+		widthFader *fader
+		mixFader   *fader
+	}
+
+	ReverbMap map[string]*Reverb
+
+	reverbChannel struct {
+		combs     []*reverbComb
+		allPasses []*allPass
+	}
+
+	// reverbComb is a feedback comb with a low pass in its feedback path
+	reverbComb struct {
+		buf   []float64
+		idx   int
+		store float64
+	}
+
+	allPass struct {
+		buf []float64
+		idx int
+	}
+
+	// delayLine delays its input by the length of its buffer
+	delayLine struct {
+		buf        []float64
+		idx        int
+		sampleRate float64
+	}
+)
+
+const (
+	// reverbInputGain keeps the sum of the combs in range
+	reverbInputGain  = 0.015
+	reverbWetScale   = 3
+	reverbRoomScale  = 0.28
+	reverbRoomOffset = 0.7
+	reverbDampScale  = 0.4
+	allPassFeedback  = 0.5
+	// reverbStereoSpread is the additional length of the right channel's delays in samples
+	reverbStereoSpread = 23
+	// the delay lengths are tuned for this sample rate
+	reverbTuningRate = 44100
+	// reverbs are as wide as possible unless a width is given
+	defaultReverbWidth = 1
+)
+
+var (
+	reverbCombTunings    = []int{1116, 1188, 1277, 1356, 1422, 1491, 1557, 1617}
+	reverbAllPassTunings = []int{556, 441, 341, 225}
+)
+
+func (m ReverbMap) Initialize(sampleRate float64) {
+	for _, r := range m {
+		if r == nil {
+			continue
+		}
+		r.initialize(sampleRate)
+	}
+}
+
+// UnmarshalYAML sets the defaults that differ from zero before decoding, so that an explicit zero can still be told apart from an unset field
+func (r *Reverb) UnmarshalYAML(unmarshal func(any) error) error {
+	type plain Reverb
+	*r = Reverb{Width: defaultReverbWidth}
+	return unmarshal((*plain)(r))
+}
+
+func (r *Reverb) initialize(sampleRate float64) {
+	r.sampleRate = sampleRate
+	r.Size = calc.Limit(r.Size, reverbRange)
+	r.Damp = calc.Limit(r.Damp, reverbRange)
+	r.Predelay = calc.Limit(r.Predelay, predelayRange)
+	r.Width = calc.Limit(r.Width, reverbRange)
+	r.Mix = calc.Limit(r.Mix, reverbRange)
+	r.Fade = calc.Limit(r.Fade, fadeRange)
+
+	r.predelay = &delayLine{sampleRate: sampleRate}
+	r.predelay.update(r.Predelay / 1000)
+	r.left = newReverbChannel(0, sampleRate)
+	r.right = newReverbChannel(reverbStereoSpread, sampleRate)
+
+	r.sizeFader = &fader{
+		current: r.Size,
+		target:  r.Size,
+	}
+	r.dampFader = &fader{
+		current: r.Damp,
+		target:  r.Damp,
+	}
+	r.widthFader = &fader{
+		current: r.Width,
+		target:  r.Width,
+	}
+	r.mixFader = &fader{
+		current: r.Mix,
+		target:  r.Mix,
+	}
+	r.initializeFaders()
+}
+
+func (r *Reverb) Update(new *Reverb) {
+	if new == nil {
+		return
+	}
+
+	r.Predelay = new.Predelay
+	r.CV = new.CV
+	r.Mod = new.Mod
+	r.In = new.In
+	r.Fade = new.Fade
+
+	if r.predelay != nil {
+		r.predelay.update(r.Predelay / 1000)
+	}
+
+	if r.sizeFader != nil {
+		r.sizeFader.target = new.Size
+	}
+	if r.dampFader != nil {
+		r.dampFader.target = new.Damp
+	}
+	if r.widthFader != nil {
+		r.widthFader.target = new.Width
+	}
+	if r.mixFader != nil {
+		r.mixFader.target = new.Mix
+	}
+	r.initializeFaders()
+}
+
+func (r *Reverb) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":  r.In,
+		"cv":  r.CV,
+		"mod": r.Mod,
+	})
+}
+
+func (r *Reverb) RenameInputs(rename func(string) string) {
+	r.In = rename(r.In)
+	r.CV = rename(r.CV)
+	r.Mod = rename(r.Mod)
+}
+
+func (r *Reverb) Step(modules *ModuleMap) {
+	mix := r.Mix
+	if r.CV != "" {
+		mix = cv(reverbRange, getMono(modules, r.CV))
+	}
+	mix = modulate(mix, reverbRange, getMono(modules, r.Mod))
+
+	// both channels feed the same reverb, the dry signal keeps its channels
+	var (
+		x                 = getOutput(modules, r.In)
+		dryLeft, dryRight = x.channels()
+		in                = r.predelay.step(x.Mono) * reverbInputGain
+		feedback          = r.Size*reverbRoomScale + reverbRoomOffset
+		damp              = r.Damp * reverbDampScale
+		left              = r.left.step(in, feedback, damp)
+		right             = r.right.step(in, feedback, damp)
+	)
+
+	// width blends the channels, at zero both channels are the same
+	var (
+		wet1 = mix * reverbWetScale * (r.Width/2 + 0.5)
+		wet2 = mix * reverbWetScale * (1 - r.Width) / 2
+		dry  = 1 - mix
+	)
+	r.current = stereoOutput(
+		calc.Limit(left*wet1+right*wet2+dryLeft*dry, outputRange),
+		calc.Limit(right*wet1+left*wet2+dryRight*dry, outputRange),
+	)
+
+	r.fade()
+}
+
+func (r *Reverb) initializeFaders() {
+	if r.sizeFader != nil {
+		r.sizeFader.initialize(r.Fade, r.sampleRate)
+	}
+	if r.dampFader != nil {
+		r.dampFader.initialize(r.Fade, r.sampleRate)
+	}
+	if r.widthFader != nil {
+		r.widthFader.initialize(r.Fade, r.sampleRate)
+	}
+	if r.mixFader != nil {
+		r.mixFader.initialize(r.Fade, r.sampleRate)
+	}
+}
+
+func (r *Reverb) fade() {
+	if r.sizeFader != nil {
+		r.Size = r.sizeFader.fade()
+	}
+	if r.dampFader != nil {
+		r.Damp = r.dampFader.fade()
+	}
+	if r.widthFader != nil {
+		r.Width = r.widthFader.fade()
+	}
+	if r.mixFader != nil {
+		r.Mix = r.mixFader.fade()
+	}
+}
+
+// newReverbChannel scales the tunings to the sample rate, spread is added to each delay
+func newReverbChannel(spread int, sampleRate float64) reverbChannel {
+	length := func(tuning int) int {
+		return max(1, int(math.Round(float64(tuning+spread)*sampleRate/reverbTuningRate)))
+	}
+
+	var c reverbChannel
+	for _, tuning := range reverbCombTunings {
+		c.combs = append(c.combs, &reverbComb{buf: make([]float64, length(tuning))})
+	}
+	for _, tuning := range reverbAllPassTunings {
+		c.allPasses = append(c.allPasses, &allPass{buf: make([]float64, length(tuning))})
+	}
+	return c
+}
+
+// step runs the combs in parallel and the all-pass filters in series
+func (c reverbChannel) step(x, feedback, damp float64) float64 {
+	var y float64
+	for _, comb := range c.combs {
+		y += comb.step(x, feedback, damp)
+	}
+	for _, ap := range c.allPasses {
+		y = ap.step(y)
+	}
+	return y
+}
+
+func (c *reverbComb) step(x, feedback, damp float64) float64 {
+	y := c.buf[c.idx]
+	c.store = y*(1-damp) + c.store*damp
+	c.buf[c.idx] = x + c.store*feedback
+	c.idx = (c.idx + 1) % len(c.buf)
+	return y
+}
+
+func (a *allPass) step(x float64) float64 {
+	delayed := a.buf[a.idx]
+	a.buf[a.idx] = x + delayed*allPassFeedback
+	a.idx = (a.idx + 1) % len(a.buf)
+	return delayed - x
+}
+
+// update resizes the buffer to the given delay in seconds, delayed samples are kept
+func (d *delayLine) update(seconds float64) {
+	length := int(math.Round(seconds * d.sampleRate))
+	if length == len(d.buf) {
+		return
+	}
+
+	if length > len(d.buf) {
+		d.buf = append(d.buf, make([]float64, length-len(d.buf))...)
+		return
+	}
+
+	d.buf = d.buf[:length]
+	if d.idx >= length {
+		d.idx = 0
+	}
+}
+
+func (d *delayLine) step(x float64) float64 {
+	if len(d.buf) == 0 {
+		return x
+	}
+
+	y := d.buf[d.idx]
+	d.buf[d.idx] = x
+	d.idx = (d.idx + 1) % len(d.buf)
+	return y
+}
@@ -0,0 +1,253 @@
+package module
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestReverb_Update(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name string
+		r    *Reverb
+		new  *Reverb
+		want *Reverb
+	}{
+		{
+			name: "no update necessary",
+			r: &Reverb{
+				Size:       0.5,
+				Mix:        0.3,
+				In:         "in",
+				sampleRate: sampleRate,
+				sizeFader:  &fader{current: 0.5, target: 0.5},
+				mixFader:   &fader{current: 0.3, target: 0.3},
+			},
+			new: nil,
+			want: &Reverb{
+				Size:       0.5,
+				Mix:        0.3,
+				In:         "in",
+				sampleRate: sampleRate,
+				sizeFader:  &fader{current: 0.5, target: 0.5},
+				mixFader:   &fader{current: 0.3, target: 0.3},
+			},
+		},
+		{
+			name: "update all",
+			r: &Reverb{
+				Size:       0.5,
+				Damp:       0.5,
+				Predelay:   10,
+				Width:      1,
+				Mix:        0.3,
+				CV:         "cv",
+				Mod:        "mod",
+				In:         "in",
+				sampleRate: sampleRate,
+				predelay:   &delayLine{buf: make([]float64, 441), sampleRate: sampleRate},
+				sizeFader:  &fader{current: 0.5, target: 0.5},
+				dampFader:  &fader{current: 0.5, target: 0.5},
+				widthFader: &fader{current: 1, target: 1},
+				mixFader:   &fader{current: 0.3, target: 0.3},
+			},
+			new: &Reverb{
+				Size:     1,
+				Damp:     0,
+				Predelay: 20,
+				Width:    0,
+				Mix:      0.5,
+				CV:       "new-cv",
+				Mod:      "new-mod",
+				In:       "new-in",
+				Fade:     1,
+			},
+			want: &Reverb{
+				Size:       0.5,
+				Damp:       0.5,
+				Predelay:   20,
+				Width:      1,
+				Mix:        0.3,
+				CV:         "new-cv",
+				Mod:        "new-mod",
+				In:         "new-in",
+				Fade:       1,
+				sampleRate: sampleRate,
+				predelay:   &delayLine{buf: make([]float64, 882), sampleRate: sampleRate},
+				sizeFader:  &fader{current: 0.5, target: 1, step: 0.5 / sampleRate},
+				dampFader:  &fader{current: 0.5, target: 0, step: -0.5 / sampleRate},
+				widthFader: &fader{current: 1, target: 0, step: -1 / sampleRate},
+				mixFader:   &fader{current: 0.3, target: 0.5, step: 0.2 / sampleRate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.r.Update(tt.new)
+			if diff := cmp.Diff(tt.want, tt.r, cmp.AllowUnexported(Module{}, Reverb{}, fader{}, delayLine{}, reverbChannel{})); diff != "" {
+				t.Errorf("Reverb.Update() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestReverb_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want *Reverb
+	}{
+		{
+			name: "default width",
+			yaml: "size: 0.5\nin: in\n",
+			want: &Reverb{Size: 0.5, Width: 1, In: "in"},
+		},
+		{
+			name: "zero width",
+			yaml: "size: 0.5\nwidth: 0\nin: in\n",
+			want: &Reverb{Size: 0.5, Width: 0, In: "in"},
+		},
+		{
+			name: "small width",
+			yaml: "width: 0.2\n",
+			want: &Reverb{Width: 0.2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &Reverb{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), got); err != nil {
+				t.Fatalf("Reverb.UnmarshalYAML() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(Module{}, Reverb{}, reverbChannel{})); diff != "" {
+				t.Errorf("Reverb.UnmarshalYAML() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestReverb_Step(t *testing.T) {
+	sampleRate := 44100.0
+
+	tests := []struct {
+		name  string
+		r     *Reverb
+		check func(t *testing.T, left, right []float64)
+	}{
+		{
+			name: "dry signal passes unchanged",
+			r:    &Reverb{Size: 0.5, Width: 1, Mix: 0},
+			check: func(t *testing.T, left, right []float64) {
+				if left[0] != 0.5 || right[0] != 0.5 {
+					t.Errorf("got %v and %v, want the impulse", left[0], right[0])
+				}
+				if tail := energy(left[1:]) + energy(right[1:]); tail != 0 {
+					t.Errorf("tail energy = %v, want 0", tail)
+				}
+			},
+		},
+		{
+			name: "wet signal decays",
+			r:    &Reverb{Size: 0.5, Damp: 0.5, Width: 1, Mix: 1},
+			check: func(t *testing.T, left, right []float64) {
+				second := int(sampleRate)
+				early := energy(left[:second/4])
+				late := energy(left[second-second/4 : second])
+				if early == 0 {
+					t.Fatalf("reverb has no output")
+				}
+				if late > early/100 {
+					t.Errorf("late energy = %v, early energy = %v, reverb doesn't decay", late, early)
+				}
+			},
+		},
+		{
+			name: "channels differ at full width",
+			r:    &Reverb{Size: 0.5, Width: 1, Mix: 1},
+			check: func(t *testing.T, left, right []float64) {
+				if c := correlation(left, right); c > 0.5 {
+					t.Errorf("correlation = %v, channels are not decorrelated", c)
+				}
+			},
+		},
+		{
+			name: "channels are the same at zero width",
+			r:    &Reverb{Size: 0.5, Width: 0, Mix: 1},
+			check: func(t *testing.T, left, right []float64) {
+				if energy(left) == 0 {
+					t.Fatalf("reverb has no output")
+				}
+				for i := range left {
+					if left[i] != right[i] {
+						t.Fatalf("left[%d] = %v, right[%d] = %v, output is not mono", i, left[i], i, right[i])
+					}
+				}
+			},
+		},
+		{
+			name: "channels are more alike at a small width",
+			r:    &Reverb{Size: 0.5, Width: 0.05, Mix: 1},
+			check: func(t *testing.T, left, right []float64) {
+				if c := correlation(left, right); c < 0.9 {
+					t.Errorf("correlation = %v, channels are too different", c)
+				}
+			},
+		},
+		{
+			name: "predelay delays the reverb",
+			r:    &Reverb{Size: 0.5, Predelay: 100, Width: 1, Mix: 1},
+			check: func(t *testing.T, left, right []float64) {
+				// the shortest path is the shortest comb followed by all all-pass filters
+				onset := 0.1*sampleRate + 1116
+				if e := energy(left[:int(onset)-1]); e != 0 {
+					t.Errorf("energy before onset = %v, want 0", e)
+				}
+				if e := energy(left[int(onset):]); e == 0 {
+					t.Errorf("no energy after onset")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.r.initialize(sampleRate)
+
+			in := &Module{}
+			modules := NewModuleMap(map[string]IModule{"in": in})
+			tt.r.In = "in"
+
+			var left, right []float64
+			for i := range int(sampleRate) {
+				in.current = Output{}
+				if i == 0 {
+					in.current = monoOutput(1)
+				}
+				tt.r.Step(modules)
+				left = append(left, tt.r.Current().Left)
+				right = append(right, tt.r.Current().Right)
+			}
+
+			tt.check(t, left, right)
+		})
+	}
+}
+
+func energy(x []float64) float64 {
+	var e float64
+	for _, v := range x {
+		e += v * v
+	}
+	return e
+}
+
+func correlation(x, y []float64) float64 {
+	var xy float64
+	for i := range x {
+		xy += x[i] * y[i]
+	}
+	return xy / math.Sqrt(energy(x)*energy(y))
+}
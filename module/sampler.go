@@ -20,17 +20,25 @@ func (s *Sampler) Update(new *Sampler) {
 	s.Trigger = new.Trigger
 }
 
+func (s *Sampler) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"in":      s.In,
+		"trigger": s.Trigger,
+	})
+}
+
+func (s *Sampler) RenameInputs(rename func(string) string) {
+	s.In = rename(s.In)
+	s.Trigger = rename(s.Trigger)
+}
+
 func (s *Sampler) Step(modules *ModuleMap) {
 	triggerValue := getMono(modules, s.Trigger)
 
 	if triggerValue > 0 && s.triggerValue <= 0 {
 		val := getMono(modules, s.In)
 
-		s.current = Output{
-			Mono:  val,
-			Left:  val / 2,
-			Right: val / 2,
-		}
+		s.current = monoOutput(val)
 	}
 
 	s.triggerValue = triggerValue
@@ -13,16 +13,50 @@ import (
 type (
 	Sequencer struct {
 		Module
-		Sequence  []string `yaml:"sequence"`
-		Trigger   string   `yaml:"trigger"`
-		Pitch     float64  `yaml:"pitch"`
-		Transpose float64  `yaml:"transpose"`
-		Randomize bool     `yaml:"randomize"`
-		Index     int      `yaml:"index"`
-
-		sequence     []float64
+		Sequence  []SequencerStep `yaml:"sequence"`
+		Trigger   string          `yaml:"trigger"`
+		Pitch     float64         `yaml:"pitch"`
+		Transpose float64         `yaml:"transpose"`
+		Tuning    *Tuning         `yaml:"tuning"`
+		Randomize bool            `yaml:"randomize"`
+		Index     int             `yaml:"index"`
+
+		sequence     []sequencerStep
 		idx          int
 		triggerValue float64
+		// next is the step that follows the current one, it is chosen in advance so a step can hold its gate open if the next one is tied to it
+		next        int
+		hasNext     bool
+		playing     bool
+		nextPlaying bool
+		// elapsed is the number of samples since the last trigger and period the number of samples between the last two triggers
+		elapsed   float64
+		period    float64
+		triggered bool
+		freq      float64
+		gate      float64
+		velocity  float64
+		// limited holds the modifiers of steps that were out of range
+		limited []LimitedValue
+	}
+
+	// SequencerStep is a step of a sequence, a step without a note is a rest
+	// it is decoded from a map of its fields or from a string like "e_2 v0.8 p0.5 x2 ~"
+	SequencerStep struct {
+		Note        string  `yaml:"note"`
+		Velocity    float64 `yaml:"vel"`
+		Probability float64 `yaml:"prob"`
+		Ratchet     int     `yaml:"ratchet"`
+		Tie         bool    `yaml:"tie"`
+	}
+
+	sequencerStep struct {
+		freq        float64
+		velocity    float64
+		probability float64
+		ratchet     int
+		tie         bool
+		rest        bool
 	}
 
 	SequencerMap map[string]*Sequencer
@@ -41,11 +75,21 @@ func (m SequencerMap) Initialize() error {
 }
 
 func (s *Sequencer) initialize() error {
+	if s.Pitch == 0 {
+		s.Pitch = defaultPitch
+	}
 	s.Pitch = calc.Limit(s.Pitch, pitchRange)
 	s.Transpose = calc.Limit(s.Transpose, transposeRange)
 
 	s.Index = int(calc.Limit(float64(s.Index), calc.Range{Min: 0, Max: float64(len(s.Sequence) - 1)}))
 	s.idx = s.Index - 1
+	s.gate = -1
+
+	if s.Tuning != nil {
+		if err := s.Tuning.initialize(s.Pitch); err != nil {
+			return &FieldError{Field: "tuning", Err: err}
+		}
+	}
 
 	err := s.makeSequence()
 	if err != nil {
@@ -55,6 +99,18 @@ func (s *Sequencer) initialize() error {
 	return nil
 }
 
+// UnmarshalYAML decodes a step from a map of its fields or from a string, which is parsed when the sequencer is initialized
+func (s *SequencerStep) UnmarshalYAML(unmarshal func(any) error) error {
+	var note string
+	if err := unmarshal(&note); err == nil {
+		*s = SequencerStep{Note: note}
+		return nil
+	}
+
+	type plain SequencerStep
+	return unmarshal((*plain)(s))
+}
+
 func (s *Sequencer) Update(new *Sequencer) {
 	if new == nil {
 		return
@@ -65,11 +121,41 @@ func (s *Sequencer) Update(new *Sequencer) {
 	s.Trigger = new.Trigger
 	s.Pitch = new.Pitch
 	s.Transpose = new.Transpose
+	s.Tuning = new.Tuning
 	s.Randomize = new.Randomize
 
 	if s.idx >= len(s.sequence) {
 		s.idx = len(s.sequence) - 1
 	}
+	s.hasNext = false
+}
+
+func (s *Sequencer) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"trigger": s.Trigger,
+	})
+}
+
+func (s *Sequencer) RenameInputs(rename func(string) string) {
+	s.Trigger = rename(s.Trigger)
+}
+
+// Ports returns the ports of the sequencer, pitch is the same as its main output
+func (s *Sequencer) Ports() []string {
+	return []string{"pitch", "gate", "velocity"}
+}
+
+func (s *Sequencer) Port(name string) (Output, bool) {
+	switch name {
+	case "pitch":
+		return s.current, true
+	case "gate":
+		return monoOutput(s.gate), true
+	case "velocity":
+		return monoOutput(s.velocity), true
+	default:
+		return Output{}, false
+	}
 }
 
 func (s *Sequencer) Step(modules *ModuleMap) {
@@ -79,86 +165,219 @@ func (s *Sequencer) Step(modules *ModuleMap) {
 
 	triggerValue := getMono(modules, s.Trigger)
 	if triggerValue > 0 && s.triggerValue <= 0 {
+		s.advance()
+	}
+	s.triggerValue = triggerValue
+
+	s.gate = s.gateValue(triggerValue > 0)
+	s.current = monoOutput(calc.Transpose(s.freq, freqRange, cvRange))
+	s.elapsed++
+}
+
+// advance moves to the next step, rests and skipped steps hold the pitch and velocity of the last step that was played
+func (s *Sequencer) advance() {
+	if s.triggered {
+		s.period = s.elapsed
+	}
+	s.triggered = true
+	s.elapsed = 0
+
+	s.idx, s.playing = s.peek()
+	s.hasNext = false
+
+	if s.playing {
+		s.freq = s.sequence[s.idx].freq
+		s.velocity = s.sequence[s.idx].velocity
+	}
+}
+
+// peek returns the index of the next step and whether it will be played
+func (s *Sequencer) peek() (int, bool) {
+	if !s.hasNext {
 		if s.Randomize {
-			s.idx = rand.Intn(len(s.sequence))
+			s.next = rand.Intn(len(s.sequence))
 		} else {
-			s.idx = (s.idx + 1) % len(s.sequence)
+			s.next = (s.idx + 1) % len(s.sequence)
 		}
+		step := s.sequence[s.next]
+		s.nextPlaying = !step.rest && rand.Float64() < step.probability
+		s.hasNext = true
+	}
+	return s.next, s.nextPlaying
+}
+
+// gateValue returns the velocity of the current step while its gate is open and -1 otherwise
+// without ratchets the gate is open while the trigger is high, a step with ratchets divides the time between two triggers
+// into equal parts and opens the gate for the first half of each part
+func (s *Sequencer) gateValue(high bool) float64 {
+	if s.idx < 0 || !s.playing {
+		return -1
+	}
+	step := s.sequence[s.idx]
+
+	open, last := high, true
+	if step.ratchet > 1 && s.period > 0 {
+		ratchets := float64(step.ratchet)
+		part := s.period / ratchets
+		n := math.Floor(s.elapsed / part)
+		open = n < ratchets && s.elapsed-n*part < part/2
+		last = n >= ratchets-1
 	}
-	s.triggerValue = triggerValue
 
-	var freq float64
-	if s.idx < 0 {
-		freq = 0
-	} else {
-		freq = s.sequence[s.idx]
+	// the gate stays open until the next step, if that one is tied to this one
+	if !open && last {
+		next, playing := s.peek()
+		open = playing && s.sequence[next].tie
 	}
 
-	val := calc.Transpose(freq, freqRange, cvRange)
-	s.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
+	if !open {
+		return -1
 	}
+	return step.velocity
 }
 
+// makeSequence parses all steps and reports the problems of each of them rather than stopping at the first one
 func (s *Sequencer) makeSequence() error {
-	var sequence []float64
+	var (
+		sequence []sequencerStep
+		errs     FieldErrors
+	)
+	s.limited = nil
 
-	for _, n := range s.Sequence {
-		freq, err := noteToFreq(n, s.Pitch, s.Transpose)
+	for i, step := range s.Sequence {
+		field := fmt.Sprintf("sequence[%d]", i)
+		parsed, limited, err := parseStep(step, s.noteToFreq)
+		for _, l := range limited {
+			l.Field = field + "." + l.Field
+			s.limited = append(s.limited, l)
+		}
 		if err != nil {
-			return err
+			errs = append(errs, &FieldError{Field: field, Err: err})
+			continue
 		}
-		sequence = append(sequence, freq)
+		sequence = append(sequence, parsed)
 	}
 
+	if len(errs) > 0 {
+		return errs
+	}
 	s.sequence = sequence
 	return nil
 }
 
-func noteToFreq(note string, pitch, transpose float64) (float64, error) {
-	notesMap := map[string]int{
-		"c":  -9,
-		"c#": -8,
-		"db": -8,
-		"d":  -7,
-		"d#": -6,
-		"eb": -6,
-		"e":  -5,
-		"e#": -4,
-		"fb": -5,
-		"f":  -4,
-		"f#": -3,
-		"gb": -3,
-		"g":  -2,
-		"g#": -1,
-		"ab": -1,
-		"a":  0,
-		"a#": 1,
-		"bb": 1,
-		"b":  2,
-		"b#": 3,
-		"cb": 2,
-	}
-
-	noteString, octaveString, found := strings.Cut(note, "_")
-	if !found {
-		return 0, fmt.Errorf("invalid syntax for note %s, missing underscore", note)
-	}
-
-	octave, err := strconv.Atoi(octaveString)
+func (s *Sequencer) Limited() []LimitedValue {
+	return s.limited
+}
+
+// noteToFreq returns the frequency of a note in the tuning of the sequencer or in equal temperament if it has none
+func (s *Sequencer) noteToFreq(note string) (float64, error) {
+	if s.Tuning != nil {
+		return s.Tuning.noteToFreq(note, s.Transpose)
+	}
+	return noteToFreq(note, s.Pitch, s.Transpose)
+}
+
+// parseStep parses the note of a step, which may be followed by modifiers, e.g. "e_2 v0.8 p0.5 x2 ~"
+// v sets the velocity, p the probability, x the number of ratchets and ~ ties the step to the previous one
+// a step without a note or with the note - is a rest
+// besides the step it returns the modifiers that were out of range
+func parseStep(step SequencerStep, noteToFreq func(string) (float64, error)) (sequencerStep, []LimitedValue, error) {
+	parsed := sequencerStep{
+		velocity:    step.Velocity,
+		probability: step.Probability,
+		ratchet:     step.Ratchet,
+		tie:         step.Tie,
+	}
+
+	var note string
+	for _, field := range strings.Fields(step.Note) {
+		var err error
+		switch {
+		case field == "~":
+			parsed.tie = true
+		case strings.HasPrefix(field, "v"):
+			parsed.velocity, err = strconv.ParseFloat(field[1:], 64)
+		case strings.HasPrefix(field, "p"):
+			parsed.probability, err = strconv.ParseFloat(field[1:], 64)
+		case strings.HasPrefix(field, "x"):
+			parsed.ratchet, err = strconv.Atoi(field[1:])
+		case note != "":
+			return sequencerStep{}, nil, fmt.Errorf("step %q has more than one note", step.Note)
+		default:
+			note = field
+		}
+		if err != nil {
+			return sequencerStep{}, nil, fmt.Errorf("invalid modifier %q in step %q", field, step.Note)
+		}
+	}
+
+	if parsed.velocity == 0 {
+		parsed.velocity = 1
+	}
+	if parsed.probability == 0 {
+		parsed.probability = 1
+	}
+	if parsed.ratchet == 0 {
+		parsed.ratchet = 1
+	}
+	var limited []LimitedValue
+	parsed.velocity = limit(parsed.velocity, gainRange, "vel", &limited)
+	parsed.probability = limit(parsed.probability, probabilityRange, "prob", &limited)
+	parsed.ratchet = int(limit(float64(parsed.ratchet), ratchetRange, "ratchet", &limited))
+
+	if note == "" || note == "-" {
+		parsed.rest = true
+		return parsed, limited, nil
+	}
+
+	freq, err := noteToFreq(note)
 	if err != nil {
-		return 0, fmt.Errorf("unable to parse octave for note %s", note)
+		return sequencerStep{}, limited, err
 	}
+	parsed.freq = freq
+	return parsed, limited, nil
+}
+
+// noteOffsets holds the distance of each note name to a in semitones
+var noteOffsets = map[string]int{
+	"c":  -9,
+	"c#": -8,
+	"db": -8,
+	"d":  -7,
+	"d#": -6,
+	"eb": -6,
+	"e":  -5,
+	"e#": -4,
+	"fb": -5,
+	"f":  -4,
+	"f#": -3,
+	"gb": -3,
+	"g":  -2,
+	"g#": -1,
+	"ab": -1,
+	"a":  0,
+	"a#": 1,
+	"bb": 1,
+	"b":  2,
+	"b#": 3,
+	"cb": 2,
+}
 
-	if octave < 0 || octave > 10 {
-		return 0, fmt.Errorf("octave must be at least 0 and at most 10 for note %s", note)
+// noteToFreq returns the frequency of a note in equal temperament
+// a note is a note name or the number of semitones above c followed by an octave, e.g. a_4 or 9_4
+func noteToFreq(note string, pitch, transpose float64) (float64, error) {
+	name, octave, err := splitNote(note)
+	if err != nil {
+		return 0, err
 	}
 
-	n, ok := notesMap[noteString]
+	n, ok := noteOffsets[name]
 	if !ok {
-		return 0, fmt.Errorf("unknown note %s", noteString)
+		degree, err := strconv.Atoi(name)
+		if err != nil {
+			return 0, fmt.Errorf("unknown note %s", name)
+		}
+		n = degree + noteOffsets["c"]
 	}
 
 	interval := transpose + float64(n)
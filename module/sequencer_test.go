@@ -1,10 +1,12 @@
 package module
 
 import (
+	"errors"
 	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/iljarotar/synth/calc"
 )
 
@@ -107,6 +109,18 @@ func Test_noteToFreq(t *testing.T) {
 			pitch: 440,
 			want:  440 * math.Pow(2, 9.0/12),
 		},
+		{
+			name:  "degree",
+			note:  "9_4",
+			pitch: 440,
+			want:  440,
+		},
+		{
+			name:  "degree beyond the octave",
+			note:  "14_4",
+			pitch: 440,
+			want:  440 * math.Pow(2, 5.0/12),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -122,11 +136,73 @@ func Test_noteToFreq(t *testing.T) {
 	}
 }
 
+func TestSequencer_tuning(t *testing.T) {
+	s := &Sequencer{
+		Sequence:  []SequencerStep{{Note: "0_4"}, {Note: "11_4"}, {Note: "a_3"}},
+		Pitch:     440,
+		Transpose: 12,
+		Tuning:    &Tuning{EDO: 19, Base: 200},
+	}
+	if err := s.initialize(); err != nil {
+		t.Fatalf("Sequencer.initialize() error = %v", err)
+	}
+
+	// a is 900 cents above c, which is closest to the 14th degree in 19-EDO
+	want := []float64{400, 400 * math.Pow(2, 11.0/19), 200 * math.Pow(2, 14.0/19)}
+	var got []float64
+	for _, step := range s.sequence {
+		got = append(got, step.freq)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Sequencer.initialize() diff = %s", diff)
+	}
+
+	s = &Sequencer{Sequence: []SequencerStep{{Note: "a_4"}}, Pitch: 440, Tuning: &Tuning{}}
+	if err := s.initialize(); err == nil {
+		t.Errorf("Sequencer.initialize() expected an error for a tuning without degrees")
+	}
+}
+
+func TestSequencer_makeSequence_problems(t *testing.T) {
+	s := &Sequencer{
+		Sequence: []SequencerStep{
+			{Note: "a_4 v2 p1.5"},
+			{Note: "h_4"},
+			{Note: "a_4"},
+			{Note: "a_4 vx"},
+			{Note: "-", Ratchet: 100},
+		},
+		Pitch: 440,
+	}
+
+	err := s.makeSequence()
+	var errs FieldErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Sequencer.makeSequence() error = %v, want FieldErrors", err)
+	}
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	if diff := cmp.Diff([]string{"sequence[1]", "sequence[3]"}, fields); diff != "" {
+		t.Errorf("Sequencer.makeSequence() error fields diff = %s", diff)
+	}
+
+	want := []LimitedValue{
+		{Field: "sequence[0].vel", Value: 2, Limit: 1},
+		{Field: "sequence[0].prob", Value: 1.5, Limit: 1},
+		{Field: "sequence[4].ratchet", Value: 100, Limit: 16},
+	}
+	if diff := cmp.Diff(want, s.Limited()); diff != "" {
+		t.Errorf("Sequencer.Limited() diff = %s", diff)
+	}
+}
+
 func TestSequencer_makeSequence(t *testing.T) {
 	tests := []struct {
 		name    string
 		s       *Sequencer
-		want    []float64
+		want    []sequencerStep
 		wantErr bool
 	}{
 		{
@@ -138,7 +214,7 @@ func TestSequencer_makeSequence(t *testing.T) {
 		{
 			name: "sequence with error",
 			s: &Sequencer{
-				Sequence: []string{"a_4", "bb_3", "e#_11"},
+				Sequence: []SequencerStep{{Note: "a_4"}, {Note: "bb_3"}, {Note: "e#_11"}},
 				Pitch:    440,
 			},
 			want:    nil,
@@ -147,19 +223,56 @@ func TestSequencer_makeSequence(t *testing.T) {
 		{
 			name: "valid sequence",
 			s: &Sequencer{
-				Sequence: []string{"a_4", "a_3", "a_5"},
+				Sequence: []SequencerStep{{Note: "a_4"}, {Note: "a_3"}, {Note: "a_5"}},
 				Pitch:    440,
 			},
-			want:    []float64{440, 220, 880},
+			want:    notes(440, 220, 880),
 			wantErr: false,
 		},
+		{
+			name: "steps with modifiers",
+			s: &Sequencer{
+				Sequence: []SequencerStep{
+					{Note: "a_4 v0.5 p0.25 x3 ~"},
+					{Note: "a_3", Velocity: 0.8, Probability: 0.5, Ratchet: 2, Tie: true},
+					{Note: "-"},
+					{},
+				},
+				Pitch: 440,
+			},
+			want: []sequencerStep{
+				{freq: 440, velocity: 0.5, probability: 0.25, ratchet: 3, tie: true},
+				{freq: 220, velocity: 0.8, probability: 0.5, ratchet: 2, tie: true},
+				{velocity: 1, probability: 1, ratchet: 1, rest: true},
+				{velocity: 1, probability: 1, ratchet: 1, rest: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid modifier",
+			s: &Sequencer{
+				Sequence: []SequencerStep{{Note: "a_4 vx"}},
+				Pitch:    440,
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "more than one note",
+			s: &Sequencer{
+				Sequence: []SequencerStep{{Note: "a_4 b_4"}},
+				Pitch:    440,
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if err := tt.s.makeSequence(); (err != nil) != tt.wantErr {
 				t.Errorf("Sequencer.makeSequence() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if diff := cmp.Diff(tt.want, tt.s.sequence); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.s.sequence, cmp.AllowUnexported(sequencerStep{})); diff != "" {
 				t.Errorf("Sequencer.makeSequence() diff = %v", diff)
 			}
 		})
@@ -178,7 +291,7 @@ func TestSequencer_Step(t *testing.T) {
 			name: "before first trigger",
 			s: &Sequencer{
 				Module:       Module{},
-				sequence:     []float64{440, 220, 110},
+				sequence:     notes(440, 220, 110),
 				idx:          -1,
 				triggerValue: 0,
 			},
@@ -191,7 +304,7 @@ func TestSequencer_Step(t *testing.T) {
 			s: &Sequencer{
 				Module:       Module{},
 				Trigger:      "trigger",
-				sequence:     []float64{440, 220, 110},
+				sequence:     notes(440, 220, 110),
 				idx:          1,
 				triggerValue: 0,
 			},
@@ -210,7 +323,7 @@ func TestSequencer_Step(t *testing.T) {
 			s: &Sequencer{
 				Module:       Module{},
 				Trigger:      "trigger",
-				sequence:     []float64{440, 220, 110},
+				sequence:     notes(440, 220, 110),
 				idx:          2,
 				triggerValue: 0,
 			},
@@ -239,6 +352,129 @@ func TestSequencer_Step(t *testing.T) {
 	}
 }
 
+func TestSequencer_gate(t *testing.T) {
+	// the trigger rises every 100 samples and stays high for 10 samples
+	trigger := func(i int) float64 {
+		if i%100 < 10 {
+			return 1
+		}
+		return -1
+	}
+
+	tests := []struct {
+		name     string
+		sequence []SequencerStep
+		// want maps samples to the expected gate
+		want map[int]float64
+	}{
+		{
+			name:     "gate follows the trigger",
+			sequence: []SequencerStep{{Note: "a_4"}},
+			want:     map[int]float64{0: 1, 9: 1, 10: -1, 99: -1, 100: 1},
+		},
+		{
+			name:     "velocity",
+			sequence: []SequencerStep{{Note: "a_4 v0.5"}, {Note: "a_4", Velocity: 0.8}},
+			want:     map[int]float64{0: 0.5, 10: -1, 100: 0.8, 110: -1},
+		},
+		{
+			name:     "rest",
+			sequence: []SequencerStep{{Note: "a_4"}, {Note: "-"}},
+			want:     map[int]float64{0: 1, 100: -1, 105: -1, 200: 1},
+		},
+		{
+			name:     "ratchets start once the period is known",
+			sequence: []SequencerStep{{Note: "a_4 x2"}},
+			want:     map[int]float64{0: 1, 10: -1, 100: 1, 124: 1, 125: -1, 150: 1, 174: 1, 175: -1, 199: -1},
+		},
+		{
+			name:     "tie holds the gate open",
+			sequence: []SequencerStep{{Note: "a_4"}, {Note: "a_3 ~"}, {Note: "a_4"}},
+			want:     map[int]float64{0: 1, 10: 1, 99: 1, 100: 1, 110: -1, 200: 1},
+		},
+		{
+			name:     "tie after ratchets",
+			sequence: []SequencerStep{{Note: "a_4 x2"}, {Note: "a_3", Tie: true}},
+			want:     map[int]float64{200: 1, 225: -1, 250: 1, 275: 1, 299: 1, 300: 1, 310: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Sequencer{Sequence: tt.sequence, Trigger: "trigger", Pitch: 440}
+			if err := s.initialize(); err != nil {
+				t.Fatalf("Sequencer.initialize() error = %v", err)
+			}
+
+			trig := &Module{}
+			modules := NewModuleMap(map[string]IModule{"trigger": trig, "seq": s})
+
+			got := map[int]float64{}
+			for i := range 400 {
+				trig.current = monoOutput(trigger(i))
+				s.Step(modules)
+				if _, ok := tt.want[i]; ok {
+					got[i] = getMono(modules, "seq.gate")
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Sequencer.Step() gate diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestSequencer_Port(t *testing.T) {
+	s := &Sequencer{
+		Sequence: []SequencerStep{{Note: "a_4 v0.5"}, {Note: "-"}},
+		Trigger:  "trigger",
+		Pitch:    440,
+	}
+	if err := s.initialize(); err != nil {
+		t.Fatalf("Sequencer.initialize() error = %v", err)
+	}
+
+	trig := &Module{}
+	modules := NewModuleMap(map[string]IModule{"trigger": trig, "seq": s})
+	pitch := calc.Transpose(440, freqRange, cvRange)
+
+	tests := []struct {
+		name    string
+		trigger float64
+		want    map[string]float64
+	}{
+		{
+			name:    "note",
+			trigger: 1,
+			want:    map[string]float64{"seq": pitch, "seq.pitch": pitch, "seq.gate": 0.5, "seq.velocity": 0.5},
+		},
+		{
+			name:    "gate closes",
+			trigger: -1,
+			want:    map[string]float64{"seq": pitch, "seq.pitch": pitch, "seq.gate": -1, "seq.velocity": 0.5},
+		},
+		{
+			name:    "rest holds pitch and velocity",
+			trigger: 1,
+			want:    map[string]float64{"seq": pitch, "seq.pitch": pitch, "seq.gate": -1, "seq.velocity": 0.5, "seq.unknown": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trig.current = monoOutput(tt.trigger)
+			s.Step(modules)
+
+			got := map[string]float64{}
+			for name := range tt.want {
+				got[name] = getMono(modules, name)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Sequencer.Port() diff = %s", diff)
+			}
+		})
+	}
+}
+
 func TestSequencer_Update(t *testing.T) {
 	tests := []struct {
 		name string
@@ -254,13 +490,13 @@ func TestSequencer_Update(t *testing.T) {
 						Mono: 1,
 					},
 				},
-				Sequence:     []string{"a_4"},
+				Sequence:     []SequencerStep{{Note: "a_4"}},
 				Trigger:      "trigger",
 				Pitch:        440,
 				Transpose:    1,
 				Randomize:    true,
 				Index:        1,
-				sequence:     []float64{440},
+				sequence:     notes(440),
 				idx:          1,
 				triggerValue: 1,
 			},
@@ -271,13 +507,13 @@ func TestSequencer_Update(t *testing.T) {
 						Mono: 1,
 					},
 				},
-				Sequence:     []string{"a_4"},
+				Sequence:     []SequencerStep{{Note: "a_4"}},
 				Trigger:      "trigger",
 				Pitch:        440,
 				Transpose:    1,
 				Randomize:    true,
 				Index:        1,
-				sequence:     []float64{440},
+				sequence:     notes(440),
 				idx:          1,
 				triggerValue: 1,
 			},
@@ -290,24 +526,24 @@ func TestSequencer_Update(t *testing.T) {
 						Mono: 1,
 					},
 				},
-				Sequence:     []string{"a_4", "a_2", "a_3"},
+				Sequence:     []SequencerStep{{Note: "a_4"}, {Note: "a_2"}, {Note: "a_3"}},
 				Trigger:      "trigger",
 				Pitch:        440,
 				Transpose:    1,
 				Randomize:    true,
 				Index:        2,
-				sequence:     []float64{440, 110, 220},
+				sequence:     notes(440, 110, 220),
 				idx:          2,
 				triggerValue: 1,
 			},
 			new: &Sequencer{
-				Sequence:  []string{"a_5", "a_3"},
+				Sequence:  []SequencerStep{{Note: "a_5"}, {Note: "a_3"}},
 				Trigger:   "new-trigger",
 				Pitch:     441,
 				Transpose: 2,
 				Randomize: false,
 				Index:     1,
-				sequence:  []float64{880, 220},
+				sequence:  notes(880, 220),
 			},
 			want: &Sequencer{
 				Module: Module{
@@ -315,13 +551,13 @@ func TestSequencer_Update(t *testing.T) {
 						Mono: 1,
 					},
 				},
-				Sequence:     []string{"a_5", "a_3"},
+				Sequence:     []SequencerStep{{Note: "a_5"}, {Note: "a_3"}},
 				Trigger:      "new-trigger",
 				Pitch:        441,
 				Transpose:    2,
 				Randomize:    false,
 				Index:        2,
-				sequence:     []float64{880, 220},
+				sequence:     notes(880, 220),
 				idx:          1,
 				triggerValue: 1,
 			},
@@ -330,7 +566,7 @@ func TestSequencer_Update(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.s.Update(tt.new)
-			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Module{}, Sequencer{})); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Module{}, Sequencer{}, sequencerStep{})); diff != "" {
 				t.Errorf("Sequencer.Update() diff = %s", diff)
 			}
 		})
@@ -347,26 +583,27 @@ func TestSequencer_initialize(t *testing.T) {
 		{
 			name: "set limits correctly",
 			s: &Sequencer{
-				Sequence:     []string{"a_4", "a_3"},
+				Sequence:     []SequencerStep{{Note: "a_4"}, {Note: "a_3"}},
 				Trigger:      "trigger",
 				Pitch:        540,
 				Transpose:    25,
 				Randomize:    true,
 				Index:        2,
-				sequence:     []float64{},
+				sequence:     []sequencerStep{},
 				idx:          0,
 				triggerValue: 0,
 			},
 			want: &Sequencer{
-				Sequence:     []string{"a_4", "a_3"},
+				Sequence:     []SequencerStep{{Note: "a_4"}, {Note: "a_3"}},
 				Trigger:      "trigger",
 				Pitch:        500,
 				Transpose:    24,
 				Randomize:    true,
 				Index:        1,
-				sequence:     []float64{2000, 1000},
+				sequence:     notes(2000, 1000),
 				idx:          0,
 				triggerValue: 0,
+				gate:         -1,
 			},
 			wantErr: false,
 		},
@@ -376,9 +613,18 @@ func TestSequencer_initialize(t *testing.T) {
 			if err := tt.s.initialize(); (err != nil) != tt.wantErr {
 				t.Errorf("Sequencer.initialize() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Module{}, Sequencer{})); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Module{}, Sequencer{}, sequencerStep{})); diff != "" {
 				t.Errorf("Sequencer.initialize() diff = %s", diff)
 			}
 		})
 	}
 }
+
+// notes returns a sequence of steps that play the given frequencies
+func notes(freqs ...float64) []sequencerStep {
+	var steps []sequencerStep
+	for _, freq := range freqs {
+		steps = append(steps, sequencerStep{freq: freq, velocity: 1, probability: 1, ratchet: 1})
+	}
+	return steps
+}
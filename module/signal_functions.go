@@ -5,14 +5,20 @@ import (
 	"math"
 )
 
-type SignalFunc func(x float64) float64
+type (
+	SignalFunc func(x float64) float64
+
+	// BandLimitedSignalFunc takes the phase increment per sample in cycles as dt to smooth out discontinuities that would otherwise alias
+	BandLimitedSignalFunc func(x, dt float64) float64
+
+	// PulseWidthSignalFunc outputs a pulse wave that is high for the given width in percent of one cycle
+	PulseWidthSignalFunc func(x, dt, width float64) float64
+)
 
 func newSignalFunc(oscType oscillatorType) (SignalFunc, error) {
 	switch oscType {
 	case oscillatorTypeSine:
 		return SineSignalFunc(), nil
-	case oscillatorTypeSquare:
-		return SquareSignalFunc(), nil
 	case oscillatorTypeSawtooth:
 		return SawtoothSignalFunc(), nil
 	case oscillatorTypeTriangle:
@@ -24,6 +30,28 @@ func newSignalFunc(oscType oscillatorType) (SignalFunc, error) {
 	}
 }
 
+func newBandLimitedSignalFunc(oscType oscillatorType) (BandLimitedSignalFunc, bool) {
+	switch oscType {
+	case oscillatorTypeSawtoothBL:
+		return SawtoothBLSignalFunc(), true
+	case oscillatorTypeReverseSawtoothBL:
+		return ReverseSawtoothBLSignalFunc(), true
+	default:
+		return nil, false
+	}
+}
+
+func newPulseWidthSignalFunc(oscType oscillatorType) (PulseWidthSignalFunc, bool) {
+	switch oscType {
+	case oscillatorTypeSquare:
+		return PulseSignalFunc(), true
+	case oscillatorTypeSquareBL:
+		return PulseBLSignalFunc(), true
+	default:
+		return nil, false
+	}
+}
+
 func NoSignalFunc() SignalFunc {
 	f := func(x float64) float64 {
 		return 0
@@ -39,18 +67,6 @@ func SineSignalFunc() SignalFunc {
 	return sine
 }
 
-func SquareSignalFunc() SignalFunc {
-	square := func(x float64) float64 {
-		y := math.Sin(x)
-		if y > 0 {
-			return 1
-		}
-		return -1
-	}
-
-	return square
-}
-
 func TriangleSignalFunc() SignalFunc {
 	triangle := func(x float64) float64 {
 		return 2 / math.Pi * math.Asin(math.Sin(x))
@@ -74,3 +90,71 @@ func ReverseSawtoothSignalFunc() SignalFunc {
 
 	return sawtooth
 }
+
+func PulseSignalFunc() PulseWidthSignalFunc {
+	pulse := func(x, _, width float64) float64 {
+		if cycle(x) < width {
+			return 1
+		}
+		return -1
+	}
+
+	return pulse
+}
+
+func PulseBLSignalFunc() PulseWidthSignalFunc {
+	pulse := func(x, dt, width float64) float64 {
+		t := cycle(x)
+		y := -1.0
+		if t < width {
+			y = 1
+		}
+		// the pulse steps up at the start of each cycle and down after width
+		return y + polyBLEP(t, dt) - polyBLEP(math.Mod(t+1-width, 1), dt)
+	}
+
+	return pulse
+}
+
+func SawtoothBLSignalFunc() BandLimitedSignalFunc {
+	sawtooth := func(x, dt float64) float64 {
+		// the naive sawtooth jumps from 1 to -1 in the middle of each cycle
+		t := math.Mod(cycle(x)+0.5, 1)
+		return 2*t - 1 - polyBLEP(t, dt)
+	}
+
+	return sawtooth
+}
+
+func ReverseSawtoothBLSignalFunc() BandLimitedSignalFunc {
+	sawtooth := SawtoothBLSignalFunc()
+	reverse := func(x, dt float64) float64 {
+		return -sawtooth(x, dt)
+	}
+
+	return reverse
+}
+
+// cycle returns the position of x within its cycle in the interval [0, 1)
+func cycle(x float64) float64 {
+	t := x / (2 * math.Pi)
+	return t - math.Floor(t)
+}
+
+// polyBLEP returns the correction of an upward step of height 2 at t = 0 for a phase increment of dt
+// it is non-zero only within one sample around the step
+func polyBLEP(t, dt float64) float64 {
+	dt = math.Min(math.Abs(dt), 0.5)
+	switch {
+	case dt == 0:
+		return 0
+	case t < dt:
+		t /= dt
+		return 2*t - t*t - 1
+	case t > 1-dt:
+		t = (t - 1) / dt
+		return t*t + 2*t + 1
+	default:
+		return 0
+	}
+}
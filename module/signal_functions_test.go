@@ -0,0 +1,183 @@
+package module
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBandLimitedSignalFunc_aliasing(t *testing.T) {
+	sampleRate := 44100.0
+	// the harmonics of such a high and odd frequency fold back in between the harmonics
+	freq := 2637.0
+
+	tests := []struct {
+		name  string
+		naive SignalFunc
+		bl    BandLimitedSignalFunc
+	}{
+		{
+			name:  "sawtooth",
+			naive: SawtoothSignalFunc(),
+			bl:    SawtoothBLSignalFunc(),
+		},
+		{
+			name:  "reverse sawtooth",
+			naive: ReverseSawtoothSignalFunc(),
+			bl:    ReverseSawtoothBLSignalFunc(),
+		},
+		{
+			name:  "square",
+			naive: withWidth(PulseSignalFunc(), defaultWidth),
+			bl:    withBLWidth(PulseBLSignalFunc(), defaultWidth),
+		},
+		{
+			name:  "pulse",
+			naive: withWidth(PulseSignalFunc(), 0.3),
+			bl:    withBLWidth(PulseBLSignalFunc(), 0.3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt := freq / sampleRate
+			naive := make([]float64, int(sampleRate))
+			bl := make([]float64, int(sampleRate))
+			for i := range naive {
+				x := 2 * math.Pi * dt * float64(i)
+				naive[i] = tt.naive(x)
+				bl[i] = tt.bl(x, dt)
+			}
+
+			naiveAlias := aliasEnergy(naive, freq, sampleRate)
+			blAlias := aliasEnergy(bl, freq, sampleRate)
+
+			// at least 10dB less aliasing
+			if blAlias > naiveAlias/10 {
+				t.Errorf("alias energy = %v, naive alias energy = %v", blAlias, naiveAlias)
+			}
+
+			// the fundamental remains unchanged
+			naiveFundamental := power(naive, freq, sampleRate)
+			blFundamental := power(bl, freq, sampleRate)
+			if math.Abs(blFundamental-naiveFundamental) > 0.05*naiveFundamental {
+				t.Errorf("fundamental power = %v, naive fundamental power = %v", blFundamental, naiveFundamental)
+			}
+		})
+	}
+}
+
+func TestBandLimitedSignalFunc_lowFrequencies(t *testing.T) {
+	dt := 1 / 44100.0
+
+	tests := []struct {
+		name  string
+		naive SignalFunc
+		bl    BandLimitedSignalFunc
+	}{
+		{
+			name:  "sawtooth",
+			naive: SawtoothSignalFunc(),
+			bl:    SawtoothBLSignalFunc(),
+		},
+		{
+			name:  "reverse sawtooth",
+			naive: ReverseSawtoothSignalFunc(),
+			bl:    ReverseSawtoothBLSignalFunc(),
+		},
+		{
+			name:  "square",
+			naive: withWidth(PulseSignalFunc(), defaultWidth),
+			bl:    withBLWidth(PulseBLSignalFunc(), defaultWidth),
+		},
+		{
+			name:  "pulse",
+			naive: withWidth(PulseSignalFunc(), 0.3),
+			bl:    withBLWidth(PulseBLSignalFunc(), 0.3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// away from the discontinuities both variants are the same
+			for _, x := range []float64{0.1, 1, 2, 4, 5, 6} {
+				if got, want := tt.bl(x, dt), tt.naive(x); math.Abs(got-want) > 1e-9 {
+					t.Errorf("signal(%v) = %v, want %v", x, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPulseSignalFunc_width(t *testing.T) {
+	sampleRate := 44100.0
+	freq := 100.0
+
+	for _, width := range []float64{0.1, 0.25, 0.5, 0.9} {
+		naive := withWidth(PulseSignalFunc(), width)
+		bl := PulseBLSignalFunc()
+
+		var naiveSum, blSum float64
+		dt := freq / sampleRate
+		for i := range int(sampleRate) {
+			x := 2 * math.Pi * dt * float64(i)
+			naiveSum += naive(x)
+			blSum += bl(x, dt, width)
+		}
+
+		// the mean of a pulse wave depends on the share of the cycle that is high
+		want := 2*width - 1
+		// the naive pulse is only accurate to one sample
+		if got := naiveSum / sampleRate; math.Abs(got-want) > 2*dt {
+			t.Errorf("width %v: naive mean = %v, want %v", width, got, want)
+		}
+		if got := blSum / sampleRate; math.Abs(got-want) > 1e-3 {
+			t.Errorf("width %v: band-limited mean = %v, want %v", width, got, want)
+		}
+	}
+}
+
+func withWidth(pulse PulseWidthSignalFunc, width float64) SignalFunc {
+	return func(x float64) float64 {
+		return pulse(x, 0, width)
+	}
+}
+
+func withBLWidth(pulse PulseWidthSignalFunc, width float64) BandLimitedSignalFunc {
+	return func(x, dt float64) float64 {
+		return pulse(x, dt, width)
+	}
+}
+
+// aliasEnergy returns the energy of a signal that is neither located at the harmonics of freq nor at 0Hz
+func aliasEnergy(signal []float64, freq, sampleRate float64) float64 {
+	var total, mean float64
+	for _, y := range signal {
+		total += y * y
+		mean += y
+	}
+	total /= float64(len(signal))
+	mean /= float64(len(signal))
+	// the offset of asymmetric waves is not aliasing
+	total -= mean * mean
+
+	var harmonics float64
+	for f := freq; f < sampleRate/2; f += freq {
+		harmonics += power(signal, f, sampleRate)
+	}
+
+	return total - harmonics
+}
+
+// power returns the mean power of a signal at the given frequency using the goertzel algorithm
+// the frequency must be a multiple of the frequency resolution sampleRate/len(signal)
+func power(signal []float64, freq, sampleRate float64) float64 {
+	var (
+		n      = float64(len(signal))
+		coeff  = 2 * math.Cos(2*math.Pi*freq/sampleRate)
+		s1, s2 float64
+	)
+	for _, y := range signal {
+		s1, s2 = y+coeff*s1-s2, s1
+	}
+
+	magnitude := s1*s1 + s2*s2 - coeff*s1*s2
+	return 2 * magnitude / (n * n)
+}
@@ -0,0 +1,389 @@
+package module
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Tuning defines the degrees of a scale that repeats at its period, which is usually an octave
+	// the degrees are given inline, as an equal division of the octave or by a Scala file, optionally with a keyboard mapping
+	Tuning struct {
+		// Steps are the degrees above the first one in the notation of Scala files, a ratio like 3/2 or cents like 701.955
+		// the last step is the period
+		Steps []string `yaml:"steps"`
+		EDO   int      `yaml:"edo"`
+		Scl   string   `yaml:"scl"`
+		Kbm   string   `yaml:"kbm"`
+		// Base is the frequency of the first degree in the fourth octave
+		Base float64 `yaml:"base"`
+
+		// degrees holds the degrees of the scale in cents, the first one is 0 and the period follows the last one
+		degrees  []float64
+		period   float64
+		keyboard *keyboard
+	}
+
+	// keyboard is a keyboard mapping of a Scala .kbm file
+	keyboard struct {
+		// size is the number of keys after which the mapping repeats, 0 maps each key to the degree of the same number
+		size int
+		// middle is the key of the first degree, reference the key that sounds at freq
+		middle    int
+		reference int
+		freq      float64
+		// octave is the degree that each repetition of the mapping is transposed by
+		octave int
+		// mapping holds the degree of each key, -1 for keys that aren't mapped
+		mapping []int
+	}
+)
+
+const edoMax = 1200
+
+func (t *Tuning) initialize(pitch float64) error {
+	var (
+		steps []float64
+		err   error
+	)
+
+	switch {
+	case len(t.Steps) > 0 && t.EDO == 0 && t.Scl == "":
+		steps, err = parseSteps(t.Steps)
+	case len(t.Steps) == 0 && t.EDO != 0 && t.Scl == "":
+		steps, err = edoSteps(t.EDO)
+	case len(t.Steps) == 0 && t.EDO == 0 && t.Scl != "":
+		steps, err = readScl(t.Scl)
+	default:
+		err = errors.New("a tuning needs exactly one of steps, edo and scl")
+	}
+	if err != nil {
+		return err
+	}
+
+	t.period = steps[len(steps)-1]
+	if t.period <= 0 {
+		return fmt.Errorf("the period of a tuning must be greater than 0, got %v cents", t.period)
+	}
+	t.degrees = append([]float64{0}, steps[:len(steps)-1]...)
+
+	t.keyboard = nil
+	if t.Kbm != "" {
+		t.keyboard, err = readKbm(t.Kbm)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.initializeBase(pitch)
+}
+
+// initializeBase sets the base to the reference of the keyboard mapping or to c_4 in equal temperament, unless it is given
+func (t *Tuning) initializeBase(pitch float64) error {
+	if t.Base > 0 {
+		return nil
+	}
+
+	if t.keyboard == nil {
+		t.Base = pitch * math.Pow(2, -9.0/12)
+		return nil
+	}
+
+	cents, ok := t.keyCents(t.keyboard.reference - t.keyboard.middle)
+	if !ok {
+		return fmt.Errorf("reference key %d is not mapped", t.keyboard.reference)
+	}
+	t.Base = t.keyboard.freq / math.Pow(2, cents/1200)
+	return nil
+}
+
+// noteToFreq returns the frequency of a note in the tuning
+// a note is a degree or a key of the keyboard mapping followed by an octave, e.g. 7_4
+// note names are mapped to the degree that is closest to their distance from c in equal temperament
+func (t *Tuning) noteToFreq(note string, transpose float64) (float64, error) {
+	name, octave, err := splitNote(note)
+	if err != nil {
+		return 0, err
+	}
+
+	var cents float64
+	if key, err := strconv.Atoi(name); err == nil {
+		var ok bool
+		cents, ok = t.keyCents(key + t.keysPerOctave()*(octave-4))
+		if !ok {
+			return 0, fmt.Errorf("note %s is not mapped", note)
+		}
+	} else {
+		n, ok := noteOffsets[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown note %s", name)
+		}
+		cents = t.degreeCents(t.closestDegree(float64(n+9)*100) + len(t.degrees)*(octave-4))
+	}
+
+	return t.Base * math.Pow(2, cents/1200+transpose/12), nil
+}
+
+// degreeCents returns the distance of a degree to the first degree in cents, degrees beyond the scale are in other periods
+func (t *Tuning) degreeCents(degree int) float64 {
+	n := len(t.degrees)
+	period, i := floorDiv(degree, n)
+	return float64(period)*t.period + t.degrees[i]
+}
+
+// keyCents returns the distance of the degree of a key relative to the middle key to the first degree in cents
+// without a keyboard mapping each key is the degree of the same number
+func (t *Tuning) keyCents(key int) (float64, bool) {
+	if t.keyboard == nil || t.keyboard.size == 0 {
+		return t.degreeCents(key), true
+	}
+
+	repetition, i := floorDiv(key, t.keyboard.size)
+	degree := t.keyboard.mapping[i]
+	if degree < 0 {
+		return 0, false
+	}
+
+	octave := t.keyboard.octave
+	if octave == 0 {
+		octave = len(t.degrees)
+	}
+	return t.degreeCents(degree + repetition*octave), true
+}
+
+func (t *Tuning) keysPerOctave() int {
+	if t.keyboard == nil || t.keyboard.size == 0 {
+		return len(t.degrees)
+	}
+	return t.keyboard.size
+}
+
+// closestDegree returns the degree within the first period that is closest to cents, the period itself counts as the next degree
+func (t *Tuning) closestDegree(cents float64) int {
+	closest := len(t.degrees)
+	for i, degree := range t.degrees {
+		if math.Abs(degree-cents) < math.Abs(t.degreeCents(closest)-cents) {
+			closest = i
+		}
+	}
+	return closest
+}
+
+// splitNote splits a note into its name and its octave, e.g. a_4 or 7_4
+func splitNote(note string) (string, int, error) {
+	name, octaveString, found := strings.Cut(note, "_")
+	if !found {
+		return "", 0, fmt.Errorf("invalid syntax for note %s, missing underscore", note)
+	}
+
+	octave, err := strconv.Atoi(octaveString)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to parse octave for note %s", note)
+	}
+
+	if octave < 0 || octave > 10 {
+		return "", 0, fmt.Errorf("octave must be at least 0 and at most 10 for note %s", note)
+	}
+
+	return name, octave, nil
+}
+
+// floorDiv returns the quotient rounded towards negative infinity and the non-negative remainder
+func floorDiv(a, b int) (int, int) {
+	q, r := a/b, a%b
+	if r < 0 {
+		q--
+		r += b
+	}
+	return q, r
+}
+
+func edoSteps(edo int) ([]float64, error) {
+	if edo < 1 || edo > edoMax {
+		return nil, fmt.Errorf("edo must be at least 1 and at most %d, got %d", edoMax, edo)
+	}
+
+	steps := make([]float64, edo)
+	for i := range steps {
+		steps[i] = float64(i+1) * 1200 / float64(edo)
+	}
+	return steps, nil
+}
+
+func parseSteps(pitches []string) ([]float64, error) {
+	steps := make([]float64, len(pitches))
+	for i, p := range pitches {
+		cents, err := parsePitch(p)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = cents
+	}
+	return steps, nil
+}
+
+// parsePitch parses a pitch in the notation of Scala files and returns it in cents
+// a pitch that contains a period is in cents, otherwise it is a ratio like 3/2 or a whole number like 2
+func parsePitch(pitch string) (float64, error) {
+	if strings.Contains(pitch, ".") {
+		cents, err := strconv.ParseFloat(pitch, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid pitch %q", pitch)
+		}
+		return cents, nil
+	}
+
+	num, den, found := strings.Cut(pitch, "/")
+	if !found {
+		den = "1"
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pitch %q", pitch)
+	}
+	d, err := strconv.ParseUint(den, 10, 64)
+	if err != nil || n == 0 || d == 0 {
+		return 0, fmt.Errorf("invalid pitch %q", pitch)
+	}
+	return 1200 * math.Log2(float64(n)/float64(d)), nil
+}
+
+// readScl reads the steps of a Scala scale file, see https://www.huygens-fokker.org/scala/scl_format.html
+func readScl(path string) ([]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	steps, err := parseScl(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scale file %s: %w", path, err)
+	}
+	return steps, nil
+}
+
+func parseScl(r io.Reader) ([]float64, error) {
+	lines, err := sclLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first line is a description, which may be empty, followed by the number of steps
+	var fields []string
+	for _, line := range lines[min(1, len(lines)):] {
+		if f := strings.Fields(line); len(f) > 0 {
+			fields = append(fields, f[0])
+		}
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("missing number of notes")
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid number of notes %q", fields[0])
+	}
+	if len(fields)-1 < n {
+		return nil, fmt.Errorf("expected %d notes, got %d", n, len(fields)-1)
+	}
+
+	return parseSteps(fields[1 : n+1])
+}
+
+// readKbm reads a Scala keyboard mapping file, see https://www.huygens-fokker.org/scala/help.htm#mappings
+func readKbm(path string) (*keyboard, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	k, err := parseKbm(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyboard mapping file %s: %w", path, err)
+	}
+	return k, nil
+}
+
+func parseKbm(r io.Reader) (*keyboard, error) {
+	lines, err := sclLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for _, line := range lines {
+		if f := strings.Fields(line); len(f) > 0 {
+			fields = append(fields, f[0])
+		}
+	}
+
+	// size, first key, last key, middle key, reference key, reference frequency and octave degree precede the mapping
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("expected at least 7 values, got %d", len(fields))
+	}
+
+	var ints [7]int
+	for i, field := range fields[:7] {
+		if i == 5 {
+			continue
+		}
+		ints[i], err = strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q in line %d", field, i+1)
+		}
+	}
+	freq, err := strconv.ParseFloat(fields[5], 64)
+	if err != nil || freq <= 0 {
+		return nil, fmt.Errorf("invalid reference frequency %q", fields[5])
+	}
+	if ints[0] < 0 {
+		return nil, fmt.Errorf("invalid map size %d", ints[0])
+	}
+
+	k := &keyboard{
+		size:      ints[0],
+		middle:    ints[3],
+		reference: ints[4],
+		freq:      freq,
+		octave:    ints[6],
+		mapping:   make([]int, ints[0]),
+	}
+
+	// keys without an entry aren't mapped
+	entries := fields[7:]
+	for i := range k.mapping {
+		k.mapping[i] = -1
+		if i >= len(entries) || entries[i] == "x" {
+			continue
+		}
+		degree, err := strconv.Atoi(entries[i])
+		if err != nil || degree < 0 {
+			return nil, fmt.Errorf("invalid mapping %q", entries[i])
+		}
+		k.mapping[i] = degree
+	}
+
+	return k, nil
+}
+
+// sclLines returns the lines of a Scala file without comments
+func sclLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,346 @@
+package module
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const (
+	justMajorScl = `! just-major.scl
+!
+Just intonation major scale
+ 7
+!
+ 9/8
+ 5/4
+ 4/3
+ 3/2
+ 5/3
+ 15/8
+ 2/1
+`
+	justMajorKbm = `! white keys of a piano
+12
+0
+127
+60
+69
+440.0
+7
+! mapping
+0
+x
+1
+x
+2
+3
+x
+4
+x
+5
+x
+6
+`
+)
+
+func writeTuningFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestTuning_initialize(t *testing.T) {
+	scl := writeTuningFile(t, "just-major.scl", justMajorScl)
+	kbm := writeTuningFile(t, "just-major.kbm", justMajorKbm)
+
+	tests := []struct {
+		name        string
+		tuning      *Tuning
+		wantDegrees []float64
+		wantPeriod  float64
+		wantBase    float64
+		wantErr     bool
+	}{
+		{
+			name:        "edo",
+			tuning:      &Tuning{EDO: 4},
+			wantDegrees: []float64{0, 300, 600, 900},
+			wantPeriod:  1200,
+			wantBase:    440 * math.Pow(2, -9.0/12),
+		},
+		{
+			name:        "ratios and cents",
+			tuning:      &Tuning{Steps: []string{"7/6", "350.", "3"}, Base: 100},
+			wantDegrees: []float64{0, 1200 * math.Log2(7.0/6), 350},
+			wantPeriod:  1200 * math.Log2(3),
+			wantBase:    100,
+		},
+		{
+			name:        "scala file",
+			tuning:      &Tuning{Scl: scl},
+			wantDegrees: []float64{0, 1200 * math.Log2(9.0/8), 1200 * math.Log2(5.0/4), 1200 * math.Log2(4.0/3), 1200 * math.Log2(3.0/2), 1200 * math.Log2(5.0/3), 1200 * math.Log2(15.0/8)},
+			wantPeriod:  1200,
+			wantBase:    440 * math.Pow(2, -9.0/12),
+		},
+		{
+			name:        "base from keyboard mapping",
+			tuning:      &Tuning{Scl: scl, Kbm: kbm},
+			wantDegrees: []float64{0, 1200 * math.Log2(9.0/8), 1200 * math.Log2(5.0/4), 1200 * math.Log2(4.0/3), 1200 * math.Log2(3.0/2), 1200 * math.Log2(5.0/3), 1200 * math.Log2(15.0/8)},
+			wantPeriod:  1200,
+			wantBase:    264,
+		},
+		{
+			name:    "no degrees",
+			tuning:  &Tuning{},
+			wantErr: true,
+		},
+		{
+			name:    "more than one source of degrees",
+			tuning:  &Tuning{EDO: 12, Steps: []string{"2"}},
+			wantErr: true,
+		},
+		{
+			name:    "edo too high",
+			tuning:  &Tuning{EDO: 1201},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ratio",
+			tuning:  &Tuning{Steps: []string{"3/0"}},
+			wantErr: true,
+		},
+		{
+			name:    "period below the first degree",
+			tuning:  &Tuning{Steps: []string{"1/2"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			tuning:  &Tuning{Scl: filepath.Join(t.TempDir(), "missing.scl")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tuning.initialize(440)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tuning.initialize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.wantDegrees, tt.tuning.degrees, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Tuning.initialize() degrees diff = %s", diff)
+			}
+			if math.Abs(tt.tuning.period-tt.wantPeriod) > 1e-9 {
+				t.Errorf("Tuning.initialize() period = %v, want %v", tt.tuning.period, tt.wantPeriod)
+			}
+			if math.Abs(tt.tuning.Base-tt.wantBase) > 1e-9 {
+				t.Errorf("Tuning.initialize() base = %v, want %v", tt.tuning.Base, tt.wantBase)
+			}
+		})
+	}
+}
+
+func TestTuning_noteToFreq(t *testing.T) {
+	scl := writeTuningFile(t, "just-major.scl", justMajorScl)
+	kbm := writeTuningFile(t, "just-major.kbm", justMajorKbm)
+
+	tests := []struct {
+		name      string
+		tuning    *Tuning
+		note      string
+		transpose float64
+		want      float64
+		wantErr   bool
+	}{
+		{
+			name:   "degree",
+			tuning: &Tuning{EDO: 19, Base: 200},
+			note:   "11_4",
+			want:   200 * math.Pow(2, 11.0/19),
+		},
+		{
+			name:   "degree beyond the period",
+			tuning: &Tuning{EDO: 19, Base: 200},
+			note:   "20_3",
+			want:   200 * math.Pow(2, 1.0/19),
+		},
+		{
+			name:   "negative degree",
+			tuning: &Tuning{EDO: 19, Base: 200},
+			note:   "-1_4",
+			want:   200 * math.Pow(2, -1.0/19),
+		},
+		{
+			name:   "note name snaps to the closest degree",
+			tuning: &Tuning{EDO: 19, Base: 200},
+			// c# is 100 cents above c, which is closer to the second degree than to the first
+			note: "c#_4",
+			want: 200 * math.Pow(2, 2.0/19),
+		},
+		{
+			name:   "note name in just intonation",
+			tuning: &Tuning{Scl: scl, Base: 264},
+			note:   "e_5",
+			want:   660,
+		},
+		{
+			name:   "note name closest to the period",
+			tuning: &Tuning{Scl: scl, Base: 264},
+			note:   "b#_3",
+			want:   264,
+		},
+		{
+			name:      "transpose",
+			tuning:    &Tuning{Scl: scl, Base: 264},
+			note:      "0_4",
+			transpose: 12,
+			want:      528,
+		},
+		{
+			name:   "keyboard mapping",
+			tuning: &Tuning{Scl: scl, Kbm: kbm},
+			note:   "9_4",
+			want:   440,
+		},
+		{
+			name:   "keyboard mapping in a lower octave",
+			tuning: &Tuning{Scl: scl, Kbm: kbm},
+			// the fourth key is mapped to the third degree
+			note: "4_3",
+			want: 165,
+		},
+		{
+			name:    "key that isn't mapped",
+			tuning:  &Tuning{Scl: scl, Kbm: kbm},
+			note:    "1_4",
+			wantErr: true,
+		},
+		{
+			name:    "unknown note",
+			tuning:  &Tuning{EDO: 19},
+			note:    "h_4",
+			wantErr: true,
+		},
+		{
+			name:    "octave too high",
+			tuning:  &Tuning{EDO: 19},
+			note:    "0_11",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tuning.initialize(440); err != nil {
+				t.Fatalf("Tuning.initialize() error = %v", err)
+			}
+			got, err := tt.tuning.noteToFreq(tt.note, tt.transpose)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tuning.noteToFreq() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Tuning.noteToFreq() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseScl(t *testing.T) {
+	tests := []struct {
+		name    string
+		scl     string
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name: "empty description and trailing text",
+			scl:  "!\n\n 2\n 1200.0 octave\n 2/1 period\n",
+			want: []float64{1200, 1200},
+		},
+		{
+			name:    "missing notes",
+			scl:     "description\n3\n3/2\n2\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid number of notes",
+			scl:     "description\nthree\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScl(strings.NewReader(tt.scl))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseScl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseScl() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func Test_parseKbm(t *testing.T) {
+	tests := []struct {
+		name    string
+		kbm     string
+		want    *keyboard
+		wantErr bool
+	}{
+		{
+			name: "white keys",
+			kbm:  justMajorKbm,
+			want: &keyboard{
+				size:      12,
+				middle:    60,
+				reference: 69,
+				freq:      440,
+				octave:    7,
+				mapping:   []int{0, -1, 1, -1, 2, 3, -1, 4, -1, 5, -1, 6},
+			},
+		},
+		{
+			name: "missing entries aren't mapped",
+			kbm:  "3\n0\n127\n60\n60\n261.63\n0\n0\n",
+			want: &keyboard{
+				size:      3,
+				middle:    60,
+				reference: 60,
+				freq:      261.63,
+				mapping:   []int{0, -1, -1},
+			},
+		},
+		{
+			name:    "too few values",
+			kbm:     "0\n0\n127\n60\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid reference frequency",
+			kbm:     "0\n0\n127\n60\n69\n-440\n0\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKbm(strings.NewReader(tt.kbm))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKbm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(keyboard{})); diff != "" {
+				t.Errorf("parseKbm() diff = %s", diff)
+			}
+		})
+	}
+}
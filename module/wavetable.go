@@ -67,16 +67,24 @@ func (w *Wavetable) Update(new *Wavetable) {
 	}
 }
 
+func (w *Wavetable) Inputs() map[string]string {
+	return inputs(map[string]string{
+		"cv":  w.CV,
+		"mod": w.Mod,
+	})
+}
+
+func (w *Wavetable) RenameInputs(rename func(string) string) {
+	w.CV = rename(w.CV)
+	w.Mod = rename(w.Mod)
+}
+
 func (w *Wavetable) Step(modules *ModuleMap) {
 	if len(w.Signal) < 1 {
 		return
 	}
 	val := w.Signal[int(math.Floor(w.idx))%len(w.Signal)]
-	w.current = Output{
-		Mono:  val,
-		Left:  val / 2,
-		Right: val / 2,
-	}
+	w.current = monoOutput(val)
 
 	freq := w.Freq
 	if w.CV != "" {
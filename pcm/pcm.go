@@ -0,0 +1,81 @@
+package pcm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/iljarotar/synth/calc"
+)
+
+type Format string
+
+const (
+	FormatS16LE Format = "s16le"
+	FormatS24LE Format = "s24le"
+	FormatF32LE Format = "f32le"
+
+	Channels = 2
+)
+
+var sampleRange = calc.Range{
+	Min: -1,
+	Max: 1,
+}
+
+func ParseFormat(format string) (Format, error) {
+	f := Format(format)
+	switch f {
+	case FormatS16LE, FormatS24LE, FormatF32LE:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %s - must be one of %s, %s, %s", format, FormatS16LE, FormatS24LE, FormatF32LE)
+	}
+}
+
+// BytesPerSample returns the size of a single sample of one channel
+func (f Format) BytesPerSample() int {
+	switch f {
+	case FormatS16LE:
+		return 2
+	case FormatS24LE:
+		return 3
+	case FormatF32LE:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// BytesPerFrame returns the size of one sample for all channels
+func (f Format) BytesPerFrame() int {
+	return f.BytesPerSample() * Channels
+}
+
+func (f Format) IsFloat() bool {
+	return f == FormatF32LE
+}
+
+// AppendFrame encodes a stereo frame and appends it to buf
+// integer formats are clipped to the range [-1, 1]
+func (f Format) AppendFrame(buf []byte, frame [2]float64) []byte {
+	for _, s := range frame {
+		buf = f.appendSample(buf, s)
+	}
+	return buf
+}
+
+func (f Format) appendSample(buf []byte, s float64) []byte {
+	switch f {
+	case FormatS16LE:
+		v := int16(math.Round(calc.Limit(s, sampleRange) * math.MaxInt16))
+		return append(buf, byte(v), byte(v>>8))
+	case FormatS24LE:
+		v := int32(math.Round(calc.Limit(s, sampleRange) * (1<<23 - 1)))
+		return append(buf, byte(v), byte(v>>8), byte(v>>16))
+	case FormatF32LE:
+		v := math.Float32bits(float32(s))
+		return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	default:
+		return buf
+	}
+}
@@ -0,0 +1,115 @@
+package pcm
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat_AppendFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		frame  [2]float64
+		want   []byte
+	}{
+		{
+			name:   "s16le",
+			format: FormatS16LE,
+			frame:  [2]float64{1, -1},
+			want:   []byte{0xff, 0x7f, 0x01, 0x80},
+		},
+		{
+			name:   "s16le clipped",
+			format: FormatS16LE,
+			frame:  [2]float64{2, -3},
+			want:   []byte{0xff, 0x7f, 0x01, 0x80},
+		},
+		{
+			name:   "s24le",
+			format: FormatS24LE,
+			frame:  [2]float64{0, 1},
+			want:   []byte{0, 0, 0, 0xff, 0xff, 0x7f},
+		},
+		{
+			name:   "f32le",
+			format: FormatF32LE,
+			frame:  [2]float64{0.5, -2},
+			want: func() []byte {
+				l := math.Float32bits(0.5)
+				r := math.Float32bits(-2)
+				return []byte{byte(l), byte(l >> 8), byte(l >> 16), byte(l >> 24), byte(r), byte(r >> 8), byte(r >> 16), byte(r >> 24)}
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.format.AppendFrame(nil, tt.frame)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Format.AppendFrame() diff = %s", diff)
+			}
+			if len(got) != tt.format.BytesPerFrame() {
+				t.Errorf("Format.AppendFrame() length = %v, want %v", len(got), tt.format.BytesPerFrame())
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		want    Format
+		wantErr bool
+	}{
+		{
+			name:   "valid format",
+			format: "s24le",
+			want:   FormatS24LE,
+		},
+		{
+			name:    "unknown format",
+			format:  "u8",
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		stop   = make(chan struct{})
+		frames int
+	)
+
+	readSample := func() [2]float64 {
+		frames++
+		if frames == 2*streamBufferFrames {
+			close(stop)
+		}
+		return [2]float64{0, 0}
+	}
+
+	err := Stream(&buf, FormatS16LE, 44100, readSample, false, stop)
+	if err != nil {
+		t.Errorf("Stream() error = %v", err)
+	}
+	if want := 2 * streamBufferFrames * FormatS16LE.BytesPerFrame(); buf.Len() != want {
+		t.Errorf("Stream() wrote %v bytes, want %v", buf.Len(), want)
+	}
+}
@@ -0,0 +1,42 @@
+package pcm
+
+import (
+	"io"
+	"time"
+)
+
+const streamBufferFrames = 512
+
+// Stream writes interleaved frames to w until stop is closed or writing fails
+// if realtime is set, the stream is paced to the sample rate, otherwise frames are written as fast as w accepts them
+func Stream(w io.Writer, format Format, sampleRate int, readSample func() [2]float64, realtime bool, stop <-chan struct{}) error {
+	var (
+		buf    = make([]byte, 0, streamBufferFrames*format.BytesPerFrame())
+		start  = time.Now()
+		frames int
+	)
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		buf = buf[:0]
+		for range streamBufferFrames {
+			buf = format.AppendFrame(buf, readSample())
+		}
+
+		_, err := w.Write(buf)
+		if err != nil {
+			return err
+		}
+		frames += streamBufferFrames
+
+		if realtime {
+			elapsed := time.Duration(float64(frames) / float64(sampleRate) * float64(time.Second))
+			time.Sleep(time.Until(start.Add(elapsed)))
+		}
+	}
+}
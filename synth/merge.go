@@ -0,0 +1,89 @@
+package synth
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MergeError lists the module names that are defined in both merged patches
+type MergeError struct {
+	Names []string
+}
+
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("duplicate module names: %s", strings.Join(e.Names, ", "))
+}
+
+// Names returns the sorted names of all modules of the patch
+func (s *Synth) Names() []string {
+	var names []string
+	for _, sec := range s.sections() {
+		names = append(names, sortedKeys(sec.modules)...)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Merge adds the modules, templates and instances of other to s, the output, volume and tempo of other are ignored
+// since names must be unique across all module types, names that s already uses are rejected with a MergeError
+func (s *Synth) Merge(other *Synth) error {
+	if other == nil {
+		return nil
+	}
+
+	for _, name := range sortedKeys(other.Templates) {
+		if _, ok := s.Templates[name]; ok {
+			return fmt.Errorf("template %s is already defined", name)
+		}
+	}
+	for _, name := range sortedKeys(other.Instances) {
+		if _, ok := s.Instances[name]; ok {
+			return fmt.Errorf("instance %s is already defined", name)
+		}
+	}
+
+	used := s.Names()
+	var conflicts []string
+	for _, name := range other.Names() {
+		if _, ok := slices.BinarySearch(used, name); ok {
+			conflicts = append(conflicts, name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &MergeError{Names: conflicts}
+	}
+
+	s.Choruses = mergeMap(s.Choruses, other.Choruses)
+	s.Delays = mergeMap(s.Delays, other.Delays)
+	s.Dividers = mergeMap(s.Dividers, other.Dividers)
+	s.Envelopes = mergeMap(s.Envelopes, other.Envelopes)
+	s.Euclids = mergeMap(s.Euclids, other.Euclids)
+	s.Filters = mergeMap(s.Filters, other.Filters)
+	s.Gates = mergeMap(s.Gates, other.Gates)
+	s.Ladders = mergeMap(s.Ladders, other.Ladders)
+	s.Mixers = mergeMap(s.Mixers, other.Mixers)
+	s.Noises = mergeMap(s.Noises, other.Noises)
+	s.Oscillators = mergeMap(s.Oscillators, other.Oscillators)
+	s.Pans = mergeMap(s.Pans, other.Pans)
+	s.Players = mergeMap(s.Players, other.Players)
+	s.Quantizers = mergeMap(s.Quantizers, other.Quantizers)
+	s.Reverbs = mergeMap(s.Reverbs, other.Reverbs)
+	s.Samplers = mergeMap(s.Samplers, other.Samplers)
+	s.Sequencers = mergeMap(s.Sequencers, other.Sequencers)
+	s.Wavetables = mergeMap(s.Wavetables, other.Wavetables)
+
+	s.Templates = mergeMap(s.Templates, other.Templates)
+	s.Instances = mergeMap(s.Instances, other.Instances)
+
+	return nil
+}
+
+func mergeMap[M ~map[string]T, T any](dst, src M) M {
+	if dst == nil && len(src) > 0 {
+		dst = M{}
+	}
+	maps.Copy(dst, src)
+	return dst
+}
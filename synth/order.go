@@ -0,0 +1,194 @@
+package synth
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iljarotar/synth/module"
+)
+
+type visitState int
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+// sortModules determines the order in which modules are stepped, so that each module reads the current output of its inputs
+func (s *Synth) sortModules() {
+	order, feedback := sortModules(s.modules)
+	stepFuncs := s.stepFuncs()
+
+	var steps []func()
+	for _, name := range order {
+		if step, ok := stepFuncs[name]; ok {
+			steps = append(steps, step)
+		}
+	}
+
+	s.steps.Store(&steps)
+	s.feedback = feedback
+}
+
+// sortModules sorts modules topologically by their inputs
+// inputs that close a feedback cycle are skipped, so that they are read with a delay of one sample
+func sortModules(modules *module.ModuleMap) (order, feedback []string) {
+	if modules == nil {
+		return nil, nil
+	}
+
+	names := modules.Keys()
+	slices.Sort(names)
+	state := make(map[string]visitState, len(names))
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+
+		mod, _ := modules.Get(name)
+		if mod != nil {
+			inputs := mod.Inputs()
+			for _, field := range sortedKeys(inputs) {
+				// a port is stepped with its module
+				input, ok := module.Resolve(modules, inputs[field])
+				if !ok {
+					continue
+				}
+
+				switch state[input] {
+				case unvisited:
+					visit(input)
+				case visiting:
+					feedback = append(feedback, fmt.Sprintf("%s.%s reads %s", name, field, input))
+				}
+			}
+		}
+
+		state[name] = visited
+		order = append(order, name)
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return order, feedback
+}
+
+func (s *Synth) stepFuncs() map[string]func() {
+	steps := make(map[string]func())
+
+	for name, c := range s.Choruses {
+		if c == nil {
+			continue
+		}
+		steps[name] = func() { c.Step(s.modules) }
+	}
+	for name, d := range s.Delays {
+		if d == nil {
+			continue
+		}
+		steps[name] = func() { d.Step(s.modules) }
+	}
+	for name, div := range s.Dividers {
+		if div == nil {
+			continue
+		}
+		steps[name] = func() { div.Step(s.modules) }
+	}
+	for name, e := range s.Envelopes {
+		if e == nil {
+			continue
+		}
+		steps[name] = func() { e.Step(s.Time, s.modules) }
+	}
+	for name, euc := range s.Euclids {
+		if euc == nil {
+			continue
+		}
+		steps[name] = func() { euc.Step(s.modules) }
+	}
+	for name, f := range s.Filters {
+		if f == nil {
+			continue
+		}
+		steps[name] = func() { f.Step(s.modules) }
+	}
+	for name, g := range s.Gates {
+		if g == nil {
+			continue
+		}
+		steps[name] = func() { g.Step(s.modules) }
+	}
+	for name, l := range s.Ladders {
+		if l == nil {
+			continue
+		}
+		steps[name] = func() { l.Step(s.modules) }
+	}
+	for name, m := range s.Mixers {
+		if m == nil {
+			continue
+		}
+		steps[name] = func() { m.Step(s.modules) }
+	}
+	for name, n := range s.Noises {
+		if n == nil {
+			continue
+		}
+		steps[name] = n.Step
+	}
+	for name, osc := range s.Oscillators {
+		if osc == nil {
+			continue
+		}
+		steps[name] = func() { osc.Step(s.modules) }
+	}
+	for name, p := range s.Pans {
+		if p == nil {
+			continue
+		}
+		steps[name] = func() { p.Step(s.modules) }
+	}
+	for name, p := range s.Players {
+		if p == nil {
+			continue
+		}
+		steps[name] = func() { p.Step(s.modules) }
+	}
+	for name, q := range s.Quantizers {
+		if q == nil {
+			continue
+		}
+		steps[name] = func() { q.Step(s.modules) }
+	}
+	for name, r := range s.Reverbs {
+		if r == nil {
+			continue
+		}
+		steps[name] = func() { r.Step(s.modules) }
+	}
+	for name, smplr := range s.Samplers {
+		if smplr == nil {
+			continue
+		}
+		steps[name] = func() { smplr.Step(s.modules) }
+	}
+	for name, seq := range s.Sequencers {
+		if seq == nil {
+			continue
+		}
+		steps[name] = func() { seq.Step(s.modules) }
+	}
+	for name, w := range s.Wavetables {
+		if w == nil {
+			continue
+		}
+		steps[name] = func() { w.Step(s.modules) }
+	}
+
+	return steps
+}
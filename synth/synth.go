@@ -1,11 +1,11 @@
 package synth
 
 import (
-	"slices"
+	"path/filepath"
+	"sync/atomic"
 
 	"github.com/iljarotar/synth/calc"
 	"github.com/iljarotar/synth/module"
-	"github.com/samber/lo"
 )
 
 const (
@@ -19,15 +19,30 @@ type Output struct {
 type Synth struct {
 	Out    string  `yaml:"out"`
 	Volume float64 `yaml:"vol"`
-
+	// BPM is the tempo of the clock that synced modules follow, Fade is the transition length of tempo changes
+	BPM  float64 `yaml:"bpm"`
+	Fade float64 `yaml:"fade"`
+	// Include lists patch files whose modules are merged into this patch
+	Include []string `yaml:"include"`
+	// Templates are expanded into modules once for each of the Instances
+	Templates map[string]*Template `yaml:"templates"`
+	Instances map[string]*Instance `yaml:"instances"`
+
+	Choruses    module.ChorusMap     `yaml:"choruses"`
 	Delays      module.DelayMap      `yaml:"delays"`
+	Dividers    module.DividerMap    `yaml:"dividers"`
 	Envelopes   module.EnvelopeMap   `yaml:"envelopes"`
+	Euclids     module.EuclidMap     `yaml:"euclids"`
 	Filters     module.FilterMap     `yaml:"filters"`
 	Gates       module.GateMap       `yaml:"gates"`
+	Ladders     module.LadderMap     `yaml:"ladders"`
 	Mixers      module.MixerMap      `yaml:"mixers"`
 	Noises      module.NoiseMap      `yaml:"noises"`
 	Oscillators module.OscillatorMap `yaml:"oscillators"`
 	Pans        module.PanMap        `yaml:"pans"`
+	Players     module.PlayerMap     `yaml:"players"`
+	Quantizers  module.QuantizerMap  `yaml:"quantizers"`
+	Reverbs     module.ReverbMap     `yaml:"reverbs"`
 	Samplers    module.SamplerMap    `yaml:"samplers"`
 	Sequencers  module.SequencerMap  `yaml:"sequencers"`
 	Wavetables  module.WavetableMap  `yaml:"wavetables"`
@@ -38,21 +53,26 @@ type Synth struct {
 	volumeStep        float64
 	notifyFadeoutChan chan<- bool
 	modules           *module.ModuleMap
+	clock             *module.Clock
 
-	delays      []*module.Delay
-	envelopes   []*module.Envelope
-	filters     []*module.Filter
-	gates       []*module.Gate
-	mixers      []*module.Mixer
-	noises      []*module.Noise
-	oscillators []*module.Oscillator
-	pans        []*module.Pan
-	samplers    []*module.Sampler
-	sequencers  []*module.Sequencer
-	wavetables  []*module.Wavetable
+	// steps holds the step functions of all modules in the order they are evaluated
+	// it is replaced on updates while the audio loop is reading it, so it is swapped atomically
+	steps    atomic.Pointer[[]func()]
+	feedback []string
 }
 
+// Initialize expands all instances, validates the patch and prepares all modules for playback
 func (s *Synth) Initialize(sampleRate float64) error {
+	if err := s.expandInstances(); err != nil {
+		return err
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return s.initialize(sampleRate)
+}
+
+func (s *Synth) initialize(sampleRate float64) error {
 	s.sampleRate = sampleRate
 	s.Volume = calc.Limit(s.Volume, calc.Range{
 		Min: 0,
@@ -62,8 +82,13 @@ func (s *Synth) Initialize(sampleRate float64) error {
 	s.Volume = 0
 	s.initializeEmptyMaps()
 	s.makeModulesMap()
-	s.flattenModules()
 
+	if err := s.Choruses.Initialize(sampleRate); err != nil {
+		return err
+	}
+	if err := s.Envelopes.Initialize(sampleRate); err != nil {
+		return err
+	}
 	if err := s.Filters.Initialize(sampleRate); err != nil {
 		return err
 	}
@@ -76,13 +101,26 @@ func (s *Synth) Initialize(sampleRate float64) error {
 	if err := s.Sequencers.Initialize(); err != nil {
 		return err
 	}
+	if err := s.Players.Initialize(sampleRate); err != nil {
+		return err
+	}
+	if err := s.Quantizers.Initialize(); err != nil {
+		return err
+	}
 
 	s.Delays.Initialize(sampleRate)
-	s.Envelopes.Initialize(sampleRate)
+	s.Dividers.Initialize(sampleRate)
+	s.Euclids.Initialize(sampleRate)
 	s.Gates.Initialize(sampleRate)
+	s.Ladders.Initialize(sampleRate)
 	s.Pans.Initialize(sampleRate)
+	s.Reverbs.Initialize(sampleRate)
 	s.Wavetables.Initialize(sampleRate)
 
+	s.clock = module.NewClock(s.BPM, s.Fade, sampleRate)
+	s.setClock()
+	s.sortModules()
+
 	return nil
 }
 
@@ -94,7 +132,16 @@ func (s *Synth) Update(from *Synth) error {
 	s.deleteOldModules(from)
 	s.addNewModules(from)
 	s.updateModules(from)
+	// the sounds of files that no player plays anymore are only removed once all players are up to date
+	module.PruneSounds(s.Players)
 	s.Out = from.Out
+	s.BPM = from.BPM
+	s.Fade = from.Fade
+	if s.clock != nil {
+		s.clock.Update(s.BPM, s.Fade)
+	}
+	s.setClock()
+	s.sortModules()
 
 	return nil
 }
@@ -108,10 +155,10 @@ func (s *Synth) GetOutput() Output {
 	s.adjustVolume()
 	out := Output{Time: s.Time}
 
-	if mod, _ := s.modules.Get(s.Out); mod != nil {
-		out.Left = mod.Current().Left * s.Volume
-		out.Right = mod.Current().Right * s.Volume
-		out.Mono = mod.Current().Mono * s.Volume
+	if current, ok := module.Lookup(s.modules, s.Out); ok {
+		out.Left = current.Left * s.Volume
+		out.Right = current.Right * s.Volume
+		out.Mono = current.Mono * s.Volume
 	}
 
 	return out
@@ -151,74 +198,21 @@ func (s *Synth) adjustVolume() {
 	}
 }
 
+// Feedback describes all inputs that are read with a delay of one sample, because they form a feedback cycle
+func (s *Synth) Feedback() []string {
+	return s.feedback
+}
+
 func (s *Synth) step() {
-	for _, d := range s.delays {
-		if d == nil {
-			continue
-		}
-		d.Step(s.modules)
-	}
-	for _, e := range s.envelopes {
-		if e == nil {
-			continue
-		}
-		e.Step(s.Time, s.modules)
-	}
-	for _, f := range s.filters {
-		if f == nil {
-			continue
+	if steps := s.steps.Load(); steps != nil {
+		for _, step := range *steps {
+			step()
 		}
-		f.Step(s.modules)
-	}
-	for _, g := range s.gates {
-		if g == nil {
-			continue
-		}
-		g.Step(s.modules)
-	}
-	for _, m := range s.mixers {
-		if m == nil {
-			continue
-		}
-		m.Step(s.modules)
-	}
-	for _, n := range s.noises {
-		if n == nil {
-			continue
-		}
-		n.Step()
-	}
-	for _, osc := range s.oscillators {
-		if osc == nil {
-			continue
-		}
-		osc.Step(s.modules)
-	}
-	for _, p := range s.pans {
-		if p == nil {
-			continue
-		}
-		p.Step(s.modules)
-	}
-	for _, smplr := range s.samplers {
-		if smplr == nil {
-			continue
-		}
-		smplr.Step(s.modules)
-	}
-	for _, seq := range s.sequencers {
-		if seq == nil {
-			continue
-		}
-		seq.Step(s.modules)
-	}
-	for _, w := range s.wavetables {
-		if w == nil {
-			continue
-		}
-		w.Step(s.modules)
 	}
 
+	if s.clock != nil {
+		s.clock.Step()
+	}
 	s.Time += 1 / s.sampleRate
 }
 
@@ -234,18 +228,36 @@ func (s *Synth) makeModulesMap() {
 		s.modules = module.NewModuleMap(map[string]module.IModule{})
 	}
 
+	for name, c := range s.Choruses {
+		if c == nil {
+			continue
+		}
+		s.modules.Set(name, c)
+	}
 	for name, d := range s.Delays {
 		if d == nil {
 			continue
 		}
 		s.modules.Set(name, d)
 	}
+	for name, div := range s.Dividers {
+		if div == nil {
+			continue
+		}
+		s.modules.Set(name, div)
+	}
 	for name, e := range s.Envelopes {
 		if e == nil {
 			continue
 		}
 		s.modules.Set(name, e)
 	}
+	for name, euc := range s.Euclids {
+		if euc == nil {
+			continue
+		}
+		s.modules.Set(name, euc)
+	}
 	for name, f := range s.Filters {
 		if f == nil {
 			continue
@@ -258,6 +270,12 @@ func (s *Synth) makeModulesMap() {
 		}
 		s.modules.Set(name, g)
 	}
+	for name, l := range s.Ladders {
+		if l == nil {
+			continue
+		}
+		s.modules.Set(name, l)
+	}
 	for name, m := range s.Mixers {
 		if m == nil {
 			continue
@@ -282,6 +300,24 @@ func (s *Synth) makeModulesMap() {
 		}
 		s.modules.Set(name, p)
 	}
+	for name, p := range s.Players {
+		if p == nil {
+			continue
+		}
+		s.modules.Set(name, p)
+	}
+	for name, q := range s.Quantizers {
+		if q == nil {
+			continue
+		}
+		s.modules.Set(name, q)
+	}
+	for name, r := range s.Reverbs {
+		if r == nil {
+			continue
+		}
+		s.modules.Set(name, r)
+	}
 	for name, smplr := range s.Samplers {
 		if smplr == nil {
 			continue
@@ -302,122 +338,117 @@ func (s *Synth) makeModulesMap() {
 	}
 }
 
-func (s *Synth) flattenModules() {
-	s.delays = lo.Values(s.Delays)
-	s.envelopes = lo.Values(s.Envelopes)
-	s.filters = lo.Values(s.Filters)
-	s.gates = lo.Values(s.Gates)
-	s.mixers = lo.Values(s.Mixers)
-	s.noises = lo.Values(s.Noises)
-	s.oscillators = lo.Values(s.Oscillators)
-	s.pans = lo.Values(s.Pans)
-	s.samplers = lo.Values(s.Samplers)
-	s.sequencers = lo.Values(s.Sequencers)
-	s.wavetables = lo.Values(s.Wavetables)
-}
-
 func (s *Synth) deleteOldModules(new *Synth) {
 	if s.modules == nil {
 		s.modules = module.NewModuleMap(map[string]module.IModule{})
 	}
 
-	for name, delay := range s.Delays {
+	for name := range s.Choruses {
+		if _, ok := new.Choruses[name]; !ok {
+			delete(s.Choruses, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Delays {
 		if _, ok := new.Delays[name]; !ok {
 			delete(s.Delays, name)
 			s.modules.Delete(name)
-			s.delays = slices.DeleteFunc(s.delays, func(d *module.Delay) bool {
-				return delay == d
-			})
 		}
 	}
-	for name, env := range s.Envelopes {
+	for name := range s.Dividers {
+		if _, ok := new.Dividers[name]; !ok {
+			delete(s.Dividers, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Envelopes {
 		if _, ok := new.Envelopes[name]; !ok {
 			delete(s.Envelopes, name)
 			s.modules.Delete(name)
-			s.envelopes = slices.DeleteFunc(s.envelopes, func(e *module.Envelope) bool {
-				return env == e
-			})
 		}
 	}
-	for name, filter := range s.Filters {
+	for name := range s.Euclids {
+		if _, ok := new.Euclids[name]; !ok {
+			delete(s.Euclids, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Filters {
 		if _, ok := new.Filters[name]; !ok {
 			delete(s.Filters, name)
 			s.modules.Delete(name)
-			s.filters = slices.DeleteFunc(s.filters, func(f *module.Filter) bool {
-				return filter == f
-			})
 		}
 	}
-	for name, gate := range s.Gates {
+	for name := range s.Gates {
 		if _, ok := new.Gates[name]; !ok {
 			delete(s.Gates, name)
 			s.modules.Delete(name)
-			s.gates = slices.DeleteFunc(s.gates, func(g *module.Gate) bool {
-				return gate == g
-			})
 		}
 	}
-	for name, mixer := range s.Mixers {
+	for name := range s.Ladders {
+		if _, ok := new.Ladders[name]; !ok {
+			delete(s.Ladders, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Mixers {
 		if _, ok := new.Mixers[name]; !ok {
 			delete(s.Mixers, name)
 			s.modules.Delete(name)
-			s.mixers = slices.DeleteFunc(s.mixers, func(m *module.Mixer) bool {
-				return mixer == m
-			})
 		}
 	}
-	for name, noise := range s.Noises {
+	for name := range s.Noises {
 		if _, ok := new.Noises[name]; !ok {
 			delete(s.Noises, name)
 			s.modules.Delete(name)
-			s.noises = slices.DeleteFunc(s.noises, func(n *module.Noise) bool {
-				return noise == n
-			})
 		}
 	}
-	for name, osc := range s.Oscillators {
+	for name := range s.Oscillators {
 		if _, ok := new.Oscillators[name]; !ok {
 			delete(s.Oscillators, name)
 			s.modules.Delete(name)
-			s.oscillators = slices.DeleteFunc(s.oscillators, func(o *module.Oscillator) bool {
-				return osc == o
-			})
 		}
 	}
-	for name, pan := range s.Pans {
+	for name := range s.Pans {
 		if _, ok := new.Pans[name]; !ok {
 			delete(s.Pans, name)
 			s.modules.Delete(name)
-			s.pans = slices.DeleteFunc(s.pans, func(p *module.Pan) bool {
-				return pan == p
-			})
 		}
 	}
-	for name, sampler := range s.Samplers {
+	for name := range s.Players {
+		if _, ok := new.Players[name]; !ok {
+			delete(s.Players, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Quantizers {
+		if _, ok := new.Quantizers[name]; !ok {
+			delete(s.Quantizers, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Reverbs {
+		if _, ok := new.Reverbs[name]; !ok {
+			delete(s.Reverbs, name)
+			s.modules.Delete(name)
+		}
+	}
+	for name := range s.Samplers {
 		if _, ok := new.Samplers[name]; !ok {
 			delete(s.Samplers, name)
 			s.modules.Delete(name)
-			s.samplers = slices.DeleteFunc(s.samplers, func(smplr *module.Sampler) bool {
-				return sampler == smplr
-			})
 		}
 	}
-	for name, seq := range s.Sequencers {
+	for name := range s.Sequencers {
 		if _, ok := new.Sequencers[name]; !ok {
 			delete(s.Sequencers, name)
 			s.modules.Delete(name)
-			s.sequencers = slices.DeleteFunc(s.sequencers, func(sq *module.Sequencer) bool {
-				return seq == sq
-			})
 		}
 	}
-	for name, wt := range s.Wavetables {
+	for name := range s.Wavetables {
 		if _, ok := new.Wavetables[name]; !ok {
 			delete(s.Wavetables, name)
 			s.modules.Delete(name)
-			s.wavetables = slices.DeleteFunc(s.wavetables, func(w *module.Wavetable) bool {
-				return wt == w
-			})
 		}
 	}
 }
@@ -427,96 +458,142 @@ func (s *Synth) addNewModules(new *Synth) {
 		s.modules = module.NewModuleMap(map[string]module.IModule{})
 	}
 
+	for name, c := range new.Choruses {
+		if _, ok := s.Choruses[name]; !ok {
+			s.Choruses[name] = c
+			s.modules.Set(name, c)
+		}
+	}
 	for name, d := range new.Delays {
 		if _, ok := s.Delays[name]; !ok {
 			s.Delays[name] = d
-			s.delays = append(s.delays, d)
 			s.modules.Set(name, d)
 		}
 	}
+	for name, div := range new.Dividers {
+		if _, ok := s.Dividers[name]; !ok {
+			s.Dividers[name] = div
+			s.modules.Set(name, div)
+		}
+	}
 	for name, e := range new.Envelopes {
 		if _, ok := s.Envelopes[name]; !ok {
 			s.Envelopes[name] = e
-			s.envelopes = append(s.envelopes, e)
 			s.modules.Set(name, e)
 		}
 	}
+	for name, euc := range new.Euclids {
+		if _, ok := s.Euclids[name]; !ok {
+			s.Euclids[name] = euc
+			s.modules.Set(name, euc)
+		}
+	}
 	for name, f := range new.Filters {
 		if _, ok := s.Filters[name]; !ok {
 			s.Filters[name] = f
-			s.filters = append(s.filters, f)
 			s.modules.Set(name, f)
 		}
 	}
 	for name, g := range new.Gates {
 		if _, ok := s.Gates[name]; !ok {
 			s.Gates[name] = g
-			s.gates = append(s.gates, g)
 			s.modules.Set(name, g)
 		}
 	}
+	for name, l := range new.Ladders {
+		if _, ok := s.Ladders[name]; !ok {
+			s.Ladders[name] = l
+			s.modules.Set(name, l)
+		}
+	}
 	for name, m := range new.Mixers {
 		if _, ok := s.Mixers[name]; !ok {
 			s.Mixers[name] = m
-			s.mixers = append(s.mixers, m)
 			s.modules.Set(name, m)
 		}
 	}
 	for name, n := range new.Noises {
 		if _, ok := s.Noises[name]; !ok {
 			s.Noises[name] = n
-			s.noises = append(s.noises, n)
 			s.modules.Set(name, n)
 		}
 	}
 	for name, osc := range new.Oscillators {
 		if _, ok := s.Oscillators[name]; !ok {
 			s.Oscillators[name] = osc
-			s.oscillators = append(s.oscillators, osc)
 			s.modules.Set(name, osc)
 		}
 	}
 	for name, p := range new.Pans {
 		if _, ok := s.Pans[name]; !ok {
 			s.Pans[name] = p
-			s.pans = append(s.pans, p)
 			s.modules.Set(name, p)
 		}
 	}
+	for name, p := range new.Players {
+		if _, ok := s.Players[name]; !ok {
+			s.Players[name] = p
+			s.modules.Set(name, p)
+		}
+	}
+	for name, q := range new.Quantizers {
+		if _, ok := s.Quantizers[name]; !ok {
+			s.Quantizers[name] = q
+			s.modules.Set(name, q)
+		}
+	}
+	for name, r := range new.Reverbs {
+		if _, ok := s.Reverbs[name]; !ok {
+			s.Reverbs[name] = r
+			s.modules.Set(name, r)
+		}
+	}
 	for name, smplr := range new.Samplers {
 		if _, ok := s.Samplers[name]; !ok {
 			s.Samplers[name] = smplr
-			s.samplers = append(s.samplers, smplr)
 			s.modules.Set(name, smplr)
 		}
 	}
 	for name, seq := range new.Sequencers {
 		if _, ok := s.Sequencers[name]; !ok {
 			s.Sequencers[name] = seq
-			s.sequencers = append(s.sequencers, seq)
 			s.modules.Set(name, seq)
 		}
 	}
 	for name, w := range new.Wavetables {
 		if _, ok := s.Wavetables[name]; !ok {
 			s.Wavetables[name] = w
-			s.wavetables = append(s.wavetables, w)
 			s.modules.Set(name, w)
 		}
 	}
 }
 
 func (s *Synth) updateModules(new *Synth) {
+	for name, chorus := range s.Choruses {
+		if newChorus, ok := new.Choruses[name]; ok {
+			chorus.Update(newChorus)
+		}
+	}
 	for name, delay := range s.Delays {
 		if newDelay, ok := new.Delays[name]; ok {
 			delay.Update(newDelay)
 		}
 	}
+	for name, divider := range s.Dividers {
+		if newDivider, ok := new.Dividers[name]; ok {
+			divider.Update(newDivider)
+		}
+	}
 	for name, env := range s.Envelopes {
 		if newEnv, ok := new.Envelopes[name]; ok {
 			env.Update(newEnv)
 		}
 	}
+	for name, euclid := range s.Euclids {
+		if newEuclid, ok := new.Euclids[name]; ok {
+			euclid.Update(newEuclid)
+		}
+	}
 	for name, filter := range s.Filters {
 		if newFilter, ok := new.Filters[name]; ok {
 			filter.Update(newFilter)
@@ -527,6 +604,11 @@ func (s *Synth) updateModules(new *Synth) {
 			gate.Update(newGate)
 		}
 	}
+	for name, ladder := range s.Ladders {
+		if newLadder, ok := new.Ladders[name]; ok {
+			ladder.Update(newLadder)
+		}
+	}
 	for name, mixer := range s.Mixers {
 		if newMixer, ok := new.Mixers[name]; ok {
 			mixer.Update(newMixer)
@@ -542,6 +624,21 @@ func (s *Synth) updateModules(new *Synth) {
 			pan.Update(newPan)
 		}
 	}
+	for name, player := range s.Players {
+		if newPlayer, ok := new.Players[name]; ok {
+			player.Update(newPlayer)
+		}
+	}
+	for name, quantizer := range s.Quantizers {
+		if newQuantizer, ok := new.Quantizers[name]; ok {
+			quantizer.Update(newQuantizer)
+		}
+	}
+	for name, reverb := range s.Reverbs {
+		if newReverb, ok := new.Reverbs[name]; ok {
+			reverb.Update(newReverb)
+		}
+	}
 	for name, sampler := range s.Samplers {
 		if newSampler, ok := new.Samplers[name]; ok {
 			sampler.Update(newSampler)
@@ -559,19 +656,55 @@ func (s *Synth) updateModules(new *Synth) {
 	}
 }
 
+// setClock passes the clock to all modules that can be synced to it, new modules need it after an update
+func (s *Synth) setClock() {
+	for _, d := range s.Delays {
+		if d != nil {
+			d.SetClock(s.clock)
+		}
+	}
+	for _, e := range s.Euclids {
+		if e != nil {
+			e.SetClock(s.clock)
+		}
+	}
+	for _, g := range s.Gates {
+		if g != nil {
+			g.SetClock(s.clock)
+		}
+	}
+	for _, osc := range s.Oscillators {
+		if osc != nil {
+			osc.SetClock(s.clock)
+		}
+	}
+}
+
 func (s *Synth) initializeEmptyMaps() {
+	if s.Choruses == nil {
+		s.Choruses = module.ChorusMap{}
+	}
 	if s.Delays == nil {
 		s.Delays = module.DelayMap{}
 	}
+	if s.Dividers == nil {
+		s.Dividers = module.DividerMap{}
+	}
 	if s.Envelopes == nil {
 		s.Envelopes = module.EnvelopeMap{}
 	}
+	if s.Euclids == nil {
+		s.Euclids = module.EuclidMap{}
+	}
 	if s.Filters == nil {
 		s.Filters = module.FilterMap{}
 	}
 	if s.Gates == nil {
 		s.Gates = module.GateMap{}
 	}
+	if s.Ladders == nil {
+		s.Ladders = module.LadderMap{}
+	}
 	if s.Mixers == nil {
 		s.Mixers = module.MixerMap{}
 	}
@@ -584,6 +717,15 @@ func (s *Synth) initializeEmptyMaps() {
 	if s.Pans == nil {
 		s.Pans = module.PanMap{}
 	}
+	if s.Players == nil {
+		s.Players = module.PlayerMap{}
+	}
+	if s.Quantizers == nil {
+		s.Quantizers = module.QuantizerMap{}
+	}
+	if s.Reverbs == nil {
+		s.Reverbs = module.ReverbMap{}
+	}
 	if s.Samplers == nil {
 		s.Samplers = module.SamplerMap{}
 	}
@@ -594,3 +736,42 @@ func (s *Synth) initializeEmptyMaps() {
 		s.Wavetables = module.WavetableMap{}
 	}
 }
+
+// ResolvePaths makes the relative file paths of all modules relative to dir, which is the directory of the patch file
+func (s *Synth) ResolvePaths(dir string) {
+	for _, p := range s.Players {
+		if p != nil {
+			p.File = resolvePath(dir, p.File)
+		}
+	}
+	for _, sequencer := range s.Sequencers {
+		if sequencer != nil {
+			resolveTuningPaths(dir, sequencer.Tuning)
+		}
+	}
+	for _, q := range s.Quantizers {
+		if q != nil {
+			resolveTuningPaths(dir, q.Tuning)
+		}
+	}
+	for _, t := range s.Templates {
+		if t != nil {
+			t.dir = dir
+		}
+	}
+}
+
+func resolveTuningPaths(dir string, t *module.Tuning) {
+	if t == nil {
+		return
+	}
+	t.Scl = resolvePath(dir, t.Scl)
+	t.Kbm = resolvePath(dir, t.Kbm)
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
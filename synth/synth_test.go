@@ -1,12 +1,17 @@
 package synth
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/iljarotar/synth/module"
+	"github.com/iljarotar/synth/pcm"
+	"github.com/iljarotar/synth/wav"
 )
 
 func Test_secondsToStep(t *testing.T) {
@@ -158,17 +163,6 @@ func TestSynth_Update(t *testing.T) {
 					"w1":   w1,
 					"w2":   w2,
 				}),
-				delays:      []*module.Delay{d1, d2},
-				envelopes:   []*module.Envelope{env1, env2},
-				filters:     []*module.Filter{f1, f2},
-				gates:       []*module.Gate{g1, g2},
-				mixers:      []*module.Mixer{m1, m2},
-				noises:      []*module.Noise{n1, n2},
-				oscillators: []*module.Oscillator{o1, o2},
-				pans:        []*module.Pan{p1, p2},
-				samplers:    []*module.Sampler{s1, s2},
-				sequencers:  []*module.Sequencer{seq1, seq2},
-				wavetables:  []*module.Wavetable{w1, w2},
 			},
 			new: &Synth{
 				Out:    "new-main",
@@ -247,7 +241,7 @@ func TestSynth_Update(t *testing.T) {
 				},
 				Sequencers: module.SequencerMap{
 					"seq2": {
-						Sequence:  []string{"a_4"},
+						Sequence:  []module.SequencerStep{{Note: "a_4"}},
 						Trigger:   "new-trigger",
 						Pitch:     440,
 						Transpose: 1,
@@ -329,7 +323,7 @@ func TestSynth_Update(t *testing.T) {
 				},
 				Sequencers: module.SequencerMap{
 					"seq2": {
-						Sequence:  []string{"a_4"},
+						Sequence:  []module.SequencerStep{{Note: "a_4"}},
 						Trigger:   "new-trigger",
 						Pitch:     440,
 						Transpose: 1,
@@ -360,25 +354,15 @@ func TestSynth_Update(t *testing.T) {
 					"seq2": seq2,
 					"w2":   w2,
 				}),
-				delays:      []*module.Delay{d2},
-				envelopes:   []*module.Envelope{env2},
-				filters:     []*module.Filter{f2},
-				gates:       []*module.Gate{g2},
-				mixers:      []*module.Mixer{m2},
-				noises:      []*module.Noise{n2},
-				oscillators: []*module.Oscillator{o2},
-				pans:        []*module.Pan{p2},
-				samplers:    []*module.Sampler{s2},
-				sequencers:  []*module.Sequencer{seq2},
-				wavetables:  []*module.Wavetable{w2},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.new.Initialize(tt.s.sampleRate)
+			// the new patch references modules that don't exist, so validation is skipped
+			err := tt.new.initialize(tt.s.sampleRate)
 			if err != nil {
-				t.Errorf("Synth.Update() new.Initialize() error %v", err)
+				t.Errorf("Synth.Update() new.initialize() error %v", err)
 			}
 
 			err = tt.s.Update(tt.new)
@@ -402,6 +386,7 @@ func TestSynth_Update(t *testing.T) {
 					module.Wavetable{},
 				),
 				cmp.AllowUnexported(Synth{}, module.ModuleMap{}),
+				cmpopts.IgnoreFields(Synth{}, "steps"),
 				cmpopts.IgnoreUnexported(sync.Mutex{}),
 			); diff != "" {
 				t.Errorf("Synth.Update() diff = %s", diff)
@@ -410,6 +395,69 @@ func TestSynth_Update(t *testing.T) {
 	}
 }
 
+func TestSynth_Update_sounds(t *testing.T) {
+	sampleRate := 44100.0
+	path := filepath.Join(t.TempDir(), "sound.wav")
+	writeSound(t, path, 0.25)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSynth := func(players module.PlayerMap) *Synth {
+		t.Helper()
+		s := &Synth{Out: "n", Noises: module.NoiseMap{"n": {}}, Players: players}
+		if err := s.Initialize(sampleRate); err != nil {
+			t.Fatalf("Synth.Initialize() error = %v", err)
+		}
+		return s
+	}
+
+	s := newSynth(module.PlayerMap{"p": {File: path}})
+	if err := s.Update(newSynth(nil)); err != nil {
+		t.Fatalf("Synth.Update() error = %v", err)
+	}
+
+	// a cached sound would still be returned for a file of the same size and modification time
+	writeSound(t, path, -0.25)
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Update(newSynth(module.PlayerMap{"p": {File: path}})); err != nil {
+		t.Fatalf("Synth.Update() error = %v", err)
+	}
+	p := s.Players["p"]
+	p.Step(s.modules)
+	// the old sound is positive, the new one negative
+	if got := p.Current().Left; got >= 0 {
+		t.Errorf("player output = %v, the sound of the removed player is still cached", got)
+	}
+}
+
+// writeSound writes a short sound in which every sample has the given value
+func writeSound(t *testing.T, path string, value float64) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, err := wav.NewWriter(f, 44100, pcm.FormatS16LE)
+	if err != nil {
+		t.Fatalf("wav.NewWriter() error = %v", err)
+	}
+	for range 4 {
+		if err := w.WriteFrame([2]float64{value, value}); err != nil {
+			t.Fatalf("Writer.WriteFrame() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+}
+
 func TestSynth_initializeEmptyMaps(t *testing.T) {
 	tests := []struct {
 		name string
@@ -420,14 +468,21 @@ func TestSynth_initializeEmptyMaps(t *testing.T) {
 			name: "initialize empty",
 			s:    &Synth{},
 			want: &Synth{
+				Choruses:    module.ChorusMap{},
 				Delays:      module.DelayMap{},
+				Dividers:    module.DividerMap{},
 				Envelopes:   module.EnvelopeMap{},
+				Euclids:     module.EuclidMap{},
 				Filters:     module.FilterMap{},
 				Gates:       module.GateMap{},
+				Ladders:     module.LadderMap{},
 				Mixers:      module.MixerMap{},
 				Noises:      module.NoiseMap{},
 				Oscillators: module.OscillatorMap{},
 				Pans:        module.PanMap{},
+				Players:     module.PlayerMap{},
+				Quantizers:  module.QuantizerMap{},
+				Reverbs:     module.ReverbMap{},
 				Samplers:    module.SamplerMap{},
 				Sequencers:  module.SequencerMap{},
 				Wavetables:  module.WavetableMap{},
@@ -437,9 +492,345 @@ func TestSynth_initializeEmptyMaps(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.s.initializeEmptyMaps()
-			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Synth{})); diff != "" {
+			if diff := cmp.Diff(tt.want, tt.s, cmp.AllowUnexported(Synth{}), cmpopts.IgnoreFields(Synth{}, "steps")); diff != "" {
 				t.Errorf("Synth.initializeEmptyMaps() diff = %s", diff)
 			}
 		})
 	}
 }
+
+func Test_sortModules(t *testing.T) {
+	tests := []struct {
+		name         string
+		modules      *module.ModuleMap
+		wantOrder    []string
+		wantFeedback []string
+	}{
+		{
+			name:    "no modules",
+			modules: module.NewModuleMap(map[string]module.IModule{}),
+		},
+		{
+			name: "chain is evaluated from source to sink",
+			modules: module.NewModuleMap(map[string]module.IModule{
+				"main":   &module.Mixer{In: map[string]float64{"filter": 1}},
+				"filter": &module.Filter{In: "osc"},
+				"osc":    &module.Oscillator{CV: "seq"},
+				"seq":    &module.Sequencer{Trigger: "gate"},
+				"gate":   &module.Gate{},
+			}),
+			wantOrder: []string{"gate", "seq", "osc", "filter", "main"},
+		},
+		{
+			name: "ports are read after their module is stepped",
+			modules: module.NewModuleMap(map[string]module.IModule{
+				"env": &module.Envelope{Gate: "seq.gate"},
+				"seq": &module.Sequencer{},
+			}),
+			wantOrder: []string{"seq", "env"},
+		},
+		{
+			name: "unknown inputs are ignored",
+			modules: module.NewModuleMap(map[string]module.IModule{
+				"osc": &module.Oscillator{CV: "unknown"},
+			}),
+			wantOrder: []string{"osc"},
+		},
+		{
+			name: "feedback cycle",
+			modules: module.NewModuleMap(map[string]module.IModule{
+				"delay":  &module.Delay{In: "mix-fb"},
+				"mix-fb": &module.Mixer{In: map[string]float64{"delay": 0.5, "osc": 1}},
+				"osc":    &module.Oscillator{},
+			}),
+			wantOrder:    []string{"osc", "mix-fb", "delay"},
+			wantFeedback: []string{"mix-fb.in.delay reads delay"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, feedback := sortModules(tt.modules)
+			if diff := cmp.Diff(tt.wantOrder, order); diff != "" {
+				t.Errorf("sortModules() order diff = %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantFeedback, feedback); diff != "" {
+				t.Errorf("sortModules() feedback diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestSynth_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *Synth
+		want []Problem
+	}{
+		{
+			name: "valid patch",
+			s: &Synth{
+				Out: "main",
+				Mixers: module.MixerMap{
+					"main": {In: map[string]float64{"osc": 1}},
+				},
+				Oscillators: module.OscillatorMap{
+					"osc": {CV: "seq"},
+				},
+				Sequencers: module.SequencerMap{
+					"seq": {},
+				},
+			},
+		},
+		{
+			name: "all problems are reported",
+			s: &Synth{
+				Out: "mian",
+				Filters: module.FilterMap{
+					"lp": {In: "osc", CV: "seqq"},
+				},
+				Mixers: module.MixerMap{
+					"main": {In: map[string]float64{"lp": 1, "nosie": 1}},
+				},
+				Noises: module.NoiseMap{
+					"lp": {},
+				},
+				Oscillators: module.OscillatorMap{
+					"osc": {},
+				},
+			},
+			want: []Problem{
+				{Path: "noises.lp", Message: `name "lp" is already used by filters.lp`},
+				{Path: "filters.lp.cv", Message: `unknown module "seqq"`},
+				{Path: "mixers.main.in.nosie", Message: `unknown module "nosie"`},
+				{Path: "out", Message: `unknown module "mian"`},
+			},
+		},
+		{
+			name: "ports",
+			s: &Synth{
+				Out: "main",
+				Envelopes: module.EnvelopeMap{
+					"env": {Gate: "seq.gate"},
+				},
+				Mixers: module.MixerMap{
+					"main": {In: map[string]float64{"env": 1, "seq.velocity": 1, "seq.volume": 1, "env.gate": 1}},
+				},
+				Sequencers: module.SequencerMap{
+					"seq": {},
+				},
+			},
+			want: []Problem{
+				{Path: "mixers.main.in.env.gate", Message: `unknown module "env.gate"`},
+				{Path: "mixers.main.in.seq.volume", Message: `unknown module "seq.volume"`},
+			},
+		},
+		{
+			name: "port as out",
+			s: &Synth{
+				Out: "lp.hp",
+				Filters: module.FilterMap{
+					"lp": {Type: "LowPass"},
+				},
+			},
+		},
+		{
+			name: "missing out",
+			s:    &Synth{},
+			want: []Problem{
+				{Path: "out", Message: "no output module specified"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Problem
+			var validationErr *ValidationError
+			if err := tt.s.Validate(); errors.As(err, &validationErr) {
+				got = validationErr.Problems
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Synth.Validate() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestSynth_Check(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *Synth
+		want []Problem
+	}{
+		{
+			name: "valid patch",
+			s: &Synth{
+				Out: "main",
+				Mixers: module.MixerMap{
+					"main": {Gain: 1, In: map[string]float64{"osc": 1}},
+				},
+				Oscillators: module.OscillatorMap{
+					"osc": {Type: "Sine", Freq: 440},
+				},
+			},
+		},
+		{
+			name: "initialization errors and limited values",
+			s: &Synth{
+				Out: "main",
+				Filters: module.FilterMap{
+					"lp": {Type: "LowPss", In: "osc", Freq: 30000},
+				},
+				Mixers: module.MixerMap{
+					"main": {Gain: 2, In: map[string]float64{"lp": 1}},
+				},
+				Oscillators: module.OscillatorMap{
+					"bad": {Type: "Sin", Freq: 30000},
+					"osc": {Type: "Sine", Freq: 30000},
+				},
+				Quantizers: module.QuantizerMap{
+					"q": {Scale: "majr"},
+				},
+				Sequencers: module.SequencerMap{
+					"seq":   {Sequence: []module.SequencerStep{{Note: "a_4"}, {Note: "h_4"}}},
+					"steps": {Sequence: []module.SequencerStep{{Note: "a_4 v2 p1.5"}, {Note: "a_4 vx"}, {Note: "-", Ratchet: 100}, {Note: "a_4 b_4"}}},
+					"tuned": {Sequence: []module.SequencerStep{{Note: "0_4"}}, Tuning: &module.Tuning{EDO: 2000}},
+				},
+			},
+			want: []Problem{
+				{Path: "filters.lp.type", Message: "unknown filter type LowPss"},
+				{Path: "filters.lp.freq", Message: "value 30000 is out of range and will be limited to 20000", Warning: true},
+				{Path: "mixers.main.gain", Message: "value 2 is out of range and will be limited to 1", Warning: true},
+				{Path: "oscillators.bad.type", Message: "unknown oscillator type Sin"},
+				{Path: "oscillators.bad.freq", Message: "value 30000 is out of range and will be limited to 20000", Warning: true},
+				{Path: "oscillators.osc.freq", Message: "value 30000 is out of range and will be limited to 20000", Warning: true},
+				{Path: "quantizers.q.scale", Message: "unknown scale majr"},
+				{Path: "sequencers.seq.sequence[1]", Message: "unknown note h"},
+				{Path: "sequencers.steps.sequence[1]", Message: `invalid modifier "vx" in step "a_4 vx"`},
+				{Path: "sequencers.steps.sequence[3]", Message: `step "a_4 b_4" has more than one note`},
+				{Path: "sequencers.steps.sequence[0].vel", Message: "value 2 is out of range and will be limited to 1", Warning: true},
+				{Path: "sequencers.steps.sequence[0].prob", Message: "value 1.5 is out of range and will be limited to 1", Warning: true},
+				{Path: "sequencers.steps.sequence[2].ratchet", Message: "value 100 is out of range and will be limited to 16", Warning: true},
+				{Path: "sequencers.tuned.tuning", Message: "edo must be at least 1 and at most 1200, got 2000"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.s.Check(44100)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Synth.Check() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestSynth_Merge(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       *Synth
+		other   *Synth
+		want    *Synth
+		wantErr bool
+	}{
+		{
+			name: "modules are added",
+			s: &Synth{
+				Out: "main",
+				Mixers: module.MixerMap{
+					"main": {In: map[string]float64{"kick": 1}},
+				},
+			},
+			other: &Synth{
+				Out: "drums",
+				Mixers: module.MixerMap{
+					"drums": {In: map[string]float64{"kick": 1}},
+				},
+				Oscillators: module.OscillatorMap{
+					"kick": {Type: "Sine"},
+				},
+			},
+			want: &Synth{
+				Out: "main",
+				Mixers: module.MixerMap{
+					"main":  {In: map[string]float64{"kick": 1}},
+					"drums": {In: map[string]float64{"kick": 1}},
+				},
+				Oscillators: module.OscillatorMap{
+					"kick": {Type: "Sine"},
+				},
+			},
+		},
+		{
+			name: "names must be unique across module types",
+			s: &Synth{
+				Noises: module.NoiseMap{
+					"kick": {},
+				},
+			},
+			other: &Synth{
+				Oscillators: module.OscillatorMap{
+					"kick": {Type: "Sine"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Merge(tt.other)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Synth.Merge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, tt.s, cmpopts.IgnoreUnexported(Synth{}, module.Mixer{}, module.Oscillator{}, module.Module{})); diff != "" {
+				t.Errorf("Synth.Merge() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestSynth_clock(t *testing.T) {
+	sampleRate := 100.0
+	newGate := func() *module.Gate {
+		return &module.Gate{Sync: 0.25, Signal: []float64{1, 0, 0}}
+	}
+
+	s := &Synth{
+		Out:   "g1",
+		BPM:   120,
+		Gates: module.GateMap{"g1": newGate()},
+	}
+	if err := s.initialize(sampleRate); err != nil {
+		t.Fatalf("Synth.initialize() error = %v", err)
+	}
+	for range 70 {
+		s.GetOutput()
+	}
+
+	// a gate that is added later starts in phase with the other one
+	new := &Synth{
+		Out:   "g1",
+		BPM:   60,
+		Gates: module.GateMap{"g1": newGate(), "g2": newGate()},
+	}
+	if err := new.initialize(sampleRate); err != nil {
+		t.Fatalf("Synth.initialize() error = %v", err)
+	}
+	if err := s.Update(new); err != nil {
+		t.Fatalf("Synth.Update() error = %v", err)
+	}
+
+	for i := range 200 {
+		s.GetOutput()
+		g1, g2 := s.Gates["g1"].Current(), s.Gates["g2"].Current()
+		if g1 != g2 {
+			t.Fatalf("step %d: gates are out of phase, got %v and %v", i, g1, g2)
+		}
+	}
+	if s.clock.BPM != 60 {
+		t.Errorf("Synth.Update() bpm = %v, want 60", s.clock.BPM)
+	}
+}
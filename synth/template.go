@@ -0,0 +1,273 @@
+package synth
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/iljarotar/synth/module"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Template is a reusable part of a patch
+	// it is defined like a patch, Params holds the default values of all placeholders in its body
+	// a param without a default value must be set by each instance
+	Template struct {
+		Params map[string]any
+		body   map[any]any
+		// dir is the directory of the file the template is defined in
+		dir string
+	}
+
+	Instance struct {
+		Template string         `yaml:"template"`
+		Params   map[string]any `yaml:"params"`
+	}
+)
+
+// placeholderRegex matches params in template bodies, e.g. ${freq}
+var placeholderRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+func (t *Template) UnmarshalYAML(unmarshal func(any) error) error {
+	var params struct {
+		Params map[string]any `yaml:"params"`
+	}
+	if err := unmarshal(&params); err != nil {
+		return err
+	}
+
+	var body map[any]any
+	if err := unmarshal(&body); err != nil {
+		return err
+	}
+	delete(body, "params")
+
+	t.Params = params.Params
+	t.body = body
+	return nil
+}
+
+// expandInstances replaces all instances by the modules of their templates
+// module names are prefixed with the instance name, e.g. lead.osc, and references between them are rewritten accordingly
+// a reference to lead.out resolves to the output module of the instance's template
+func (s *Synth) expandInstances() error {
+	return s.expand(nil)
+}
+
+// expand expands instances recursively, parents holds the templates that are currently being expanded
+func (s *Synth) expand(parents []string) error {
+	if len(s.Instances) == 0 {
+		return nil
+	}
+
+	outs := map[string]string{}
+	for _, name := range sortedKeys(s.Instances) {
+		instance := s.Instances[name]
+		if instance == nil {
+			continue
+		}
+
+		sub, err := s.instantiate(name, instance, parents)
+		if err != nil {
+			return fmt.Errorf("failed to expand instance %s: %w", name, err)
+		}
+		if sub.Out != "" {
+			outs[name+".out"] = sub.Out
+		}
+
+		err = s.Merge(sub)
+		if err != nil {
+			return fmt.Errorf("failed to expand instance %s: %w", name, err)
+		}
+	}
+	s.Instances = nil
+
+	rename := func(input string) string {
+		if out, ok := outs[input]; ok {
+			return out
+		}
+		return input
+	}
+	s.renameInputs(rename)
+	s.Out = rename(s.Out)
+
+	return nil
+}
+
+// instantiate decodes the template of an instance with the instance's params and prefixes all module names
+func (s *Synth) instantiate(name string, instance *Instance, parents []string) (*Synth, error) {
+	template := s.Templates[instance.Template]
+	if template == nil {
+		return nil, fmt.Errorf("unknown template %q", instance.Template)
+	}
+	if slices.Contains(parents, instance.Template) {
+		return nil, fmt.Errorf("template cycle: %s", strings.Join(append(parents, instance.Template), " -> "))
+	}
+
+	params := maps.Clone(template.Params)
+	if params == nil {
+		params = map[string]any{}
+	}
+	for _, param := range sortedKeys(instance.Params) {
+		if _, ok := params[param]; !ok {
+			return nil, fmt.Errorf("template %s has no param %q", instance.Template, param)
+		}
+		params[param] = instance.Params[param]
+	}
+	// params without a default value are required
+	for _, param := range sortedKeys(params) {
+		if params[param] == nil {
+			return nil, fmt.Errorf("param %q of template %s is required", param, instance.Template)
+		}
+	}
+
+	body, err := substitute(template.body, params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var sub Synth
+	err = yaml.Unmarshal(data, &sub)
+	if err != nil {
+		return nil, err
+	}
+
+	// paths in the body are relative to the file the template is defined in
+	sub.ResolvePaths(template.dir)
+
+	// nested instances use the same templates
+	sub.Templates = s.Templates
+	err = sub.expand(append(parents, instance.Template))
+	if err != nil {
+		return nil, err
+	}
+	sub.Templates = nil
+
+	names := sub.Names()
+	rename := func(input string) string {
+		if _, ok := slices.BinarySearch(names, input); ok {
+			return name + "." + input
+		}
+		// ports of the template's modules, e.g. seq.gate
+		if base, _, ok := module.SplitPort(input); ok {
+			if _, ok := slices.BinarySearch(names, base); ok {
+				return name + "." + input
+			}
+		}
+		return input
+	}
+	sub.renameInputs(rename)
+	sub.Out = rename(sub.Out)
+	sub.prefixNames(name + ".")
+
+	return &sub, nil
+}
+
+func (s *Synth) renameInputs(rename func(string) string) {
+	for _, sec := range s.sections() {
+		for _, mod := range sec.modules {
+			mod.RenameInputs(rename)
+		}
+	}
+}
+
+func (s *Synth) prefixNames(prefix string) {
+	s.Choruses = prefixKeys(s.Choruses, prefix)
+	s.Delays = prefixKeys(s.Delays, prefix)
+	s.Dividers = prefixKeys(s.Dividers, prefix)
+	s.Envelopes = prefixKeys(s.Envelopes, prefix)
+	s.Euclids = prefixKeys(s.Euclids, prefix)
+	s.Filters = prefixKeys(s.Filters, prefix)
+	s.Gates = prefixKeys(s.Gates, prefix)
+	s.Ladders = prefixKeys(s.Ladders, prefix)
+	s.Mixers = prefixKeys(s.Mixers, prefix)
+	s.Noises = prefixKeys(s.Noises, prefix)
+	s.Oscillators = prefixKeys(s.Oscillators, prefix)
+	s.Pans = prefixKeys(s.Pans, prefix)
+	s.Players = prefixKeys(s.Players, prefix)
+	s.Quantizers = prefixKeys(s.Quantizers, prefix)
+	s.Reverbs = prefixKeys(s.Reverbs, prefix)
+	s.Samplers = prefixKeys(s.Samplers, prefix)
+	s.Sequencers = prefixKeys(s.Sequencers, prefix)
+	s.Wavetables = prefixKeys(s.Wavetables, prefix)
+}
+
+func prefixKeys[M ~map[string]T, T any](m M, prefix string) M {
+	if m == nil {
+		return nil
+	}
+
+	prefixed := make(M, len(m))
+	for name, v := range m {
+		prefixed[prefix+name] = v
+	}
+	return prefixed
+}
+
+// substitute replaces the placeholders in all keys and values of a decoded yaml document
+func substitute(node any, params map[string]any) (any, error) {
+	switch n := node.(type) {
+	case string:
+		return substituteString(n, params)
+
+	case map[any]any:
+		substituted := make(map[any]any, len(n))
+		for k, v := range n {
+			key, err := substitute(k, params)
+			if err != nil {
+				return nil, err
+			}
+			value, err := substitute(v, params)
+			if err != nil {
+				return nil, err
+			}
+			substituted[key] = value
+		}
+		return substituted, nil
+
+	case []any:
+		substituted := make([]any, len(n))
+		for i, v := range n {
+			value, err := substitute(v, params)
+			if err != nil {
+				return nil, err
+			}
+			substituted[i] = value
+		}
+		return substituted, nil
+	}
+
+	return node, nil
+}
+
+// substituteString replaces all placeholders in s
+// if s consists of a single placeholder, the param is returned as it is, so numbers remain numbers
+func substituteString(s string, params map[string]any) (any, error) {
+	if m := placeholderRegex.FindStringSubmatch(s); m != nil && m[0] == s {
+		value, ok := params[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown param %q", m[1])
+		}
+		return value, nil
+	}
+
+	var err error
+	substituted := placeholderRegex.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		value, ok := params[name]
+		if !ok {
+			err = fmt.Errorf("unknown param %q", name)
+			return placeholder
+		}
+		return fmt.Sprint(value)
+	})
+
+	return substituted, err
+}
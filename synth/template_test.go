@@ -0,0 +1,180 @@
+package synth
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/iljarotar/synth/module"
+	"gopkg.in/yaml.v2"
+)
+
+const voicePatch = `
+out: main
+templates:
+  voice:
+    params:
+      freq: 440
+      cutoff: 2000
+      gate:
+    out: vca
+    oscillators:
+      osc:
+        type: Sawtooth
+        freq: ${freq}
+    filters:
+      lp:
+        type: LowPass
+        freq: ${cutoff}
+        in: osc
+    mixers:
+      vca:
+        gain: 1
+        cv: ${gate}-env
+        in:
+          lp: 1
+    envelopes:
+      env:
+        gate: ${gate}
+  chord:
+    params:
+      root: 220
+    out: sum
+    instances:
+      low:
+        template: voice
+        params:
+          freq: ${root}
+          gate: gate
+      high:
+        template: voice
+        params:
+          freq: 330
+          gate: gate
+    mixers:
+      sum:
+        in:
+          low.out: 1
+          high.out: 1
+`
+
+func TestSynth_expandInstances(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances string
+		wantNames []string
+		wantMixer map[string]*module.Mixer
+		wantOut   string
+		wantErr   bool
+	}{
+		{
+			name: "instances are prefixed",
+			instances: `
+instances:
+  lead:
+    template: voice
+    params:
+      freq: 880
+      gate: clock
+mixers:
+  main:
+    in:
+      lead.out: 1
+`,
+			wantNames: []string{"lead.env", "lead.lp", "lead.osc", "lead.vca", "main"},
+			wantMixer: map[string]*module.Mixer{
+				"lead.vca": {CV: "clock-env", In: map[string]float64{"lead.lp": 1}},
+				"main":     {In: map[string]float64{"lead.vca": 1}},
+			},
+		},
+		{
+			name: "nested instances",
+			instances: `
+out: pad.out
+instances:
+  pad:
+    template: chord
+`,
+			wantNames: []string{"pad.high.env", "pad.high.lp", "pad.high.osc", "pad.high.vca", "pad.low.env", "pad.low.lp", "pad.low.osc", "pad.low.vca", "pad.sum"},
+			wantMixer: map[string]*module.Mixer{
+				"pad.sum": {In: map[string]float64{"pad.low.vca": 1, "pad.high.vca": 1}},
+			},
+			wantOut: "pad.sum",
+		},
+		{
+			name: "unknown param",
+			instances: `
+instances:
+  lead:
+    template: voice
+    params:
+      frq: 880
+`,
+			wantErr: true,
+		},
+		{
+			name: "missing param",
+			instances: `
+instances:
+  lead:
+    template: voice
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown template",
+			instances: `
+instances:
+  lead:
+    template: vocie
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Synth
+			if err := yaml.Unmarshal([]byte(voicePatch), &s); err != nil {
+				t.Fatal(err)
+			}
+			var instances Synth
+			if err := yaml.Unmarshal([]byte(tt.instances), &instances); err != nil {
+				t.Fatal(err)
+			}
+			s.Instances = instances.Instances
+			s.Mixers = instances.Mixers
+			if instances.Out != "" {
+				s.Out = instances.Out
+			}
+
+			err := s.expandInstances()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Synth.expandInstances() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tt.wantNames, s.Names()); diff != "" {
+				t.Errorf("Synth.expandInstances() names diff = %s", diff)
+			}
+			for name, want := range tt.wantMixer {
+				got := s.Mixers[name]
+				if got == nil {
+					t.Fatalf("Synth.expandInstances() mixer %s is missing", name)
+				}
+				if diff := cmp.Diff(want.In, got.In); diff != "" {
+					t.Errorf("Synth.expandInstances() %s.in diff = %s", name, diff)
+				}
+				if want.CV != got.CV {
+					t.Errorf("Synth.expandInstances() %s.cv = %s, want %s", name, got.CV, want.CV)
+				}
+			}
+			if tt.wantOut != "" && s.Out != tt.wantOut {
+				t.Errorf("Synth.expandInstances() out = %s, want %s", s.Out, tt.wantOut)
+			}
+			if osc := s.Oscillators["lead.osc"]; osc != nil && osc.Freq != 880 {
+				t.Errorf("Synth.expandInstances() lead.osc.freq = %v, want 880", osc.Freq)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/iljarotar/synth/calc"
+	"github.com/iljarotar/synth/module"
+	"github.com/samber/lo"
+)
+
+type (
+	// ValidationError lists all problems found in a patch
+	ValidationError struct {
+		Problems []Problem
+	}
+
+	// Problem refers to the offending field by its path in the patch, e.g. filters.lp.cv
+	Problem struct {
+		Path    string
+		Message string
+		// a warning doesn't prevent the patch from being played
+		Warning bool
+	}
+
+	section struct {
+		name    string
+		modules map[string]module.IModule
+		// initialize initializes a single module of the section
+		initialize func(name string, sampleRate float64) error
+	}
+)
+
+func (e *ValidationError) Error() string {
+	problems := lo.Map(e.Problems, func(p Problem, _ int) string {
+		return p.String()
+	})
+	return fmt.Sprintf("invalid patch: %s", strings.Join(problems, "; "))
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// Validate checks that module names are unique across all module types and that all references point to existing modules
+func (s *Synth) Validate() error {
+	var (
+		problems []Problem
+		owners   = map[string]string{}
+		modules  = map[string]module.IModule{}
+		sections = s.sections()
+	)
+
+	for _, sec := range sections {
+		for _, name := range sortedKeys(sec.modules) {
+			path := sec.name + "." + name
+			if owner, ok := owners[name]; ok {
+				problems = append(problems, Problem{
+					Path:    path,
+					Message: fmt.Sprintf("name %q is already used by %s", name, owner),
+				})
+				continue
+			}
+			owners[name] = path
+			modules[name] = sec.modules[name]
+		}
+	}
+
+	// known reports whether an input is the name of a module or refers to a port of a module, e.g. seq.gate
+	known := func(input string) bool {
+		if _, ok := owners[input]; ok {
+			return true
+		}
+		name, port, ok := module.SplitPort(input)
+		return ok && module.HasPort(modules[name], port)
+	}
+
+	for _, sec := range sections {
+		for _, name := range sortedKeys(sec.modules) {
+			inputs := sec.modules[name].Inputs()
+			for _, field := range sortedKeys(inputs) {
+				input := inputs[field]
+				if !known(input) {
+					problems = append(problems, Problem{
+						Path:    fmt.Sprintf("%s.%s.%s", sec.name, name, field),
+						Message: fmt.Sprintf("unknown module %q", input),
+					})
+				}
+			}
+		}
+	}
+
+	if s.Out == "" {
+		problems = append(problems, Problem{
+			Path:    "out",
+			Message: "no output module specified",
+		})
+	} else if !known(s.Out) {
+		problems = append(problems, Problem{
+			Path:    "out",
+			Message: fmt.Sprintf("unknown module %q", s.Out),
+		})
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Check expands all instances and reports all problems of a patch without preparing it for playback
+// in addition to the problems found by Validate, these are modules that fail to initialize and values that are out of range
+// the modules are initialized in the process, so the patch must not be played afterwards
+func (s *Synth) Check(sampleRate float64) []Problem {
+	if err := s.expandInstances(); err != nil {
+		return []Problem{{Path: "instances", Message: err.Error()}}
+	}
+
+	var problems []Problem
+
+	var validationErr *ValidationError
+	if err := s.Validate(); errors.As(err, &validationErr) {
+		problems = append(problems, validationErr.Problems...)
+	}
+
+	if s.Volume < 0 || s.Volume > maxVolume {
+		problems = append(problems, Problem{
+			Path:    "vol",
+			Message: fmt.Sprintf("value %v is out of range and will be limited to %v", s.Volume, calc.Limit(s.Volume, calc.Range{Min: 0, Max: maxVolume})),
+			Warning: true,
+		})
+	}
+
+	for _, sec := range s.sections() {
+		if sec.initialize == nil {
+			continue
+		}
+
+		for _, name := range sortedKeys(sec.modules) {
+			path := sec.name + "." + name
+			before := scalarFields(sec.modules[name])
+
+			// modules limit their values before they fail, so a failed module is still checked for values out of range
+			err := sec.initialize(name, sampleRate)
+			var (
+				fieldErrs module.FieldErrors
+				fieldErr  *module.FieldError
+			)
+			switch {
+			case errors.As(err, &fieldErrs):
+				for _, e := range fieldErrs {
+					problems = append(problems, Problem{
+						Path:    path + "." + e.Field,
+						Message: e.Error(),
+					})
+				}
+			case errors.As(err, &fieldErr):
+				problems = append(problems, Problem{
+					Path:    path + "." + fieldErr.Field,
+					Message: fieldErr.Error(),
+				})
+			case err != nil:
+				problems = append(problems, Problem{
+					Path:    path,
+					Message: err.Error(),
+				})
+			}
+
+			after := scalarFields(sec.modules[name])
+			for _, field := range sortedKeys(before) {
+				// zero values are left out, as they are replaced by defaults rather than limited
+				if before[field] == 0 || before[field] == after[field] {
+					continue
+				}
+				problems = append(problems, Problem{
+					Path:    path + "." + field,
+					Message: fmt.Sprintf("value %v is out of range and will be limited to %v", before[field], after[field]),
+					Warning: true,
+				})
+			}
+
+			if limiter, ok := sec.modules[name].(module.Limiter); ok {
+				for _, l := range limiter.Limited() {
+					problems = append(problems, Problem{
+						Path:    path + "." + l.Field,
+						Message: fmt.Sprintf("value %v is out of range and will be limited to %v", l.Value, l.Limit),
+						Warning: true,
+					})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+// sections returns all module maps with their names in the patch file
+func (s *Synth) sections() []section {
+	return []section{
+		{name: "choruses", modules: toModules(s.Choruses), initialize: initializer(s.Choruses, module.ChorusMap.Initialize)},
+		{name: "delays", modules: toModules(s.Delays), initialize: initializer(s.Delays, withoutError(module.DelayMap.Initialize))},
+		{name: "dividers", modules: toModules(s.Dividers), initialize: initializer(s.Dividers, withoutError(module.DividerMap.Initialize))},
+		{name: "envelopes", modules: toModules(s.Envelopes), initialize: initializer(s.Envelopes, module.EnvelopeMap.Initialize)},
+		{name: "euclids", modules: toModules(s.Euclids), initialize: initializer(s.Euclids, withoutError(module.EuclidMap.Initialize))},
+		{name: "filters", modules: toModules(s.Filters), initialize: initializer(s.Filters, module.FilterMap.Initialize)},
+		{name: "gates", modules: toModules(s.Gates), initialize: initializer(s.Gates, withoutError(module.GateMap.Initialize))},
+		{name: "ladders", modules: toModules(s.Ladders), initialize: initializer(s.Ladders, withoutError(module.LadderMap.Initialize))},
+		{name: "mixers", modules: toModules(s.Mixers), initialize: initializer(s.Mixers, module.MixerMap.Initialize)},
+		{name: "noises", modules: toModules(s.Noises)},
+		{name: "oscillators", modules: toModules(s.Oscillators), initialize: initializer(s.Oscillators, module.OscillatorMap.Initialize)},
+		{name: "pans", modules: toModules(s.Pans), initialize: initializer(s.Pans, withoutError(module.PanMap.Initialize))},
+		{name: "players", modules: toModules(s.Players), initialize: initializer(s.Players, module.PlayerMap.Initialize)},
+		{name: "quantizers", modules: toModules(s.Quantizers), initialize: initializer(s.Quantizers, func(m module.QuantizerMap, _ float64) error {
+			return m.Initialize()
+		})},
+		{name: "reverbs", modules: toModules(s.Reverbs), initialize: initializer(s.Reverbs, withoutError(module.ReverbMap.Initialize))},
+		{name: "samplers", modules: toModules(s.Samplers)},
+		{name: "sequencers", modules: toModules(s.Sequencers), initialize: initializer(s.Sequencers, func(m module.SequencerMap, _ float64) error {
+			return m.Initialize()
+		})},
+		{name: "wavetables", modules: toModules(s.Wavetables), initialize: initializer(s.Wavetables, withoutError(module.WavetableMap.Initialize))},
+	}
+}
+
+func initializer[M ~map[string]T, T any](m M, initialize func(M, float64) error) func(string, float64) error {
+	return func(name string, sampleRate float64) error {
+		return initialize(M{name: m[name]}, sampleRate)
+	}
+}
+
+func withoutError[M any](initialize func(M, float64)) func(M, float64) error {
+	return func(m M, sampleRate float64) error {
+		initialize(m, sampleRate)
+		return nil
+	}
+}
+
+// scalarFields maps the yaml names of all numeric fields of a module to their values
+func scalarFields(mod any) map[string]float64 {
+	fields := map[string]float64{}
+
+	v := reflect.Indirect(reflect.ValueOf(mod))
+	if v.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Float64:
+			fields[name] = f.Float()
+		case reflect.Int:
+			fields[name] = float64(f.Int())
+		}
+	}
+
+	return fields
+}
+
+func toModules[T interface {
+	comparable
+	module.IModule
+}](m map[string]T) map[string]module.IModule {
+	var null T
+	modules := make(map[string]module.IModule, len(m))
+	for name, mod := range m {
+		if mod == null {
+			continue
+		}
+		modules[name] = mod
+	}
+	return modules
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := lo.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
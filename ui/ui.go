@@ -3,6 +3,7 @@ package ui
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
@@ -16,6 +17,8 @@ type (
 		logger     *log.Logger
 		file       string
 		signalChan chan<- Signal
+		recordable bool
+		out        io.Writer
 
 		logs []string
 		time string
@@ -26,26 +29,37 @@ type (
 		File       string
 		Duration   float64
 		SignalChan chan<- Signal
+		Recordable bool
+		// Output defaults to stdout
+		Output io.Writer
 	}
 )
 
 const (
 	SignalQuit      Signal = "quit"
 	SignalInterrupt Signal = "interrupt"
+	SignalRecord    Signal = "record"
 )
 
 func NewUI(c Config) *UI {
+	out := c.Output
+	if out == nil {
+		out = os.Stdout
+	}
+
 	return &UI{
 		logger:     c.Logger,
 		file:       c.File,
 		signalChan: c.SignalChan,
+		recordable: c.Recordable,
+		out:        out,
 		time:       "00:00:00",
 	}
 }
 
-func LineBreaks(number int) {
+func LineBreaks(w io.Writer, number int) {
 	for range number {
-		fmt.Print("\r\n")
+		fmt.Fprint(w, "\r\n")
 	}
 }
 
@@ -79,8 +93,12 @@ func (ui *UI) read() {
 
 	for {
 		r, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return
+		}
 		if err != nil {
 			ui.logger.Error(fmt.Sprintf("failed to read input %v", err))
+			return
 		}
 		ui.handleInput(r)
 	}
@@ -96,22 +114,29 @@ func (ui *UI) handleInput(r rune) {
 	case "q":
 		ui.resetScreen()
 		ui.signalChan <- SignalQuit
+	case "r":
+		if ui.recordable {
+			ui.signalChan <- SignalRecord
+		}
 	}
 }
 
 func (ui *UI) resetScreen() {
 	ui.clear()
-	fmt.Printf("%s %s", log.Colored("Synth playing", log.ColorBlueStrong), ui.file)
-	LineBreaks(2)
+	fmt.Fprintf(ui.out, "%s %s", log.Colored("Synth playing", log.ColorBlueStrong), ui.file)
+	LineBreaks(ui.out, 2)
 
 	for _, log := range ui.logs {
-		fmt.Print(log + "\r\n")
+		fmt.Fprint(ui.out, log+"\r\n")
 	}
 	if len(ui.logs) > 0 {
-		LineBreaks(1)
+		LineBreaks(ui.out, 1)
+	}
+	fmt.Fprintf(ui.out, "%s ", ui.time)
+	fmt.Fprint(ui.out, "Press 'q' to quit")
+	if ui.recordable {
+		fmt.Fprint(ui.out, ", 'r' to start or stop recording")
 	}
-	fmt.Printf("%s ", ui.time)
-	fmt.Print("Press 'q' to quit")
 }
 
 func (ui *UI) updateTime() {
@@ -119,7 +144,7 @@ func (ui *UI) updateTime() {
 	// \0337 to save current cursor location
 	// \r to move cursor to beginning of line
 	// \0338 to restore original cursor location
-	fmt.Printf("\0337\r%s\0338", ui.time)
+	fmt.Fprintf(ui.out, "\0337\r%s\0338", ui.time)
 }
 
 func (ui *UI) appendLog(log string) {
@@ -133,7 +158,7 @@ func (ui *UI) appendLog(log string) {
 
 func (ui *UI) clear() {
 	cmd := exec.Command("clear")
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = ui.out
 	err := cmd.Run()
 	if err != nil {
 		ui.logger.Error(err.Error())
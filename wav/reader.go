@@ -0,0 +1,171 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+const formatTagExtensible = 0xfffe
+
+type (
+	// Sound holds decoded audio with samples in the interval [-1, 1]
+	Sound struct {
+		SampleRate int
+		Channels   int
+		// Frames holds the left and right channel, mono files have the same value in both
+		Frames [][2]float64
+	}
+
+	format struct {
+		tag           uint16
+		channels      int
+		sampleRate    int
+		blockAlign    int
+		bitsPerSample int
+	}
+)
+
+func ReadFile(path string) (*Sound, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode reads integer PCM with 8, 16, 24 or 32 bits and float with 32 or 64 bits
+// all channels but the first two are dropped
+func Decode(data []byte) (*Sound, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a wav file")
+	}
+
+	var (
+		f         *format
+		samples   []byte
+		hasData   bool
+		remaining = data[12:]
+	)
+	for len(remaining) >= 8 {
+		id := string(remaining[0:4])
+		size := int(binary.LittleEndian.Uint32(remaining[4:8]))
+		remaining = remaining[8:]
+		// the size of the last chunk may be wrong if the file was not finalized
+		size = min(size, len(remaining))
+		chunk := remaining[:size]
+
+		switch id {
+		case "fmt ":
+			parsed, err := parseFormat(chunk)
+			if err != nil {
+				return nil, err
+			}
+			f = parsed
+		case "data":
+			samples = chunk
+			hasData = true
+		}
+
+		// chunks are padded to an even size
+		size += size % 2
+		remaining = remaining[min(size, len(remaining)):]
+	}
+
+	if f == nil {
+		return nil, errors.New("missing fmt chunk")
+	}
+	if !hasData {
+		return nil, errors.New("missing data chunk")
+	}
+
+	return f.decode(samples)
+}
+
+func parseFormat(chunk []byte) (*format, error) {
+	if len(chunk) < 16 {
+		return nil, errors.New("fmt chunk is too short")
+	}
+
+	f := &format{
+		tag:           binary.LittleEndian.Uint16(chunk[0:2]),
+		channels:      int(binary.LittleEndian.Uint16(chunk[2:4])),
+		sampleRate:    int(binary.LittleEndian.Uint32(chunk[4:8])),
+		blockAlign:    int(binary.LittleEndian.Uint16(chunk[12:14])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+
+	// the actual format of extensible files is given by the first two bytes of the sub format
+	if f.tag == formatTagExtensible {
+		if len(chunk) < 26 {
+			return nil, errors.New("fmt chunk is too short")
+		}
+		f.tag = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+
+	if f.channels < 1 {
+		return nil, errors.New("no channels")
+	}
+	if f.sampleRate < 1 {
+		return nil, errors.New("invalid sample rate")
+	}
+
+	switch {
+	case f.tag == formatTagPCM && (f.bitsPerSample == 8 || f.bitsPerSample == 16 || f.bitsPerSample == 24 || f.bitsPerSample == 32):
+	case f.tag == formatTagFloat && (f.bitsPerSample == 32 || f.bitsPerSample == 64):
+	default:
+		return nil, fmt.Errorf("unsupported format %d with %d bits per sample", f.tag, f.bitsPerSample)
+	}
+
+	bytesPerSample := f.bitsPerSample / 8
+	if f.blockAlign < f.channels*bytesPerSample {
+		f.blockAlign = f.channels * bytesPerSample
+	}
+
+	return f, nil
+}
+
+func (f *format) decode(data []byte) (*Sound, error) {
+	var (
+		frames         = len(data) / f.blockAlign
+		bytesPerSample = f.bitsPerSample / 8
+		sound          = &Sound{
+			SampleRate: f.sampleRate,
+			Channels:   min(f.channels, 2),
+			Frames:     make([][2]float64, frames),
+		}
+	)
+
+	for i := range frames {
+		frame := data[i*f.blockAlign:]
+		left := f.sample(frame)
+		right := left
+		if f.channels > 1 {
+			right = f.sample(frame[bytesPerSample:])
+		}
+		sound.Frames[i] = [2]float64{left, right}
+	}
+
+	return sound, nil
+}
+
+func (f *format) sample(b []byte) float64 {
+	switch {
+	case f.tag == formatTagFloat && f.bitsPerSample == 32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case f.tag == formatTagFloat:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case f.bitsPerSample == 8:
+		// 8 bit samples are unsigned
+		return (float64(b[0]) - 128) / 128
+	case f.bitsPerSample == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case f.bitsPerSample == 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
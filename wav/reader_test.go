@@ -0,0 +1,142 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/iljarotar/synth/pcm"
+)
+
+func TestReadFile(t *testing.T) {
+	frames := [][2]float64{{0.5, -0.5}, {0, 0.25}, {-1, 0.999}}
+
+	tests := []struct {
+		name      string
+		format    pcm.Format
+		tolerance float64
+	}{
+		{
+			name:      "16 bit pcm",
+			format:    pcm.FormatS16LE,
+			tolerance: 2.0 / (1 << 15),
+		},
+		{
+			name:      "24 bit pcm",
+			format:    pcm.FormatS24LE,
+			tolerance: 2.0 / (1 << 23),
+		},
+		{
+			name:      "32 bit float",
+			format:    pcm.FormatF32LE,
+			tolerance: 1e-7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sound.wav")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := NewWriter(f, 48000, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, frame := range frames {
+				if err := w.WriteFrame(frame); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			want := &Sound{
+				SampleRate: 48000,
+				Channels:   2,
+				Frames:     frames,
+			}
+			if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, tt.tolerance)); diff != "" {
+				t.Errorf("ReadFile() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Sound
+		wantErr bool
+	}{
+		{
+			name: "8 bit mono",
+			data: wavFile(formatTagPCM, 1, 8, []byte{128, 255, 0}),
+			want: &Sound{
+				SampleRate: 44100,
+				Channels:   1,
+				Frames:     [][2]float64{{0, 0}, {127.0 / 128, 127.0 / 128}, {-1, -1}},
+			},
+		},
+		{
+			name: "64 bit float",
+			data: wavFile(formatTagFloat, 2, 64, binary.LittleEndian.AppendUint64(
+				binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.5)), math.Float64bits(-0.25))),
+			want: &Sound{
+				SampleRate: 44100,
+				Channels:   2,
+				Frames:     [][2]float64{{0.5, -0.25}},
+			},
+		},
+		{
+			name:    "no wav file",
+			data:    []byte("not a wav file"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			data:    wavFile(2, 1, 4, []byte{0}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Decode() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func wavFile(tag uint16, channels, bits int, data []byte) []byte {
+	var buf bytes.Buffer
+	blockAlign := channels * bits / 8
+	fields := []any{
+		[]byte("RIFF"), uint32(36 + len(data)), []byte("WAVE"),
+		[]byte("fmt "), uint32(16), tag, uint16(channels), uint32(44100), uint32(44100 * blockAlign), uint16(blockAlign), uint16(bits),
+		[]byte("data"), uint32(len(data)), data,
+	}
+	for _, f := range fields {
+		_ = binary.Write(&buf, binary.LittleEndian, f)
+	}
+	return buf.Bytes()
+}
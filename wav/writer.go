@@ -0,0 +1,172 @@
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/iljarotar/synth/pcm"
+)
+
+const (
+	formatTagPCM   = 1
+	formatTagFloat = 3
+
+	riffSizeOffset = 4
+	bufferSize     = 64 * 1024
+)
+
+type (
+	Writer struct {
+		w      io.WriteSeeker
+		buf    *bufio.Writer
+		format pcm.Format
+		frame  []byte
+		frames uint32
+		// maxFrames is the number of frames after which the sizes in the header would overflow
+		maxFrames  uint32
+		dataOffset int64
+		factOffset int64
+	}
+)
+
+// ErrTooLarge is returned for frames that don't fit into the file, as the sizes in the header are limited to 4 GiB
+var ErrTooLarge = errors.New("wav files can't be larger than 4 GiB")
+
+// NewWriter writes a WAV header with empty sizes to w
+// the sizes are filled in once the writer is closed
+func NewWriter(w io.WriteSeeker, sampleRate int, format pcm.Format) (*Writer, error) {
+	if format.BytesPerSample() == 0 {
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+
+	writer := &Writer{
+		w:      w,
+		buf:    bufio.NewWriterSize(w, bufferSize),
+		format: format,
+		frame:  make([]byte, 0, format.BytesPerFrame()),
+	}
+
+	err := writer.writeHeader(sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write wav header: %w", err)
+	}
+
+	// the riff size covers everything but its own field and the chunk id, one byte is reserved for padding
+	maxDataSize := math.MaxUint32 - (writer.dataOffset + 4 - 8) - 1
+	writer.maxFrames = uint32(maxDataSize / int64(format.BytesPerFrame()))
+
+	return writer, nil
+}
+
+// WriteFrame returns ErrTooLarge once the file has reached its maximum size, the frames written so far remain valid
+func (w *Writer) WriteFrame(frame [2]float64) error {
+	if w.frames >= w.maxFrames {
+		return ErrTooLarge
+	}
+
+	w.frame = w.format.AppendFrame(w.frame[:0], frame)
+	_, err := w.buf.Write(w.frame)
+	if err != nil {
+		return err
+	}
+	w.frames++
+	return nil
+}
+
+// Close patches the header sizes, it does not close the underlying writer
+func (w *Writer) Close() error {
+	dataSize := w.frames * uint32(w.format.BytesPerFrame())
+
+	// the data chunk must be padded to an even number of bytes
+	if dataSize%2 != 0 {
+		if err := w.buf.WriteByte(0); err != nil {
+			return err
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+
+	end, err := w.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if err := w.writeAt(riffSizeOffset, uint32(end-8)); err != nil {
+		return err
+	}
+	if err := w.writeAt(w.dataOffset, dataSize); err != nil {
+		return err
+	}
+	if w.factOffset > 0 {
+		if err := w.writeAt(w.factOffset, w.frames); err != nil {
+			return err
+		}
+	}
+
+	_, err = w.w.Seek(end, io.SeekStart)
+	return err
+}
+
+func (w *Writer) writeHeader(sampleRate int) error {
+	var (
+		bytesPerFrame = w.format.BytesPerFrame()
+		formatTag     = uint16(formatTagPCM)
+		fmtSize       = uint32(16)
+	)
+	if w.format.IsFloat() {
+		formatTag = formatTagFloat
+		fmtSize = 18
+	}
+
+	fields := []any{
+		[]byte("RIFF"),
+		uint32(0),
+		[]byte("WAVE"),
+		[]byte("fmt "),
+		fmtSize,
+		formatTag,
+		uint16(pcm.Channels),
+		uint32(sampleRate),
+		uint32(sampleRate * bytesPerFrame),
+		uint16(bytesPerFrame),
+		uint16(w.format.BytesPerSample() * 8),
+	}
+	if w.format.IsFloat() {
+		// non-PCM formats require the extension size and a fact chunk holding the number of frames
+		fields = append(fields, uint16(0), []byte("fact"), uint32(4))
+		w.factOffset = headerSize(fields)
+		fields = append(fields, uint32(0))
+	}
+	fields = append(fields, []byte("data"))
+	w.dataOffset = headerSize(fields)
+	fields = append(fields, uint32(0))
+
+	for _, f := range fields {
+		if err := binary.Write(w.buf, binary.LittleEndian, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeAt(offset int64, value uint32) error {
+	_, err := w.w.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	return binary.Write(w.w, binary.LittleEndian, value)
+}
+
+func headerSize(fields []any) int64 {
+	var size int
+	for _, f := range fields {
+		size += binary.Size(f)
+	}
+	return int64(size)
+}
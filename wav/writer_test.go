@@ -0,0 +1,136 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iljarotar/synth/pcm"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         pcm.Format
+		frames         int
+		wantFormatTag  uint16
+		wantDataOffset int
+		wantSize       int
+	}{
+		{
+			name:           "16 bit pcm",
+			format:         pcm.FormatS16LE,
+			frames:         3,
+			wantFormatTag:  formatTagPCM,
+			wantDataOffset: 40,
+			wantSize:       44 + 3*4,
+		},
+		{
+			name:           "24 bit pcm with padding",
+			format:         pcm.FormatS24LE,
+			frames:         1,
+			wantFormatTag:  formatTagPCM,
+			wantDataOffset: 40,
+			wantSize:       44 + 6,
+		},
+		{
+			name:           "32 bit float",
+			format:         pcm.FormatF32LE,
+			frames:         2,
+			wantFormatTag:  formatTagFloat,
+			wantDataOffset: 54,
+			wantSize:       58 + 2*8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.wav")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := NewWriter(f, 44100, tt.format)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			for range tt.frames {
+				if err := w.WriteFrame([2]float64{0.5, -0.5}); err != nil {
+					t.Fatalf("Writer.WriteFrame() error = %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) != tt.wantSize {
+				t.Errorf("file size = %v, want %v", len(data), tt.wantSize)
+			}
+			if got := binary.LittleEndian.Uint32(data[riffSizeOffset:]); int(got) != len(data)-8 {
+				t.Errorf("riff size = %v, want %v", got, len(data)-8)
+			}
+			if got := binary.LittleEndian.Uint16(data[20:]); got != tt.wantFormatTag {
+				t.Errorf("format tag = %v, want %v", got, tt.wantFormatTag)
+			}
+			if got := string(data[tt.wantDataOffset-4 : tt.wantDataOffset]); got != "data" {
+				t.Errorf("data chunk id = %v, want data", got)
+			}
+			wantDataSize := tt.frames * tt.format.BytesPerFrame()
+			if got := binary.LittleEndian.Uint32(data[tt.wantDataOffset:]); int(got) != wantDataSize {
+				t.Errorf("data size = %v, want %v", got, wantDataSize)
+			}
+		})
+	}
+}
+
+func TestWriter_maxFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWriter(f, 44100, pcm.FormatF32LE)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	// the riff size of a full file is the largest value that fits into its field
+	if got, want := 58-8+int64(w.maxFrames)*8, int64(math.MaxUint32); got > want || got < want-8 {
+		t.Errorf("riff size of a full file = %v, want at most %v", got, want)
+	}
+
+	w.maxFrames = 2
+	for range 2 {
+		if err := w.WriteFrame([2]float64{0.5, -0.5}); err != nil {
+			t.Fatalf("Writer.WriteFrame() error = %v", err)
+		}
+	}
+	if err := w.WriteFrame([2]float64{0.5, -0.5}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Writer.WriteFrame() error = %v, want %v", err, ErrTooLarge)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sound, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if len(sound.Frames) != 2 {
+		t.Errorf("ReadFile() got %d frames, want 2", len(sound.Frames))
+	}
+}